@@ -0,0 +1,586 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -bench=. executor_test.go homework_test.go
+
+// Executor runs tasks like the Go scheduler runs goroutines on Ps:
+// every worker has a local deque, tasks from outside go to the global
+// queue and an idle worker steals from a random victim. The owner takes
+// tasks from the bottom of its deque (LIFO, hot caches) and thieves take
+// from the top (FIFO, the biggest pieces of work in fork/join).
+type Executor struct {
+	workers []*Worker
+	global  taskDeque
+	wakeup  chan struct{} // tokens for sleeping workers
+	stop    chan struct{}
+	pending sync.WaitGroup // spawned but not finished tasks
+	running sync.WaitGroup // running workers
+	steals  atomic.Int64
+}
+
+type Worker struct {
+	executor *Executor
+	local    taskDeque
+	random   *rand.Rand // used only by goroutine of the worker
+}
+
+// Handle allows to wait for a spawned task
+type Handle struct {
+	done chan struct{}
+	err  error // set before done is closed
+}
+
+// PanicError is returned by Wait and Join of a task which panicked,
+// the panic is recovered so the worker keeps running
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
+
+func (h *Handle) Done() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until the task is finished, inside of tasks use
+// Worker.Join instead of it. It returns PanicError if the task panicked.
+func (h *Handle) Wait() error {
+	<-h.done
+	return h.err
+}
+
+type job struct {
+	task   func(*Worker)
+	handle *Handle
+}
+
+type taskDeque struct {
+	mutex sync.Mutex
+	jobs  []job
+	head  int // jobs before head are already taken from the top
+}
+
+func (d *taskDeque) pushBottom(j job) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.head != 0 && len(d.jobs) == cap(d.jobs) {
+		// reuse space of taken jobs instead of growing
+		n := copy(d.jobs, d.jobs[d.head:])
+		clear(d.jobs[n:])
+		d.jobs = d.jobs[:n]
+		d.head = 0
+	}
+
+	d.jobs = append(d.jobs, j)
+}
+
+func (d *taskDeque) popBottom() (job, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.head == len(d.jobs) {
+		return job{}, false
+	}
+
+	last := len(d.jobs) - 1
+	j := d.jobs[last]
+	d.jobs[last] = job{} // avoid memory leak
+	d.jobs = d.jobs[:last]
+	d.resetIfEmpty()
+
+	return j, true
+}
+
+func (d *taskDeque) popTop() (job, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.head == len(d.jobs) {
+		return job{}, false
+	}
+
+	j := d.jobs[d.head]
+	d.jobs[d.head] = job{} // avoid memory leak
+	d.head++
+	d.resetIfEmpty()
+
+	return j, true
+}
+
+func (d *taskDeque) resetIfEmpty() {
+	if d.head == len(d.jobs) {
+		d.jobs = d.jobs[:0]
+		d.head = 0
+	}
+}
+
+func NewExecutor(workersNumber int) *Executor {
+	executor := &Executor{
+		workers: make([]*Worker, workersNumber),
+		wakeup:  make(chan struct{}, workersNumber),
+		stop:    make(chan struct{}),
+	}
+
+	for i := range executor.workers {
+		executor.workers[i] = &Worker{
+			executor: executor,
+			random:   rand.New(rand.NewSource(int64(i))),
+		}
+	}
+
+	executor.running.Add(workersNumber)
+	for _, worker := range executor.workers {
+		go worker.run()
+	}
+
+	return executor
+}
+
+// Spawn puts the task to the global queue
+func (e *Executor) Spawn(task func(*Worker)) *Handle {
+	j := e.newJob(task)
+	e.global.pushBottom(j)
+	e.notify()
+
+	return j.handle
+}
+
+// Shutdown waits for all spawned tasks (including subtasks)
+// and stops workers, tasks must not be spawned after it
+func (e *Executor) Shutdown() {
+	e.pending.Wait()
+	close(e.stop)
+	e.running.Wait()
+}
+
+// Steals returns number of tasks taken from deques of other workers
+func (e *Executor) Steals() int64 {
+	return e.steals.Load()
+}
+
+func (e *Executor) newJob(task func(*Worker)) job {
+	e.pending.Add(1)
+	return job{
+		task:   task,
+		handle: &Handle{done: make(chan struct{})},
+	}
+}
+
+func (e *Executor) notify() {
+	select {
+	case e.wakeup <- struct{}{}:
+	default: // all workers will wake up anyway
+	}
+}
+
+// Spawn puts the subtask to the local deque of the worker
+func (w *Worker) Spawn(task func(*Worker)) *Handle {
+	j := w.executor.newJob(task)
+	w.local.pushBottom(j)
+	w.executor.notify()
+
+	return j.handle
+}
+
+// Join waits for the subtask and executes other tasks meanwhile,
+// without tasks to run it sleeps until the subtask is finished or
+// new tasks are spawned. It returns PanicError if the subtask panicked.
+func (w *Worker) Join(handle *Handle) error {
+	for !handle.Done() {
+		if j, found := w.findJob(); found {
+			w.execute(j)
+			continue
+		}
+
+		select {
+		case <-handle.done:
+		case <-w.executor.wakeup:
+		}
+	}
+
+	return handle.err
+}
+
+func (w *Worker) run() {
+	defer w.executor.running.Done()
+
+	for {
+		if j, found := w.findJob(); found {
+			w.execute(j)
+			continue
+		}
+
+		select {
+		case <-w.executor.wakeup:
+		case <-w.executor.stop:
+			return
+		}
+	}
+}
+
+func (w *Worker) findJob() (job, bool) {
+	if j, found := w.local.popBottom(); found {
+		return j, true
+	}
+
+	if j, found := w.executor.global.popTop(); found {
+		return j, true
+	}
+
+	return w.steal()
+}
+
+func (w *Worker) steal() (job, bool) {
+	workers := w.executor.workers
+	offset := w.random.Intn(len(workers))
+	for i := range workers {
+		victim := workers[(offset+i)%len(workers)]
+		if victim == w {
+			continue
+		}
+
+		if j, found := victim.local.popTop(); found {
+			w.executor.steals.Add(1)
+			return j, true
+		}
+	}
+
+	return job{}, false
+}
+
+func (w *Worker) execute(j job) {
+	defer w.executor.pending.Done()
+	defer close(j.handle.done)
+	defer func() {
+		if value := recover(); value != nil {
+			j.handle.err = &PanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+
+	j.task(w)
+}
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+
+	return fib(n-1) + fib(n-2)
+}
+
+func parallelFib(worker *Worker, n int) int {
+	const sequentialThreshold = 10
+	if n < sequentialThreshold {
+		return fib(n)
+	}
+
+	var left int
+	handle := worker.Spawn(func(worker *Worker) {
+		left = parallelFib(worker, n-1)
+	})
+	right := parallelFib(worker, n-2)
+	worker.Join(handle)
+
+	return left + right
+}
+
+func TestExecutorRunsAllTasks(t *testing.T) {
+	var counter atomic.Int32
+	executor := NewExecutor(4)
+	for i := 0; i < 1000; i++ {
+		executor.Spawn(func(*Worker) {
+			counter.Add(1)
+		})
+	}
+
+	executor.Shutdown()
+	assert.Equal(t, int32(1000), counter.Load())
+}
+
+func TestExecutorForkJoin(t *testing.T) {
+	executor := NewExecutor(4)
+	defer executor.Shutdown()
+
+	var result int
+	handle := executor.Spawn(func(worker *Worker) {
+		result = parallelFib(worker, 25)
+	})
+
+	handle.Wait()
+	assert.Equal(t, fib(25), result)
+}
+
+func TestExecutorWorkStealing(t *testing.T) {
+	var counter atomic.Int32
+	executor := NewExecutor(4)
+
+	executor.Spawn(func(worker *Worker) {
+		handles := make([]*Handle, 0, 100)
+		for i := 0; i < 100; i++ {
+			handles = append(handles, worker.Spawn(func(*Worker) {
+				time.Sleep(time.Millisecond)
+				counter.Add(1)
+			}))
+		}
+
+		for _, handle := range handles {
+			worker.Join(handle)
+		}
+	})
+
+	executor.Shutdown()
+	assert.Equal(t, int32(100), counter.Load())
+	assert.Positive(t, executor.Steals())
+}
+
+func TestExecutorSubtasksWithoutJoin(t *testing.T) {
+	var counter atomic.Int32
+	executor := NewExecutor(2)
+
+	var spawn func(worker *Worker, depth int)
+	spawn = func(worker *Worker, depth int) {
+		counter.Add(1)
+		if depth == 0 {
+			return
+		}
+
+		worker.Spawn(func(worker *Worker) { spawn(worker, depth-1) })
+		worker.Spawn(func(worker *Worker) { spawn(worker, depth-1) })
+	}
+
+	executor.Spawn(func(worker *Worker) { spawn(worker, 9) })
+	executor.Shutdown()
+
+	assert.Equal(t, int32(1<<10-1), counter.Load())
+}
+
+func TestExecutorPanic(t *testing.T) {
+	executor := NewExecutor(1)
+	defer executor.Shutdown()
+
+	var joinErr error
+	var counter atomic.Int32
+	handle := executor.Spawn(func(worker *Worker) {
+		joinErr = worker.Join(worker.Spawn(func(*Worker) {
+			panic("boom")
+		}))
+		counter.Add(1)
+	})
+	assert.NoError(t, handle.Wait())
+
+	var panicErr *PanicError
+	require.ErrorAs(t, joinErr, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+
+	// the only worker survives the panic
+	assert.ErrorAs(t, executor.Spawn(func(*Worker) { panic("again") }).Wait(), &panicErr)
+	assert.NoError(t, executor.Spawn(func(*Worker) { counter.Add(1) }).Wait())
+	assert.Equal(t, int32(2), counter.Load())
+}
+
+func TestTaskDeque(t *testing.T) {
+	var deque taskDeque
+	for i := 0; i < 3; i++ {
+		deque.pushBottom(job{handle: &Handle{}})
+	}
+
+	first := deque.jobs[0].handle
+	last := deque.jobs[2].handle
+
+	j, found := deque.popTop()
+	assert.True(t, found)
+	assert.Same(t, first, j.handle)
+
+	j, found = deque.popBottom()
+	assert.True(t, found)
+	assert.Same(t, last, j.handle)
+
+	_, found = deque.popBottom()
+	assert.True(t, found)
+	_, found = deque.popTop()
+	assert.False(t, found)
+	assert.Zero(t, deque.head)
+}
+
+// tree computation over implicit complete binary tree,
+// children of node i are nodes 2*i+1 and 2*i+2
+
+const (
+	treeDepth           = 20
+	treeSequentialDepth = 8 // subtrees of this depth are computed inline
+)
+
+func newTree() []int {
+	values := make([]int, 1<<treeDepth-1)
+	for i := range values {
+		values[i] = i % 7
+	}
+
+	return values
+}
+
+func treeSum(values []int, node int) int {
+	if node >= len(values) {
+		return 0
+	}
+
+	return values[node] + treeSum(values, 2*node+1) + treeSum(values, 2*node+2)
+}
+
+func treeDepthOf(values []int, node int) int {
+	depth := 0
+	for node < len(values) {
+		node = 2*node + 1
+		depth++
+	}
+
+	return depth
+}
+
+func executorTreeSum(worker *Worker, values []int, node int) int {
+	if treeDepthOf(values, node) <= treeSequentialDepth {
+		return treeSum(values, node)
+	}
+
+	var left int
+	handle := worker.Spawn(func(worker *Worker) {
+		left = executorTreeSum(worker, values, 2*node+1)
+	})
+	right := executorTreeSum(worker, values, 2*node+2)
+	worker.Join(handle)
+
+	return values[node] + left + right
+}
+
+// workerPoolTreeSum spawns subtrees to the channel based WorkerPool,
+// all workers read tasks from its single shared channel
+func workerPoolTreeSum(pool *WorkerPool, wg *sync.WaitGroup, sum *atomic.Int64, values []int, node int) {
+	defer wg.Done()
+
+	if treeDepthOf(values, node) <= treeSequentialDepth {
+		sum.Add(int64(treeSum(values, node)))
+		return
+	}
+
+	sum.Add(int64(values[node]))
+	for _, child := range []int{2*node + 1, 2*node + 2} {
+		wg.Add(1)
+		err := pool.AddTask(func() {
+			workerPoolTreeSum(pool, wg, sum, values, child)
+		})
+		if err != nil {
+			// pool is full, caller runs the task
+			workerPoolTreeSum(pool, wg, sum, values, child)
+		}
+	}
+}
+
+func TestTreeSum(t *testing.T) {
+	values := newTree()
+	expected := treeSum(values, 0)
+
+	executor := NewExecutor(4)
+	handle := executor.Spawn(func(worker *Worker) {
+		assert.Equal(t, expected, executorTreeSum(worker, values, 0))
+	})
+	handle.Wait()
+	executor.Shutdown()
+
+	var wg sync.WaitGroup
+	var sum atomic.Int64
+	pool := NewWorkerPool(4)
+	wg.Add(1)
+	workerPoolTreeSum(pool, &wg, &sum, values, 0)
+	wg.Wait()
+	pool.Shutdown()
+	assert.Equal(t, int64(expected), sum.Load())
+}
+
+var Sink int
+
+func BenchmarkTreeSumSequential(b *testing.B) {
+	values := newTree()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		Sink = treeSum(values, 0)
+	}
+}
+
+func BenchmarkTreeSumExecutor(b *testing.B) {
+	values := newTree()
+	executor := NewExecutor(runtime.GOMAXPROCS(0))
+	defer executor.Shutdown()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		executor.Spawn(func(worker *Worker) {
+			Sink = executorTreeSum(worker, values, 0)
+		}).Wait()
+	}
+}
+
+func BenchmarkTreeSumWorkerPool(b *testing.B) {
+	values := newTree()
+	pool := NewWorkerPool(runtime.GOMAXPROCS(0))
+	defer pool.Shutdown()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		var sum atomic.Int64
+		wg.Add(1)
+		workerPoolTreeSum(pool, &wg, &sum, values, 0)
+		wg.Wait()
+		Sink = int(sum.Load())
+	}
+}
+
+func BenchmarkFlatTasksExecutor(b *testing.B) {
+	executor := NewExecutor(runtime.GOMAXPROCS(0))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		executor.Spawn(func(*Worker) {
+			Sink = fib(10)
+		})
+	}
+
+	executor.Shutdown()
+}
+
+func BenchmarkFlatTasksWorkerPool(b *testing.B) {
+	pool := NewWorkerPool(runtime.GOMAXPROCS(0))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for pool.AddTask(func() { Sink = fib(10) }) != nil {
+			runtime.Gosched()
+		}
+	}
+
+	pool.Shutdown()
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -10,25 +12,67 @@ import (
 
 // go test -v homework_test.go
 
+var (
+	ErrPoolFull   = errors.New("worker pool is full")
+	ErrPoolClosed = errors.New("worker pool is closed")
+)
+
+// every worker can have this number of tasks waiting in the queue
+const tasksPerWorker = 2
+
 type WorkerPool struct {
-	// need to implement
+	tasks   chan func()
+	mutex   sync.RWMutex // protects tasks from sending after close
+	closed  bool
+	workers sync.WaitGroup
 }
 
 func NewWorkerPool(workersNumber int) *WorkerPool {
-	// need to implement
-	return &WorkerPool{}
+	pool := &WorkerPool{
+		tasks: make(chan func(), workersNumber*tasksPerWorker),
+	}
+
+	pool.workers.Add(workersNumber)
+	for i := 0; i < workersNumber; i++ {
+		go func() {
+			defer pool.workers.Done()
+			for task := range pool.tasks {
+				task()
+			}
+		}()
+	}
+
+	return pool
 }
 
 // Return an error if the pool is full
 func (wp *WorkerPool) AddTask(task func()) error {
-	// need to implement
-	return nil
+	wp.mutex.RLock()
+	defer wp.mutex.RUnlock()
+
+	if wp.closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case wp.tasks <- task:
+		return nil
+	default:
+		return ErrPoolFull
+	}
 }
 
 // Shutdown all workers and wait for all
 // tasks in the pool to complete
 func (wp *WorkerPool) Shutdown() {
-	// need to implement
+	wp.mutex.Lock()
+	if !wp.closed {
+		wp.closed = true
+		close(wp.tasks)
+	}
+	wp.mutex.Unlock()
+
+	wp.workers.Wait()
 }
 
 func TestWorkerPool(t *testing.T) {
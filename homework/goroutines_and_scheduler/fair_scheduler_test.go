@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const defaultTenantWeight = 1
+
+var ErrDuplicateTask = errors.New("task is already queued")
+
+type tenantQueue struct {
+	name    string
+	weight  int // from the last added task with positive Weight
+	deficit int
+	tasks   Scheduler
+}
+
+// FairScheduler shares dispatches between tenants with deficit round robin:
+// every turn a tenant may take up to weight tasks from its own Scheduler,
+// so a noisy tenant cannot monopolise workers. Tenant weight is set by
+// SetTenantWeight or taken from the last added task of this tenant with
+// positive Weight. Weights set by SetTenantWeight are permanent until
+// reset, weights taken from tasks are dropped with the tenant queue when
+// the tenant has no tasks, so short-lived tenants do not accumulate.
+type FairScheduler struct {
+	tenants    map[string]*tenantQueue // tenants with tasks
+	weights    map[string]int          // set by SetTenantWeight
+	active     []*tenantQueue          // round robin order
+	current    int
+	taskTenant map[int]*tenantQueue
+	options    []SchedulerOption // for schedulers of tenants
+}
+
+func NewFairScheduler(options ...SchedulerOption) FairScheduler {
+	return FairScheduler{
+		tenants:    make(map[string]*tenantQueue),
+		weights:    make(map[string]int),
+		taskTenant: make(map[int]*tenantQueue),
+		options:    options,
+	}
+}
+
+// SetTenantWeight sets the number of tasks the tenant takes per turn,
+// it overrides weights of tasks. Not positive weight resets it.
+func (s *FairScheduler) SetTenantWeight(tenant string, weight int) {
+	if weight <= 0 {
+		delete(s.weights, tenant)
+		return
+	}
+
+	s.weights[tenant] = weight
+}
+
+// TenantWeight returns the weight of the tenant, without tasks
+// only the weight set by SetTenantWeight is kept
+func (s *FairScheduler) TenantWeight(tenant string) int {
+	if weight, found := s.weights[tenant]; found {
+		return weight
+	}
+
+	if queue, exists := s.tenants[tenant]; exists && queue.weight > 0 {
+		return queue.weight
+	}
+
+	return defaultTenantWeight
+}
+
+// AddTask queues the task of its tenant, identifiers must be unique
+// among queued tasks of all tenants since ChangeTaskPriority finds
+// tasks by identifier only
+func (s *FairScheduler) AddTask(task Task) error {
+	if queued, exists := s.taskTenant[task.Identifier]; exists {
+		return fmt.Errorf("%w: %d of tenant %q", ErrDuplicateTask, task.Identifier, queued.name)
+	}
+
+	tenant, exists := s.tenants[task.Tenant]
+	if !exists {
+		tenant = &tenantQueue{
+			name:  task.Tenant,
+			tasks: NewScheduler(s.options...),
+		}
+		s.tenants[task.Tenant] = tenant
+		s.active = append(s.active, tenant)
+	}
+
+	if task.Weight > 0 {
+		tenant.weight = task.Weight
+	}
+
+	tenant.tasks.AddTask(task)
+	s.taskTenant[task.Identifier] = tenant
+	return nil
+}
+
+func (s *FairScheduler) ChangeTaskPriority(taskID int, newPriority int) {
+	tenant, exists := s.taskTenant[taskID]
+	if !exists {
+		return
+	}
+
+	tenant.tasks.ChangeTaskPriority(taskID, newPriority)
+}
+
+func (s *FairScheduler) GetTask() Task {
+	// every tenant is visited at most once, tenants
+	// with delayed tasks only can give nothing
+	for range len(s.active) {
+		tenant := s.active[s.current]
+		if tenant.deficit == 0 {
+			tenant.deficit = s.TenantWeight(tenant.name)
+		}
+
+		task, ok := tenant.tasks.getTask()
+		if !ok {
+			tenant.deficit = 0
+			s.next()
+			continue
+		}
+
+		tenant.deficit--
+		delete(s.taskTenant, task.Identifier)
+
+		if tenant.tasks.Len() == 0 {
+			s.removeCurrent()
+		} else if tenant.deficit == 0 {
+			s.next()
+		}
+
+		return task
+	}
+
+	return Task{}
+}
+
+// QueueDepth returns number of tasks of the tenant including delayed ones
+func (s *FairScheduler) QueueDepth(tenant string) int {
+	queue, exists := s.tenants[tenant]
+	if !exists {
+		return 0
+	}
+
+	return queue.tasks.Len()
+}
+
+// QueueDepths returns number of tasks for every tenant with tasks
+func (s *FairScheduler) QueueDepths() map[string]int {
+	depths := make(map[string]int, len(s.tenants))
+	for name, tenant := range s.tenants {
+		depths[name] = tenant.tasks.Len()
+	}
+
+	return depths
+}
+
+func (s *FairScheduler) next() {
+	s.current = (s.current + 1) % len(s.active)
+}
+
+// removeCurrent drops the queue of a drained tenant
+// together with the weight taken from its tasks
+func (s *FairScheduler) removeCurrent() {
+	tenant := s.active[s.current]
+	delete(s.tenants, tenant.name)
+
+	s.active = append(s.active[:s.current], s.active[s.current+1:]...)
+	if s.current == len(s.active) {
+		s.current = 0
+	}
+}
+
+func TestFairSchedulerEmpty(t *testing.T) {
+	scheduler := NewFairScheduler()
+	assert.Equal(t, Task{}, scheduler.GetTask())
+	assert.Empty(t, scheduler.QueueDepths())
+}
+
+func TestFairSchedulerNoisyTenant(t *testing.T) {
+	scheduler := NewFairScheduler()
+	for id := 0; id < 1000; id++ {
+		scheduler.AddTask(Task{Identifier: id, Priority: 100, Tenant: "noisy"})
+	}
+	for id := 1000; id < 1005; id++ {
+		scheduler.AddTask(Task{Identifier: id, Priority: 1, Tenant: "quiet"})
+	}
+
+	var tenants []string
+	for i := 0; i < 10; i++ {
+		tenants = append(tenants, scheduler.GetTask().Tenant)
+	}
+
+	expected := []string{"noisy", "quiet", "noisy", "quiet", "noisy", "quiet", "noisy", "quiet", "noisy", "quiet"}
+	assert.Equal(t, expected, tenants)
+	assert.Equal(t, map[string]int{"noisy": 995}, scheduler.QueueDepths())
+}
+
+func TestFairSchedulerWeights(t *testing.T) {
+	scheduler := NewFairScheduler()
+	for id := 0; id < 300; id++ {
+		scheduler.AddTask(Task{Identifier: id, Tenant: "gold", Weight: 3})
+		scheduler.AddTask(Task{Identifier: id + 1000, Tenant: "silver", Weight: 1})
+	}
+
+	counters := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		counters[scheduler.GetTask().Tenant]++
+	}
+
+	assert.Equal(t, map[string]int{"gold": 300, "silver": 100}, counters)
+	assert.Equal(t, 0, scheduler.QueueDepth("gold"))
+	assert.Equal(t, 200, scheduler.QueueDepth("silver"))
+}
+
+func TestFairSchedulerWeightsAfterDrain(t *testing.T) {
+	scheduler := NewFairScheduler()
+	scheduler.SetTenantWeight("gold", 3)
+	scheduler.AddTask(Task{Identifier: 1, Tenant: "gold"})
+	scheduler.AddTask(Task{Identifier: 2, Tenant: "silver", Weight: 2})
+	assert.Equal(t, 2, scheduler.TenantWeight("silver"))
+
+	// both tenants drain and lose their queues
+	scheduler.GetTask()
+	scheduler.GetTask()
+	assert.Empty(t, scheduler.QueueDepths())
+	assert.Equal(t, 3, scheduler.TenantWeight("gold"))
+	assert.Equal(t, defaultTenantWeight, scheduler.TenantWeight("silver"))
+
+	// tasks come back without Weight
+	for id := 10; id < 310; id++ {
+		scheduler.AddTask(Task{Identifier: id, Tenant: "gold"})
+		scheduler.AddTask(Task{Identifier: id + 1000, Tenant: "silver"})
+	}
+
+	counters := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		counters[scheduler.GetTask().Tenant]++
+	}
+	assert.Equal(t, map[string]int{"gold": 300, "silver": 100}, counters)
+
+	scheduler.SetTenantWeight("gold", 0)
+	assert.Equal(t, defaultTenantWeight, scheduler.TenantWeight("gold"))
+	assert.Equal(t, defaultTenantWeight, scheduler.TenantWeight("unknown"))
+}
+
+func TestFairSchedulerExplicitWeightOverridesTasks(t *testing.T) {
+	scheduler := NewFairScheduler()
+	scheduler.SetTenantWeight("gold", 3)
+	scheduler.AddTask(Task{Identifier: 1, Tenant: "gold", Weight: 5})
+	assert.Equal(t, 3, scheduler.TenantWeight("gold"))
+
+	scheduler.SetTenantWeight("gold", 0)
+	assert.Equal(t, 5, scheduler.TenantWeight("gold"))
+}
+
+func TestFairSchedulerDuplicateTask(t *testing.T) {
+	scheduler := NewFairScheduler()
+	task1 := Task{Identifier: 1, Priority: 10, Tenant: "a"}
+	task2 := Task{Identifier: 2, Priority: 20, Tenant: "a"}
+	assert.NoError(t, scheduler.AddTask(task1))
+	assert.NoError(t, scheduler.AddTask(task2))
+	assert.ErrorIs(t, scheduler.AddTask(Task{Identifier: 1, Tenant: "b"}), ErrDuplicateTask)
+	assert.ErrorIs(t, scheduler.AddTask(Task{Identifier: 2, Tenant: "a"}), ErrDuplicateTask)
+	assert.Equal(t, map[string]int{"a": 2}, scheduler.QueueDepths())
+
+	scheduler.ChangeTaskPriority(1, 30)
+	assert.Equal(t, task1, scheduler.GetTask())
+
+	// identifier can be reused after the task is taken
+	task3 := Task{Identifier: 1, Tenant: "b"}
+	assert.NoError(t, scheduler.AddTask(task3))
+	assert.Equal(t, task2, scheduler.GetTask())
+	assert.Equal(t, task3, scheduler.GetTask())
+}
+
+func TestFairSchedulerPriorityInsideTenant(t *testing.T) {
+	scheduler := NewFairScheduler()
+	task1 := Task{Identifier: 1, Priority: 10, Tenant: "a"}
+	task2 := Task{Identifier: 2, Priority: 20, Tenant: "a"}
+	task3 := Task{Identifier: 3, Priority: 5, Tenant: "b"}
+	scheduler.AddTask(task1)
+	scheduler.AddTask(task2)
+	scheduler.AddTask(task3)
+
+	scheduler.ChangeTaskPriority(1, 30)
+	scheduler.ChangeTaskPriority(999, 30)
+
+	assert.Equal(t, task1, scheduler.GetTask())
+	assert.Equal(t, task3, scheduler.GetTask())
+	assert.Equal(t, task2, scheduler.GetTask())
+	assert.Equal(t, Task{}, scheduler.GetTask())
+}
+
+func TestFairSchedulerDelayedTenant(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewFairScheduler(WithClock(clock.Now))
+
+	task1 := Task{Identifier: 1, Tenant: "a", NotBefore: clock.Now().Add(time.Second)}
+	task2 := Task{Identifier: 2, Tenant: "b"}
+	scheduler.AddTask(task1)
+	scheduler.AddTask(task2)
+
+	assert.Equal(t, task2, scheduler.GetTask())
+	assert.Equal(t, Task{}, scheduler.GetTask())
+	assert.Equal(t, map[string]int{"a": 1}, scheduler.QueueDepths())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, task1, scheduler.GetTask())
+	assert.Empty(t, scheduler.QueueDepths())
+}
+
+func BenchmarkFairScheduler(b *testing.B) {
+	const tenantsNumber = 100
+	scheduler := NewFairScheduler()
+	for id := 0; id < benchmarkTasksNumber; id++ {
+		tenant := fmt.Sprint("tenant-", id%tenantsNumber)
+		scheduler.AddTask(Task{Identifier: id, Tenant: tenant, Weight: id%tenantsNumber + 1})
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		task := scheduler.GetTask()
+		scheduler.AddTask(task)
+	}
+}
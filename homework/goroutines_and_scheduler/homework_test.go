@@ -2,25 +2,49 @@ package main
 
 import (
 	"container/heap"
+	"math"
+	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 type Task struct {
-	Identifier       int
-	Priority         int
-	sequence         int // for FIFO ordering (private field)
-	originalPriority int // store original priority
+	Identifier int
+	Priority   int
+	Deadline   time.Time // optional, tasks with deadline go first in EDF order, see WithStarvationLimit
+	NotBefore  time.Time // optional, task is invisible until this moment
+	Tenant     string    // optional, used by FairScheduler
+	Weight     int       // optional, tenant weight for FairScheduler
+
+	sequence         int       // for FIFO ordering (private field)
+	originalPriority int       // store original priority
+	index            int       // position in heap, maintained by TaskHeap.Swap
+	rank             int64     // priority with aging applied, see Scheduler.rank
+	enqueuedAt       time.Time // moment when task became visible
+	delayed          bool      // task is waiting for NotBefore
 }
-type TaskHeap []Task
+
+// TaskHeap is an indexed heap: every move of an element updates its index,
+// so a task can be found and fixed in O(log n) without scanning the heap.
+// Scheduler keeps tasks with and without deadline in separate heaps.
+type TaskHeap []*Task
 
 func (h TaskHeap) Len() int { return len(h) }
 
 func (h TaskHeap) Less(i, j int) bool {
+	// Tasks with deadline first, earliest deadline first
+	hasDeadlineI, hasDeadlineJ := !h[i].Deadline.IsZero(), !h[j].Deadline.IsZero()
+	if hasDeadlineI != hasDeadlineJ {
+		return hasDeadlineI
+	}
+	if hasDeadlineI && !h[i].Deadline.Equal(h[j].Deadline) {
+		return h[i].Deadline.Before(h[j].Deadline)
+	}
 	// Higher priority first
-	if h[i].Priority != h[j].Priority {
-		return h[i].Priority > h[j].Priority
+	if h[i].rank != h[j].rank {
+		return h[i].rank > h[j].rank
 	}
 	// FIFO for same priority
 	return h[i].sequence < h[j].sequence
@@ -28,34 +52,96 @@ func (h TaskHeap) Less(i, j int) bool {
 
 func (h TaskHeap) Swap(i, j int) {
 	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
 }
 
 func (h *TaskHeap) Push(x interface{}) {
-	*h = append(*h, x.(Task))
+	task := x.(*Task)
+	task.index = len(*h)
+	*h = append(*h, task)
 }
 
 func (h *TaskHeap) Pop() interface{} {
 	old := *h
 	n := len(old)
 	x := old[n-1]
+	old[n-1] = nil // avoid memory leak
+	x.index = -1
 	*h = old[0 : n-1]
 	return x
 }
 
+// delayedTaskHeap keeps tasks that are not visible yet, earliest NotBefore first
+type delayedTaskHeap struct {
+	TaskHeap
+}
+
+func (h delayedTaskHeap) Less(i, j int) bool {
+	if !h.TaskHeap[i].NotBefore.Equal(h.TaskHeap[j].NotBefore) {
+		return h.TaskHeap[i].NotBefore.Before(h.TaskHeap[j].NotBefore)
+	}
+	return h.TaskHeap[i].sequence < h.TaskHeap[j].sequence
+}
+
+type SchedulerOption func(*Scheduler)
+
+// WithClock replaces time.Now, mostly for tests
+func WithClock(now func() time.Time) SchedulerOption {
+	return func(scheduler *Scheduler) {
+		scheduler.now = now
+	}
+}
+
+// WithAging increases effective priority of a task by one
+// for every step it waits in the scheduler
+func WithAging(step time.Duration) SchedulerOption {
+	return func(scheduler *Scheduler) {
+		scheduler.agingStep = step
+	}
+}
+
+// WithStarvationLimit lets the best task without deadline go before
+// tasks with deadline once it has waited for limit. Without the limit
+// tasks with deadline always go first, so a steady stream of them
+// starves other tasks even with aging.
+func WithStarvationLimit(limit time.Duration) SchedulerOption {
+	return func(scheduler *Scheduler) {
+		scheduler.starvationLimit = limit
+	}
+}
+
 type Scheduler struct {
-	tasks   *TaskHeap
-	taskMap map[int]int // taskID -> index in heap
-	nextSeq int         // for FIFO ordering
+	tasks     *TaskHeap // tasks without deadline by priority with aging
+	deadlines *TaskHeap // tasks with deadline, earliest deadline first
+	delayed   *delayedTaskHeap
+	taskMap   map[int]*Task // taskID -> task, task.index is its position in heap
+	nextSeq   int           // for FIFO ordering
+
+	now             func() time.Time
+	agingStep       time.Duration
+	starvationLimit time.Duration
+	epoch           time.Time // reference point for aging ranks
 }
 
-func NewScheduler() Scheduler {
+func NewScheduler(options ...SchedulerOption) Scheduler {
 	h := &TaskHeap{}
 	heap.Init(h)
-	return Scheduler{
-		tasks:   h,
-		taskMap: make(map[int]int),
-		nextSeq: 0,
+	scheduler := Scheduler{
+		tasks:     h,
+		deadlines: &TaskHeap{},
+		delayed:   &delayedTaskHeap{},
+		taskMap:   make(map[int]*Task),
+		nextSeq:   0,
+		now:       time.Now,
+	}
+
+	for _, option := range options {
+		option(&scheduler)
 	}
+
+	scheduler.epoch = scheduler.now()
+	return scheduler
 }
 
 func (s *Scheduler) AddTask(task Task) {
@@ -63,42 +149,142 @@ func (s *Scheduler) AddTask(task Task) {
 	task.sequence = s.nextSeq
 	s.nextSeq++
 
-	heap.Push(s.tasks, task)
+	s.taskMap[task.Identifier] = &task
 
-	s.taskMap[task.Identifier] = s.tasks.Len() - 1
+	now := s.now()
+	if task.NotBefore.After(now) {
+		task.delayed = true
+		heap.Push(s.delayed, &task)
+		return
+	}
+
+	task.enqueuedAt = now
+	s.push(&task)
 }
 
 func (s *Scheduler) ChangeTaskPriority(taskID int, newPriority int) {
-	index, exists := s.taskMap[taskID]
+	task, exists := s.taskMap[taskID]
 	if !exists {
 		return
 	}
-	(*s.tasks)[index].Priority = newPriority
-
-	heap.Fix(s.tasks, index)
+	task.Priority = newPriority
 
-	for i, task := range *s.tasks {
-		s.taskMap[task.Identifier] = i
+	if task.delayed {
+		return // rank will be calculated when task becomes visible
 	}
+
+	task.rank = s.rank(task)
+	heap.Fix(s.heapOf(task), task.index)
 }
 
 func (s *Scheduler) GetTask() Task {
-	if s.tasks.Len() == 0 {
-		return Task{}
+	task, _ := s.getTask()
+	return task
+}
+
+// Len returns number of tasks in the scheduler including delayed ones
+func (s *Scheduler) Len() int {
+	return len(s.taskMap)
+}
+
+func (s *Scheduler) getTask() (Task, bool) {
+	s.promoteDelayedTasks()
+	tasks := s.next()
+	if tasks.Len() == 0 {
+		return Task{}, false
 	}
 
-	task := heap.Pop(s.tasks).(Task)
+	task := heap.Pop(tasks).(*Task)
 
 	delete(s.taskMap, task.Identifier)
 
-	for i, t := range *s.tasks {
-		s.taskMap[t.Identifier] = i
-	}
-
 	// Return task with original priority (due to original test requirements)
 	return Task{
 		Identifier: task.Identifier,
 		Priority:   task.originalPriority,
+		Deadline:   task.Deadline,
+		NotBefore:  task.NotBefore,
+		Tenant:     task.Tenant,
+		Weight:     task.Weight,
+	}, true
+}
+
+func (s *Scheduler) push(task *Task) {
+	task.rank = s.rank(task)
+	heap.Push(s.heapOf(task), task)
+}
+
+func (s *Scheduler) heapOf(task *Task) *TaskHeap {
+	if task.Deadline.IsZero() {
+		return s.tasks
+	}
+
+	return s.deadlines
+}
+
+// next returns the heap with the task to run: tasks with deadline
+// go first unless the best task without deadline is starving
+func (s *Scheduler) next() *TaskHeap {
+	switch {
+	case s.deadlines.Len() == 0:
+		return s.tasks
+	case s.tasks.Len() == 0:
+		return s.deadlines
+	case s.starvationLimit > 0 && s.now().Sub((*s.tasks)[0].enqueuedAt) >= s.starvationLimit:
+		return s.tasks
+	default:
+		return s.deadlines
+	}
+}
+
+func (s *Scheduler) promoteDelayedTasks() {
+	now := s.now()
+	for s.delayed.Len() != 0 {
+		task := s.delayed.TaskHeap[0]
+		if task.NotBefore.After(now) {
+			return
+		}
+
+		heap.Pop(s.delayed)
+		task.delayed = false
+		task.enqueuedAt = task.NotBefore
+		s.push(task)
+	}
+}
+
+// rank returns effective priority of the task. With aging it is
+// Priority + (now - enqueuedAt) / agingStep scaled by agingStep, and
+// since now is the same for all tasks it can be dropped from the
+// comparison, so ranks never change while tasks are waiting.
+// Huge priorities saturate instead of overflowing.
+func (s *Scheduler) rank(task *Task) int64 {
+	if s.agingStep <= 0 {
+		return int64(task.Priority)
+	}
+
+	priority, step := int64(task.Priority), int64(s.agingStep)
+	var scaled int64
+	switch {
+	case priority > math.MaxInt64/step:
+		scaled = math.MaxInt64
+	case priority < math.MinInt64/step:
+		scaled = math.MinInt64
+	default:
+		scaled = priority * step
+	}
+
+	return saturatingSub(scaled, int64(task.enqueuedAt.Sub(s.epoch)))
+}
+
+func saturatingSub(a, b int64) int64 {
+	result := a - b
+	switch {
+	case b > 0 && result > a:
+		return math.MinInt64
+	case b < 0 && result < a:
+		return math.MaxInt64
+	default:
+		return result
 	}
 }
 
@@ -181,10 +367,276 @@ func TestMultiplePriorityChanges(t *testing.T) {
 	scheduler.ChangeTaskPriority(1, 5)
 	scheduler.ChangeTaskPriority(1, 25)
 
-	// task2 should be first (priority 20), then task1 (priority 10)
+	// task1 should be first (priority 25), then task2 (priority 20)
 	task := scheduler.GetTask()
+	assert.Equal(t, task1, task)
+
+	task = scheduler.GetTask()
 	assert.Equal(t, task2, task)
+}
+
+func TestChangePriorityAfterSiftUp(t *testing.T) {
+	scheduler := NewScheduler()
+	for id := 1; id <= 10; id++ {
+		scheduler.AddTask(Task{Identifier: id, Priority: id * 10})
+	}
+
+	// task10 was sifted to the root on push, its index must follow it
+	scheduler.ChangeTaskPriority(10, 0)
+	scheduler.ChangeTaskPriority(1, 1000)
+
+	task := scheduler.GetTask()
+	assert.Equal(t, Task{Identifier: 1, Priority: 10}, task)
+
+	for id := 9; id >= 2; id-- {
+		task = scheduler.GetTask()
+		assert.Equal(t, Task{Identifier: id, Priority: id * 10}, task)
+	}
 
 	task = scheduler.GetTask()
-	assert.Equal(t, task1, task)
+	assert.Equal(t, Task{Identifier: 10, Priority: 100}, task)
+}
+
+func TestHeapIndexesConsistent(t *testing.T) {
+	scheduler := NewScheduler()
+	r := rand.New(rand.NewSource(42))
+	for id := 0; id < 1000; id++ {
+		scheduler.AddTask(Task{Identifier: id, Priority: r.Intn(100)})
+	}
+	for i := 0; i < 1000; i++ {
+		scheduler.ChangeTaskPriority(r.Intn(1000), r.Intn(100))
+		if i%10 == 0 {
+			scheduler.GetTask()
+		}
+	}
+
+	for i, task := range *scheduler.tasks {
+		assert.Equal(t, i, task.index)
+		assert.Same(t, task, scheduler.taskMap[task.Identifier])
+	}
+	assert.Equal(t, scheduler.tasks.Len(), len(scheduler.taskMap))
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(duration time.Duration) {
+	c.now = c.now.Add(duration)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestAging(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now), WithAging(time.Second))
+
+	maintenance := Task{Identifier: 1, Priority: 1}
+	scheduler.AddTask(maintenance)
+
+	// constant high priority load, maintenance must eventually run
+	id := 2
+	for ; id < 100; id++ {
+		clock.Advance(time.Second)
+		scheduler.AddTask(Task{Identifier: id, Priority: 10})
+
+		if task := scheduler.GetTask(); task.Identifier == maintenance.Identifier {
+			break
+		}
+	}
+
+	// waited 9 seconds: 1 + 9 = 10, older task wins the tie
+	assert.Equal(t, 10, id)
+}
+
+func TestAgingSamePriorityFIFO(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now), WithAging(time.Second))
+
+	task1 := Task{Identifier: 1, Priority: 10}
+	task2 := Task{Identifier: 2, Priority: 10}
+	scheduler.AddTask(task1)
+	scheduler.AddTask(task2)
+	clock.Advance(time.Minute)
+
+	assert.Equal(t, task1, scheduler.GetTask())
+	assert.Equal(t, task2, scheduler.GetTask())
+}
+
+func TestAgingWithPriorityChange(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now), WithAging(time.Second))
+
+	task1 := Task{Identifier: 1, Priority: 1}
+	task2 := Task{Identifier: 2, Priority: 5}
+	scheduler.AddTask(task1)
+	clock.Advance(3 * time.Second)
+	scheduler.AddTask(task2)
+
+	// 2 + 3 waited seconds = 5, FIFO with task2
+	scheduler.ChangeTaskPriority(1, 2)
+	assert.Equal(t, task1, scheduler.GetTask())
+	assert.Equal(t, task2, scheduler.GetTask())
+}
+
+func TestWithoutAgingStarvation(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now))
+
+	scheduler.AddTask(Task{Identifier: 1, Priority: 1})
+	for id := 2; id < 100; id++ {
+		clock.Advance(time.Second)
+		scheduler.AddTask(Task{Identifier: id, Priority: 10})
+		assert.Equal(t, id, scheduler.GetTask().Identifier)
+	}
+}
+
+func TestEarliestDeadlineFirst(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now))
+
+	task1 := Task{Identifier: 1, Priority: 100}
+	task2 := Task{Identifier: 2, Priority: 1, Deadline: clock.Now().Add(time.Hour)}
+	task3 := Task{Identifier: 3, Priority: 10, Deadline: clock.Now().Add(time.Minute)}
+	task4 := Task{Identifier: 4, Priority: 20, Deadline: clock.Now().Add(time.Minute)}
+	scheduler.AddTask(task1)
+	scheduler.AddTask(task2)
+	scheduler.AddTask(task3)
+	scheduler.AddTask(task4)
+
+	assert.Equal(t, task4, scheduler.GetTask())
+	assert.Equal(t, task3, scheduler.GetTask())
+	assert.Equal(t, task2, scheduler.GetTask())
+	assert.Equal(t, task1, scheduler.GetTask())
+}
+
+func TestDeadlinesStarveWithoutLimit(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now), WithAging(time.Second))
+
+	scheduler.AddTask(Task{Identifier: 1, Priority: 1})
+	for id := 2; id < 100; id++ {
+		clock.Advance(time.Second)
+		scheduler.AddTask(Task{Identifier: id, Priority: 1, Deadline: clock.Now().Add(time.Minute)})
+		assert.Equal(t, id, scheduler.GetTask().Identifier)
+	}
+}
+
+func TestStarvationLimit(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now), WithAging(time.Second), WithStarvationLimit(10*time.Second))
+
+	maintenance := Task{Identifier: 1, Priority: 1}
+	scheduler.AddTask(maintenance)
+
+	// constant load of tasks with deadline
+	id := 2
+	for ; id < 100; id++ {
+		clock.Advance(time.Second)
+		scheduler.AddTask(Task{Identifier: id, Priority: 100, Deadline: clock.Now().Add(time.Minute)})
+
+		if task := scheduler.GetTask(); task.Identifier == maintenance.Identifier {
+			break
+		}
+	}
+	assert.Equal(t, 11, id)
+
+	// the task with deadline was not lost
+	assert.Equal(t, 11, scheduler.GetTask().Identifier)
+	assert.Equal(t, Task{}, scheduler.GetTask())
+}
+
+func TestAgingHugePriorities(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now), WithAging(time.Hour))
+
+	low := Task{Identifier: 1, Priority: math.MinInt}
+	high := Task{Identifier: 2, Priority: math.MaxInt}
+	middle := Task{Identifier: 3, Priority: 1}
+	scheduler.AddTask(low)
+	scheduler.AddTask(high)
+	clock.Advance(time.Hour)
+	scheduler.AddTask(middle)
+
+	assert.Equal(t, high, scheduler.GetTask())
+	assert.Equal(t, middle, scheduler.GetTask())
+	assert.Equal(t, low, scheduler.GetTask())
+}
+
+func TestDelayedTasks(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now))
+
+	task1 := Task{Identifier: 1, Priority: 100, NotBefore: clock.Now().Add(2 * time.Second)}
+	task2 := Task{Identifier: 2, Priority: 100, NotBefore: clock.Now().Add(time.Second)}
+	task3 := Task{Identifier: 3, Priority: 1}
+	scheduler.AddTask(task1)
+	scheduler.AddTask(task2)
+	scheduler.AddTask(task3)
+
+	assert.Equal(t, task3, scheduler.GetTask())
+	assert.Equal(t, Task{}, scheduler.GetTask())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, task2, scheduler.GetTask())
+	assert.Equal(t, Task{}, scheduler.GetTask())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, task1, scheduler.GetTask())
+}
+
+func TestChangePriorityOfDelayedTask(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(WithClock(clock.Now))
+
+	task1 := Task{Identifier: 1, Priority: 1, NotBefore: clock.Now().Add(time.Second)}
+	task2 := Task{Identifier: 2, Priority: 10}
+	scheduler.AddTask(task1)
+	scheduler.AddTask(task2)
+
+	scheduler.ChangeTaskPriority(1, 20)
+	clock.Advance(time.Second)
+
+	assert.Equal(t, task1, scheduler.GetTask())
+	assert.Equal(t, task2, scheduler.GetTask())
+}
+
+// go test -bench=. -benchmem
+
+const benchmarkTasksNumber = 1_000_000
+
+func newBenchmarkScheduler(r *rand.Rand) Scheduler {
+	scheduler := NewScheduler()
+	for id := 0; id < benchmarkTasksNumber; id++ {
+		scheduler.AddTask(Task{Identifier: id, Priority: r.Intn(benchmarkTasksNumber)})
+	}
+
+	return scheduler
+}
+
+func BenchmarkChangeTaskPriority(b *testing.B) {
+	r := rand.New(rand.NewSource(42))
+	scheduler := newBenchmarkScheduler(r)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		scheduler.ChangeTaskPriority(r.Intn(benchmarkTasksNumber), r.Intn(benchmarkTasksNumber))
+	}
+}
+
+func BenchmarkGetAndAddTask(b *testing.B) {
+	r := rand.New(rand.NewSource(42))
+	scheduler := newBenchmarkScheduler(r)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		task := scheduler.GetTask()
+		scheduler.AddTask(Task{Identifier: task.Identifier, Priority: r.Intn(benchmarkTasksNumber)})
+	}
 }
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v bimap_test.go
+
+var ErrAlreadyBound = errors.New("already bound")
+
+// BiMap is a one-to-one map, values are found by keys and keys by
+// values. Both directions are changed together, so they always agree.
+type BiMap[K comparable, V comparable] struct {
+	forward  map[K]V
+	backward map[V]K
+}
+
+func NewBiMap[K comparable, V comparable]() *BiMap[K, V] {
+	return &BiMap[K, V]{
+		forward:  make(map[K]V),
+		backward: make(map[V]K),
+	}
+}
+
+// Put binds the key to the value, the old value of the key is released.
+// It fails when the value is bound to another key.
+func (m *BiMap[K, V]) Put(key K, value V) error {
+	if bound, found := m.backward[value]; found && bound != key {
+		return fmt.Errorf("%w: value %v is bound to key %v", ErrAlreadyBound, value, bound)
+	}
+
+	m.ForcePut(key, value)
+	return nil
+}
+
+// ForcePut binds the key to the value and releases
+// the old value of the key and the old key of the value
+func (m *BiMap[K, V]) ForcePut(key K, value V) {
+	m.DeleteKey(key)
+	m.DeleteValue(value)
+	m.forward[key] = value
+	m.backward[value] = key
+}
+
+func (m *BiMap[K, V]) GetValue(key K) (V, bool) {
+	value, found := m.forward[key]
+	return value, found
+}
+
+func (m *BiMap[K, V]) GetKey(value V) (K, bool) {
+	key, found := m.backward[value]
+	return key, found
+}
+
+func (m *BiMap[K, V]) ContainsKey(key K) bool {
+	_, found := m.forward[key]
+	return found
+}
+
+func (m *BiMap[K, V]) ContainsValue(value V) bool {
+	_, found := m.backward[value]
+	return found
+}
+
+func (m *BiMap[K, V]) DeleteKey(key K) bool {
+	value, found := m.forward[key]
+	if !found {
+		return false
+	}
+
+	delete(m.forward, key)
+	delete(m.backward, value)
+	return true
+}
+
+func (m *BiMap[K, V]) DeleteValue(value V) bool {
+	key, found := m.backward[value]
+	if !found {
+		return false
+	}
+
+	delete(m.backward, value)
+	delete(m.forward, key)
+	return true
+}
+
+func (m *BiMap[K, V]) Len() int {
+	return len(m.forward)
+}
+
+func (m *BiMap[K, V]) All() iter.Seq2[K, V] {
+	return maps.All(m.forward)
+}
+
+func (m *BiMap[K, V]) Keys() iter.Seq[K] {
+	return maps.Keys(m.forward)
+}
+
+func (m *BiMap[K, V]) Values() iter.Seq[V] {
+	return maps.Keys(m.backward)
+}
+
+// Inverse shares data with m, so changes of one are seen in the other
+func (m *BiMap[K, V]) Inverse() *BiMap[V, K] {
+	return &BiMap[V, K]{forward: m.backward, backward: m.forward}
+}
+
+// Union returns pairs of both maps, it fails when they bind
+// a key or a value differently
+func (m *BiMap[K, V]) Union(other *BiMap[K, V]) (*BiMap[K, V], error) {
+	result := m.Clone()
+	for key, value := range other.All() {
+		if bound, found := result.forward[key]; found && bound != value {
+			return nil, fmt.Errorf("%w: key %v is bound to values %v and %v", ErrAlreadyBound, key, bound, value)
+		}
+
+		if err := result.Put(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// Intersection returns pairs which are in both maps
+func (m *BiMap[K, V]) Intersection(other *BiMap[K, V]) *BiMap[K, V] {
+	result := NewBiMap[K, V]()
+	for key, value := range m.All() {
+		if bound, found := other.forward[key]; found && bound == value {
+			result.ForcePut(key, value)
+		}
+	}
+
+	return result
+}
+
+// Difference returns pairs of m which are not in other
+func (m *BiMap[K, V]) Difference(other *BiMap[K, V]) *BiMap[K, V] {
+	result := NewBiMap[K, V]()
+	for key, value := range m.All() {
+		if bound, found := other.forward[key]; !found || bound != value {
+			result.ForcePut(key, value)
+		}
+	}
+
+	return result
+}
+
+func (m *BiMap[K, V]) Clone() *BiMap[K, V] {
+	return &BiMap[K, V]{
+		forward:  maps.Clone(m.forward),
+		backward: maps.Clone(m.backward),
+	}
+}
+
+// checkBiMap verifies that both directions agree
+func checkBiMap[K comparable, V comparable](t *testing.T, m *BiMap[K, V]) {
+	t.Helper()
+	require.Equal(t, len(m.forward), len(m.backward))
+	for key, value := range m.forward {
+		require.Equal(t, key, m.backward[value])
+	}
+}
+
+func TestBiMap(t *testing.T) {
+	tags := NewBiMap[string, int]()
+	require.NoError(t, tags.Put("go", 1))
+	require.NoError(t, tags.Put("rust", 2))
+	require.NoError(t, tags.Put("go", 1)) // the same pair again
+
+	id, found := tags.GetValue("go")
+	assert.True(t, found)
+	assert.Equal(t, 1, id)
+	name, found := tags.GetKey(2)
+	assert.True(t, found)
+	assert.Equal(t, "rust", name)
+
+	err := tags.Put("zig", 1)
+	assert.ErrorIs(t, err, ErrAlreadyBound)
+	assert.False(t, tags.ContainsKey("zig"))
+
+	// a new value releases the old one
+	require.NoError(t, tags.Put("go", 3))
+	assert.False(t, tags.ContainsValue(1))
+	checkBiMap(t, tags)
+
+	tags.ForcePut("zig", 3) // takes the value from go
+	assert.False(t, tags.ContainsKey("go"))
+	name, _ = tags.GetKey(3)
+	assert.Equal(t, "zig", name)
+	checkBiMap(t, tags)
+
+	assert.True(t, tags.DeleteValue(2))
+	assert.False(t, tags.ContainsKey("rust"))
+	assert.True(t, tags.DeleteKey("zig"))
+	assert.False(t, tags.DeleteKey("zig"))
+	assert.Zero(t, tags.Len())
+	checkBiMap(t, tags)
+}
+
+func TestBiMapInverse(t *testing.T) {
+	tags := NewBiMap[string, int]()
+	tags.ForcePut("go", 1)
+	ids := tags.Inverse()
+
+	name, found := ids.GetValue(1)
+	assert.True(t, found)
+	assert.Equal(t, "go", name)
+
+	ids.ForcePut(2, "rust")
+	id, _ := tags.GetValue("rust")
+	assert.Equal(t, 2, id)
+	assert.Equal(t, map[string]int{"go": 1, "rust": 2}, maps.Collect(tags.All()))
+	assert.ElementsMatch(t, []int{1, 2}, slices.Collect(ids.Keys()))
+	checkBiMap(t, tags)
+	checkBiMap(t, ids)
+}
+
+func TestBiMapSetOperations(t *testing.T) {
+	a := NewBiMap[string, int]()
+	a.ForcePut("go", 1)
+	a.ForcePut("rust", 2)
+	b := NewBiMap[string, int]()
+	b.ForcePut("go", 1)
+	b.ForcePut("zig", 3)
+
+	union, err := a.Union(b)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"go": 1, "rust": 2, "zig": 3}, maps.Collect(union.All()))
+	checkBiMap(t, union)
+	assert.Equal(t, map[string]int{"go": 1}, maps.Collect(a.Intersection(b).All()))
+	assert.Equal(t, map[string]int{"rust": 2}, maps.Collect(a.Difference(b).All()))
+
+	conflicting := NewBiMap[string, int]()
+	conflicting.ForcePut("go", 2) // go is 1 in a
+	_, err = a.Union(conflicting)
+	assert.ErrorIs(t, err, ErrAlreadyBound)
+
+	conflicting = NewBiMap[string, int]()
+	conflicting.ForcePut("c", 2) // 2 is rust in a
+	_, err = a.Union(conflicting)
+	assert.ErrorIs(t, err, ErrAlreadyBound)
+	assert.Equal(t, 2, a.Len())
+}
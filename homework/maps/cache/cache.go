@@ -0,0 +1,368 @@
+// Package cache contains bounded generic caches: LRU evicts the least
+// recently used entries, LFU the least frequently used ones and TTL
+// the oldest ones, which also expire after a fixed time. Capacity is
+// the number of entries or the total cost given by WithCost.
+//
+// Caches are not safe for concurrent use, wrap them with Synchronized,
+// or with SynchronizedLFU and SynchronizedTTL to keep their own methods.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache is implemented by all caches of the package
+type Cache[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Put(key K, value V)
+	Delete(key K) bool
+	Len() int
+	Cost() int64 // total cost of stored entries
+	Stats() Stats
+}
+
+// Reason tells why an entry left the cache
+type Reason int
+
+const (
+	ReasonCapacity Reason = iota // evicted to fit a new entry
+	ReasonExpired                // lived longer than TTL
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+// Stats counts cache operations since creation
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+}
+
+// HitRatio returns the part of Get calls which found the key
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type config[K comparable, V any] struct {
+	cost    func(K, V) int64
+	onEvict func(K, V, Reason)
+	now     func() time.Time
+}
+
+type Option[K comparable, V any] func(*config[K, V])
+
+// WithCost makes capacity limit the total cost of entries
+// instead of their number
+func WithCost[K comparable, V any](cost func(key K, value V) int64) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.cost = cost
+	}
+}
+
+// WithEvictionCallback calls onEvict for entries removed because of
+// capacity or expiration and for entries which cost more than the
+// whole capacity, but not for deleted or replaced ones.
+// onEvict must not use the cache.
+func WithEvictionCallback[K comparable, V any](onEvict func(key K, value V, reason Reason)) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.onEvict = onEvict
+	}
+}
+
+// WithClock replaces time.Now for TTL caches
+func WithClock[K comparable, V any](now func() time.Time) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.now = now
+	}
+}
+
+// store keeps entries and their total cost,
+// caches link entries in their own order
+type store[K comparable, V any] struct {
+	config[K, V]
+	entries  map[K]*entry[K, V]
+	capacity int64
+	used     int64
+	stats    Stats
+}
+
+func newStore[K comparable, V any](capacity int64, options []Option[K, V]) store[K, V] {
+	s := store[K, V]{
+		config: config[K, V]{
+			cost: func(K, V) int64 { return 1 },
+			now:  time.Now,
+		},
+		entries:  make(map[K]*entry[K, V]),
+		capacity: capacity,
+	}
+
+	for _, option := range options {
+		option(&s.config)
+	}
+
+	return s
+}
+
+func (s *store[K, V]) lookup(key K) (*entry[K, V], bool) {
+	e, found := s.entries[key]
+	s.count(found)
+	return e, found
+}
+
+func (s *store[K, V]) count(hit bool) {
+	if hit {
+		s.stats.Hits++
+	} else {
+		s.stats.Misses++
+	}
+}
+
+func (s *store[K, V]) newEntry(key K, value V) *entry[K, V] {
+	return &entry[K, V]{key: key, value: value, cost: s.cost(key, value)}
+}
+
+// fits reports whether the entry can be stored at all
+func (s *store[K, V]) fits(e *entry[K, V]) bool {
+	return e.cost <= s.capacity
+}
+
+// full reports whether entries have to be evicted to store the entry
+func (s *store[K, V]) full(e *entry[K, V]) bool {
+	return s.used+e.cost > s.capacity
+}
+
+func (s *store[K, V]) insert(e *entry[K, V]) {
+	s.entries[e.key] = e
+	s.used += e.cost
+}
+
+func (s *store[K, V]) remove(e *entry[K, V]) {
+	delete(s.entries, e.key)
+	s.used -= e.cost
+}
+
+func (s *store[K, V]) evict(e *entry[K, V], reason Reason) {
+	s.remove(e)
+	s.discard(e, reason)
+}
+
+// discard reports the entry which left the cache
+// or did not fit into it
+func (s *store[K, V]) discard(e *entry[K, V], reason Reason) {
+	if reason == ReasonExpired {
+		s.stats.Expirations++
+	} else {
+		s.stats.Evictions++
+	}
+
+	if s.onEvict != nil {
+		s.onEvict(e.key, e.value, reason)
+	}
+}
+
+func (s *store[K, V]) Len() int {
+	return len(s.entries)
+}
+
+func (s *store[K, V]) Cost() int64 {
+	return s.used
+}
+
+func (s *store[K, V]) Stats() Stats {
+	return s.stats
+}
+
+// entry is a node of an intrusive doubly linked list
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	cost      int64
+	frequency int       // used by LFU
+	expires   time.Time // used by TTL
+
+	prev, next *entry[K, V]
+}
+
+// list is circular with a sentinel, so links are never nil
+type list[K comparable, V any] struct {
+	root entry[K, V]
+	size int
+}
+
+func (l *list[K, V]) init() *list[K, V] {
+	l.root.prev = &l.root
+	l.root.next = &l.root
+	return l
+}
+
+func (l *list[K, V]) empty() bool {
+	return l.size == 0
+}
+
+func (l *list[K, V]) front() *entry[K, V] {
+	if l.empty() {
+		return nil
+	}
+
+	return l.root.next
+}
+
+func (l *list[K, V]) back() *entry[K, V] {
+	if l.empty() {
+		return nil
+	}
+
+	return l.root.prev
+}
+
+func (l *list[K, V]) pushFront(e *entry[K, V]) {
+	l.insertAfter(e, &l.root)
+}
+
+func (l *list[K, V]) pushBack(e *entry[K, V]) {
+	l.insertAfter(e, l.root.prev)
+}
+
+func (l *list[K, V]) insertAfter(e, at *entry[K, V]) {
+	e.prev = at
+	e.next = at.next
+	at.next.prev = e
+	at.next = e
+	l.size++
+}
+
+func (l *list[K, V]) unlink(e *entry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev = nil
+	e.next = nil
+	l.size--
+}
+
+func (l *list[K, V]) moveToFront(e *entry[K, V]) {
+	if l.root.next == e {
+		return
+	}
+
+	l.unlink(e)
+	l.pushFront(e)
+}
+
+// synchronized guards every call with one mutex, because
+// even Get changes the order of entries
+type synchronized[K comparable, V any] struct {
+	mutex sync.Mutex
+	cache Cache[K, V]
+}
+
+// Synchronized makes cache safe for concurrent use
+func Synchronized[K comparable, V any](cache Cache[K, V]) Cache[K, V] {
+	return &synchronized[K, V]{cache: cache}
+}
+
+func (s *synchronized[K, V]) Get(key K) (V, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.cache.Get(key)
+}
+
+func (s *synchronized[K, V]) Put(key K, value V) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.cache.Put(key, value)
+}
+
+func (s *synchronized[K, V]) Delete(key K) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.cache.Delete(key)
+}
+
+func (s *synchronized[K, V]) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.cache.Len()
+}
+
+func (s *synchronized[K, V]) Cost() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.cache.Cost()
+}
+
+func (s *synchronized[K, V]) Stats() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.cache.Stats()
+}
+
+// SyncLFU is LFU safe for concurrent use
+type SyncLFU[K comparable, V any] struct {
+	synchronized[K, V]
+	lfu *LFU[K, V]
+}
+
+func SynchronizedLFU[K comparable, V any](cache *LFU[K, V]) *SyncLFU[K, V] {
+	return &SyncLFU[K, V]{synchronized: synchronized[K, V]{cache: cache}, lfu: cache}
+}
+
+func (s *SyncLFU[K, V]) Frequency(key K) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lfu.Frequency(key)
+}
+
+// SyncTTL is TTL safe for concurrent use, RemoveExpired
+// can be called by a background goroutine
+type SyncTTL[K comparable, V any] struct {
+	synchronized[K, V]
+	ttl *TTL[K, V]
+}
+
+func SynchronizedTTL[K comparable, V any](cache *TTL[K, V]) *SyncTTL[K, V] {
+	return &SyncTTL[K, V]{synchronized: synchronized[K, V]{cache: cache}, ttl: cache}
+}
+
+func (s *SyncTTL[K, V]) RemoveExpired() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.ttl.RemoveExpired()
+}
+
+// Memoize returns function which keeps results of fn in the cache,
+// so memory is bounded by the cache capacity. Recursive functions
+// have to call the result to reuse cached values.
+func Memoize[K comparable, V any](cache Cache[K, V], fn func(K) V) func(K) V {
+	return func(key K) V {
+		if value, found := cache.Get(key); found {
+			return value
+		}
+
+		value := fn(key)
+		cache.Put(key, value)
+		return value
+	}
+}
@@ -0,0 +1,328 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -race -bench=. ./...
+
+type eviction struct {
+	key    string
+	value  int
+	reason Reason
+}
+
+type evictions []eviction
+
+func (e *evictions) record(key string, value int, reason Reason) {
+	*e = append(*e, eviction{key: key, value: value, reason: reason})
+}
+
+func keysOf(c Cache[string, int], keys ...string) []string {
+	var found []string
+	for _, key := range keys {
+		if _, ok := c.Get(key); ok {
+			found = append(found, key)
+		}
+	}
+
+	return found
+}
+
+func TestLRU(t *testing.T) {
+	var evicted evictions
+	c := NewLRU(2, WithEvictionCallback(evicted.record))
+
+	c.Put("a", 1)
+	c.Put("b", 2)
+	value, found := c.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, 1, value)
+
+	c.Put("c", 3) // b is the least recently used
+	assert.Equal(t, evictions{{key: "b", value: 2, reason: ReasonCapacity}}, evicted)
+	_, found = c.Get("b")
+	assert.False(t, found)
+
+	c.Put("a", 10) // replacing is not an eviction
+	assert.Len(t, evicted, 1)
+	value, _ = c.Get("a")
+	assert.Equal(t, 10, value)
+	assert.Equal(t, 2, c.Len())
+
+	assert.True(t, c.Delete("a"))
+	assert.False(t, c.Delete("a"))
+	assert.Equal(t, 1, c.Len())
+	assert.Len(t, evicted, 1)
+
+	assert.Equal(t, Stats{Hits: 2, Misses: 1, Evictions: 1}, c.Stats())
+	assert.InDelta(t, 2.0/3, c.Stats().HitRatio(), 1e-9)
+}
+
+func TestLRUCost(t *testing.T) {
+	var evicted evictions
+	c := NewLRU(10,
+		WithCost(func(_ string, value int) int64 { return int64(value) }),
+		WithEvictionCallback(evicted.record),
+	)
+
+	c.Put("a", 4)
+	c.Put("b", 4)
+	c.Put("c", 2)
+	assert.Equal(t, int64(10), c.Cost())
+
+	c.Get("a")
+	c.Put("d", 5) // b and c are older than a
+	assert.Equal(t, []string{"a", "d"}, keysOf(c, "a", "b", "c", "d"))
+	assert.Equal(t, int64(9), c.Cost())
+
+	c.Put("e", 11) // costs more than the whole cache
+	assert.Equal(t, []string{"a", "d"}, keysOf(c, "a", "d", "e"))
+	assert.Equal(t, evictions{
+		{key: "b", value: 4, reason: ReasonCapacity},
+		{key: "c", value: 2, reason: ReasonCapacity},
+		{key: "e", value: 11, reason: ReasonCapacity},
+	}, evicted)
+
+	c.Put("a", 10) // growing entry pushes out others
+	assert.Equal(t, []string{"a"}, keysOf(c, "a", "d"))
+	assert.Equal(t, int64(10), c.Cost())
+}
+
+func TestLFU(t *testing.T) {
+	var evicted evictions
+	c := NewLFU(3, WithEvictionCallback(evicted.record))
+
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("c", 3)
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	assert.Equal(t, 3, c.Frequency("a"))
+	assert.Equal(t, 2, c.Frequency("b"))
+	assert.Equal(t, 1, c.Frequency("c"))
+
+	c.Put("d", 4) // c is the least frequently used
+	assert.Equal(t, evictions{{key: "c", value: 3, reason: ReasonCapacity}}, evicted)
+
+	c.Get("d") // b and d are used twice, b earlier
+	c.Put("e", 5)
+	assert.Equal(t, "b", evicted[1].key)
+
+	c.Put("e", 50) // replacing is a use
+	assert.Equal(t, 2, c.Frequency("e"))
+	assert.Zero(t, c.Frequency("b"))
+
+	assert.True(t, c.Delete("a"))
+	assert.Equal(t, 2, c.Len())
+	c.Put("f", 6)
+	c.Put("g", 7) // the minimal frequency is found after deletes
+	assert.Equal(t, "f", evicted[2].key)
+	assert.Equal(t, []string{"d", "e", "g"}, keysOf(c, "a", "b", "c", "d", "e", "f", "g"))
+}
+
+func TestLFUCost(t *testing.T) {
+	c := NewLFU(10, WithCost(func(_ string, value int) int64 { return int64(value) }))
+	c.Put("a", 5)
+	c.Put("b", 5)
+	c.Get("a")
+	c.Get("b")
+	c.Get("b")
+
+	c.Put("c", 6) // a has fewer uses than b, but both have to go
+	assert.Equal(t, 1, c.Len())
+	assert.Equal(t, int64(6), c.Cost())
+	assert.Equal(t, Stats{Hits: 3, Evictions: 2}, c.Stats())
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(duration time.Duration) {
+	c.now = c.now.Add(duration)
+}
+
+func TestTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var evicted evictions
+	c := NewTTL(3, time.Minute,
+		WithClock[string, int](clock.Now),
+		WithEvictionCallback(evicted.record),
+	)
+
+	c.Put("a", 1)
+	clock.Advance(30 * time.Second)
+	c.Put("b", 2)
+	value, found := c.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, 1, value)
+
+	clock.Advance(30 * time.Second) // Get does not extend the lifetime
+	_, found = c.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, evictions{{key: "a", value: 1, reason: ReasonExpired}}, evicted)
+
+	c.Put("c", 3)
+	c.Put("d", 4)
+	c.Put("e", 5) // b is the oldest
+	assert.Equal(t, eviction{key: "b", value: 2, reason: ReasonCapacity}, evicted[1])
+
+	c.Put("c", 30) // replacing restarts the lifetime
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, 3, c.Len())
+	assert.Zero(t, c.RemoveExpired())
+	clock.Advance(time.Second)
+	assert.Equal(t, 3, c.Len()) // expired entries wait for removal
+	assert.Equal(t, 3, c.RemoveExpired())
+	assert.Zero(t, c.Len())
+	assert.Zero(t, c.Cost())
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Evictions: 1, Expirations: 4}, c.Stats())
+}
+
+func TestTTLPutRemovesExpired(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var evicted evictions
+	c := NewTTL(2, time.Second, WithClock[string, int](clock.Now), WithEvictionCallback(evicted.record))
+
+	c.Put("a", 1)
+	c.Put("b", 2)
+	clock.Advance(time.Second)
+	c.Put("c", 3) // expired entries free the space first
+	require.Len(t, evicted, 2)
+	assert.Equal(t, ReasonExpired, evicted[0].reason)
+	assert.Equal(t, ReasonExpired, evicted[1].reason)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestSynchronized(t *testing.T) {
+	const goroutines, operations = 8, 1_000
+	caches := map[string]Cache[string, int]{
+		"lru": Synchronized[string, int](NewLRU[string, int](100)),
+		"lfu": Synchronized[string, int](NewLFU[string, int](100)),
+		"ttl": Synchronized[string, int](NewTTL[string, int](100, time.Hour)),
+	}
+
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			wg.Add(goroutines)
+			for g := range goroutines {
+				go func() {
+					defer wg.Done()
+					for i := range operations {
+						key := fmt.Sprint((g*operations + i) % 300)
+						if _, found := c.Get(key); !found {
+							c.Put(key, i)
+						}
+						if i%10 == 0 {
+							c.Delete(key)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			assert.LessOrEqual(t, c.Len(), 100)
+			assert.Equal(t, int64(c.Len()), c.Cost())
+			stats := c.Stats()
+			assert.Equal(t, uint64(goroutines*operations), stats.Hits+stats.Misses)
+		})
+	}
+}
+
+func TestSynchronizedKeepsMethods(t *testing.T) {
+	lfu := SynchronizedLFU(NewLFU[string, int](10))
+	lfu.Put("a", 1)
+	lfu.Get("a")
+	assert.Equal(t, 2, lfu.Frequency("a"))
+	assert.Zero(t, lfu.Frequency("b"))
+
+	const goroutines, operations = 4, 1_000
+	ttl := SynchronizedTTL(NewTTL[int, int](100, time.Millisecond))
+	var wg sync.WaitGroup
+	wg.Add(goroutines + 1)
+	for g := range goroutines {
+		go func() {
+			defer wg.Done()
+			for i := range operations {
+				ttl.Put(g*operations+i, i)
+			}
+		}()
+	}
+	go func() { // a janitor
+		defer wg.Done()
+		for range operations {
+			ttl.RemoveExpired()
+		}
+	}()
+	wg.Wait()
+
+	ttl.Put(-1, 0)
+	time.Sleep(2 * time.Millisecond)
+	removed := ttl.RemoveExpired()
+	assert.Positive(t, removed)
+	assert.Zero(t, ttl.Len())
+	assert.LessOrEqual(t, uint64(removed), ttl.Stats().Expirations)
+}
+
+func TestMemoize(t *testing.T) {
+	calls := 0
+	c := NewLRU[int, uint64](3)
+
+	var fibonacci func(int) uint64
+	fibonacci = Memoize(c, func(number int) uint64 {
+		calls++
+		if number <= 2 {
+			return 1
+		}
+
+		return fibonacci(number-1) + fibonacci(number-2)
+	})
+
+	assert.Equal(t, uint64(12586269025), fibonacci(50))
+	assert.Equal(t, 50, calls) // every number is computed once
+	assert.Equal(t, 3, c.Len())
+
+	assert.Equal(t, uint64(12586269025), fibonacci(50))
+	assert.Equal(t, 50, calls)
+}
+
+func BenchmarkCaches(b *testing.B) {
+	const capacity, keys = 1_000, 2_000
+	caches := map[string]func() Cache[int, int]{
+		"lru": func() Cache[int, int] { return NewLRU[int, int](capacity) },
+		"lfu": func() Cache[int, int] { return NewLFU[int, int](capacity) },
+		"ttl": func() Cache[int, int] { return NewTTL[int, int](capacity, time.Hour) },
+	}
+
+	for name, newCache := range caches {
+		b.Run(name, func(b *testing.B) {
+			c := newCache()
+			for i := 0; b.Loop(); i++ {
+				// half of the requests go to hot keys, the rest are
+				// spread over twice more keys than the cache holds
+				key := (i * 7919) % keys
+				if i%2 == 0 {
+					key %= capacity / 10
+				}
+				if _, found := c.Get(key); !found {
+					c.Put(key, i)
+				}
+			}
+			b.ReportMetric(c.Stats().HitRatio(), "hits/op")
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package cache
+
+// LFU evicts the entry which was used the least number of times,
+// the least recently used one among equally used entries
+type LFU[K comparable, V any] struct {
+	store[K, V]
+	frequencies  map[int]*list[K, V] // entries by number of uses, most recently used first
+	minFrequency int                 // not greater than any frequency in use
+}
+
+func NewLFU[K comparable, V any](capacity int64, options ...Option[K, V]) *LFU[K, V] {
+	return &LFU[K, V]{
+		store:       newStore(capacity, options),
+		frequencies: make(map[int]*list[K, V]),
+	}
+}
+
+func (c *LFU[K, V]) Get(key K) (V, bool) {
+	e, found := c.lookup(key)
+	if !found {
+		var empty V
+		return empty, false
+	}
+
+	c.unlink(e)
+	e.frequency++
+	c.link(e)
+	return e.value, true
+}
+
+// Put counts as a use when it replaces a value
+func (c *LFU[K, V]) Put(key K, value V) {
+	e := c.newEntry(key, value)
+	e.frequency = 1
+	if old, found := c.entries[key]; found {
+		e.frequency = old.frequency + 1
+		c.unlink(old)
+		c.remove(old)
+	}
+
+	if !c.fits(e) {
+		c.discard(e, ReasonCapacity)
+		return
+	}
+
+	for c.full(e) {
+		victim := c.victim()
+		c.unlink(victim)
+		c.evict(victim, ReasonCapacity)
+	}
+
+	c.insert(e)
+	c.link(e)
+}
+
+func (c *LFU[K, V]) Delete(key K) bool {
+	e, found := c.entries[key]
+	if !found {
+		return false
+	}
+
+	c.unlink(e)
+	c.remove(e)
+	return true
+}
+
+// Frequency returns the number of uses of the key
+func (c *LFU[K, V]) Frequency(key K) int {
+	if e, found := c.entries[key]; found {
+		return e.frequency
+	}
+
+	return 0
+}
+
+func (c *LFU[K, V]) link(e *entry[K, V]) {
+	entries, found := c.frequencies[e.frequency]
+	if !found {
+		entries = new(list[K, V]).init()
+		c.frequencies[e.frequency] = entries
+	}
+
+	entries.pushFront(e)
+	if e.frequency < c.minFrequency || len(c.frequencies) == 1 {
+		c.minFrequency = e.frequency
+	}
+}
+
+// unlink leaves minFrequency as is, victim finds the new one
+// only when it is needed
+func (c *LFU[K, V]) unlink(e *entry[K, V]) {
+	entries := c.frequencies[e.frequency]
+	entries.unlink(e)
+	if entries.empty() {
+		delete(c.frequencies, e.frequency)
+	}
+}
+
+func (c *LFU[K, V]) victim() *entry[K, V] {
+	entries, found := c.frequencies[c.minFrequency]
+	if !found {
+		c.minFrequency = 0
+		for frequency := range c.frequencies {
+			if c.minFrequency == 0 || frequency < c.minFrequency {
+				c.minFrequency = frequency
+			}
+		}
+		entries = c.frequencies[c.minFrequency]
+	}
+
+	return entries.back()
+}
@@ -0,0 +1,54 @@
+package cache
+
+// LRU evicts the entry which was not used for the longest time
+type LRU[K comparable, V any] struct {
+	store[K, V]
+	order list[K, V] // most recently used first
+}
+
+func NewLRU[K comparable, V any](capacity int64, options ...Option[K, V]) *LRU[K, V] {
+	c := &LRU[K, V]{store: newStore(capacity, options)}
+	c.order.init()
+	return c
+}
+
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	e, found := c.lookup(key)
+	if !found {
+		var empty V
+		return empty, false
+	}
+
+	c.order.moveToFront(e)
+	return e.value, true
+}
+
+func (c *LRU[K, V]) Put(key K, value V) {
+	c.Delete(key)
+
+	e := c.newEntry(key, value)
+	if !c.fits(e) {
+		c.discard(e, ReasonCapacity)
+		return
+	}
+
+	for c.full(e) {
+		victim := c.order.back()
+		c.order.unlink(victim)
+		c.evict(victim, ReasonCapacity)
+	}
+
+	c.insert(e)
+	c.order.pushFront(e)
+}
+
+func (c *LRU[K, V]) Delete(key K) bool {
+	e, found := c.entries[key]
+	if !found {
+		return false
+	}
+
+	c.order.unlink(e)
+	c.remove(e)
+	return true
+}
@@ -0,0 +1,87 @@
+package cache
+
+import "time"
+
+// TTL removes entries a fixed time after they were put and evicts
+// the oldest ones first when it is full. Expired entries are removed
+// by Get, Put and RemoveExpired, so Len and Cost include expired
+// entries which were not removed yet.
+type TTL[K comparable, V any] struct {
+	store[K, V]
+	ttl   time.Duration
+	order list[K, V] // the first to expire first
+}
+
+func NewTTL[K comparable, V any](capacity int64, ttl time.Duration, options ...Option[K, V]) *TTL[K, V] {
+	c := &TTL[K, V]{store: newStore(capacity, options), ttl: ttl}
+	c.order.init()
+	return c
+}
+
+// Get does not extend the lifetime of the entry
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	e, found := c.entries[key]
+	if found && c.expired(e, c.now()) {
+		c.order.unlink(e)
+		c.evict(e, ReasonExpired)
+		found = false
+	}
+
+	c.count(found)
+	if !found {
+		var empty V
+		return empty, false
+	}
+
+	return e.value, true
+}
+
+func (c *TTL[K, V]) Put(key K, value V) {
+	c.Delete(key)
+	c.RemoveExpired()
+
+	e := c.newEntry(key, value)
+	if !c.fits(e) {
+		c.discard(e, ReasonCapacity)
+		return
+	}
+
+	for c.full(e) {
+		victim := c.order.front()
+		c.order.unlink(victim)
+		c.evict(victim, ReasonCapacity)
+	}
+
+	e.expires = c.now().Add(c.ttl)
+	c.insert(e)
+	c.order.pushBack(e)
+}
+
+func (c *TTL[K, V]) Delete(key K) bool {
+	e, found := c.entries[key]
+	if !found {
+		return false
+	}
+
+	c.order.unlink(e)
+	c.remove(e)
+	return true
+}
+
+// RemoveExpired returns the number of removed entries. Entries are
+// ordered by expiration, so it stops at the first live entry.
+func (c *TTL[K, V]) RemoveExpired() int {
+	now := c.now()
+	removed := 0
+	for e := c.order.front(); e != nil && c.expired(e, now); e = c.order.front() {
+		c.order.unlink(e)
+		c.evict(e, ReasonExpired)
+		removed++
+	}
+
+	return removed
+}
+
+func (c *TTL[K, V]) expired(e *entry[K, V], now time.Time) bool {
+	return !now.Before(e.expires)
+}
@@ -1,203 +1,757 @@
 package main
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"iter"
+	"math/rand"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // go test -v homework_test.go
-type node[K comparable, V any] struct {
-	key  K
-	data *V
-	next *node[K, V]
-	prev *node[K, V]
+
+type color bool
+
+const (
+	red   color = false
+	black color = true
+)
+
+// node of a red-black tree: a red node has only black children and
+// every path from a node down to leaves has the same number of black
+// nodes, so the height is at most 2*log(n+1)
+type node[K any, V any] struct {
+	key    K
+	value  V
+	left   *node[K, V]
+	right  *node[K, V]
+	parent *node[K, V]
+	color  color
+	count  int // nodes in the subtree for rank and select
+}
+
+func isRed[K any, V any](n *node[K, V]) bool {
+	return n != nil && n.color == red
 }
 
-func (n *node[K, V]) fetchNode(key K, comparator func(a, b K) bool) *node[K, V] {
-	if n.key == key {
-		return n
+func count[K any, V any](n *node[K, V]) int {
+	if n == nil {
+		return 0
 	}
-	if comparator(n.key, key) {
-		if n.prev == nil {
-			n.prev = &node[K, V]{key: key, next: n}
-			return n.prev
+
+	return n.count
+}
+
+func (n *node[K, V]) updateCount() {
+	n.count = 1 + count(n.left) + count(n.right)
+}
+
+func (n *node[K, V]) minimum() *node[K, V] {
+	for n.left != nil {
+		n = n.left
+	}
+
+	return n
+}
+
+func (n *node[K, V]) maximum() *node[K, V] {
+	for n.right != nil {
+		n = n.right
+	}
+
+	return n
+}
+
+// successor returns the next node in order or nil
+func (n *node[K, V]) successor() *node[K, V] {
+	if n.right != nil {
+		return n.right.minimum()
+	}
+
+	for n.parent != nil && n == n.parent.right {
+		n = n.parent
+	}
+
+	return n.parent
+}
+
+// predecessor returns the previous node in order or nil
+func (n *node[K, V]) predecessor() *node[K, V] {
+	if n.left != nil {
+		return n.left.maximum()
+	}
+
+	for n.parent != nil && n == n.parent.left {
+		n = n.parent
+	}
+
+	return n.parent
+}
+
+var ErrInvalidComparator = errors.New("comparator is not a strict weak ordering")
+
+// OrderedMap keeps keys sorted in a red-black tree, all operations
+// are iterative, so big maps do not overflow the stack. Keys are equal
+// when the comparator says so, they are never compared with ==.
+type OrderedMap[K any, V any] struct {
+	root       *node[K, V]
+	size       int
+	compare    func(a, b K) int
+	validating bool
+}
+
+type orderedMapConfig struct {
+	validate bool
+}
+
+type OrderedMapOption func(*orderedMapConfig)
+
+// WithComparatorValidation checks on every comparison that the comparator
+// is consistent and on every insertion that neighbours are ordered.
+// It makes operations several times slower, so it is for tests and debugging.
+func WithComparatorValidation() OrderedMapOption {
+	return func(config *orderedMapConfig) {
+		config.validate = true
+	}
+}
+
+// NewOrderedMap takes comparator which reports whether a goes after b
+func NewOrderedMap[K any, V any](comparator func(a, b K) bool, options ...OrderedMapOption) *OrderedMap[K, V] {
+	return NewOrderedMapFunc[K, V](func(a, b K) int {
+		switch {
+		case comparator(a, b):
+			return 1
+		case comparator(b, a):
+			return -1
+		default:
+			return 0
+		}
+	}, options...)
+}
+
+// NewOrderedMapOf orders keys by cmp.Compare, NaN is less than other floats
+func NewOrderedMapOf[K cmp.Ordered, V any](options ...OrderedMapOption) *OrderedMap[K, V] {
+	return NewOrderedMapFunc[K, V](cmp.Compare[K], options...)
+}
+
+// NewOrderedMapFunc takes three-way comparison like cmp.Compare:
+// negative when a < b, zero when keys are equal and positive when a > b
+func NewOrderedMapFunc[K any, V any](compare func(a, b K) int, options ...OrderedMapOption) *OrderedMap[K, V] {
+	var config orderedMapConfig
+	for _, option := range options {
+		option(&config)
+	}
+
+	m := &OrderedMap[K, V]{compare: compare, validating: config.validate}
+	if config.validate {
+		m.compare = validatingCompare(compare)
+	}
+
+	return m
+}
+
+// validatingCompare panics when compare(a, b) and compare(b, a)
+// disagree or a key is not equal to itself
+func validatingCompare[K any](compare func(a, b K) int) func(a, b K) int {
+	return func(a, b K) int {
+		if compare(a, a) != 0 {
+			panic(fmt.Errorf("%w: %v is not equal to itself", ErrInvalidComparator, a))
 		}
 
-		if comparator(key, n.prev.key) {
-			newNode := &node[K, V]{key: key, prev: n.prev, next: n}
-			n.prev.next = newNode
-			n.prev = newNode
-			return n.prev
+		forward, backward := cmp.Compare(compare(a, b), 0), cmp.Compare(compare(b, a), 0)
+		if forward != -backward {
+			panic(fmt.Errorf("%w: compare(%v, %v) = %d, compare(%v, %v) = %d", ErrInvalidComparator, a, b, forward, b, a, backward))
+		}
+
+		return forward
+	}
+}
+
+func (m *OrderedMap[K, V]) Insert(key K, value V) {
+	var parent *node[K, V]
+	var order int
+	current := m.root
+	for current != nil {
+		order = m.compare(key, current.key)
+		if order == 0 {
+			current.value = value
+			return
 		}
 
-		return n.prev.fetchNode(key, comparator)
+		parent = current
+		if order < 0 {
+			current = current.left
+		} else {
+			current = current.right
+		}
 	}
 
-	if n.next == nil {
-		n.next = &node[K, V]{key: key, prev: n}
-		return n.next
+	inserted := &node[K, V]{key: key, value: value, parent: parent, color: red, count: 1}
+	switch {
+	case parent == nil:
+		m.root = inserted
+	case order < 0:
+		parent.left = inserted
+	default:
+		parent.right = inserted
 	}
 
-	if comparator(n.next.key, key) {
-		newNode := &node[K, V]{key: key, prev: n, next: n.next}
-		n.next.prev = newNode
-		n.next = newNode
-		return n.next
+	for ancestor := parent; ancestor != nil; ancestor = ancestor.parent {
+		ancestor.count++
 	}
 
-	return n.next.fetchNode(key, comparator)
+	m.size++
+	m.insertFixup(inserted)
+	if m.validating {
+		m.checkNeighbours(inserted)
+	}
 }
 
-func (n *node[K, V]) findNode(key K, comparator func(a, b K) bool) *node[K, V] {
-	if n.key == key {
-		return n
+// checkNeighbours catches intransitive comparators: the new key was placed
+// by comparisons with its ancestors, but it also must be between neighbours
+// and the smallest key must be less than the biggest one
+func (m *OrderedMap[K, V]) checkNeighbours(n *node[K, V]) {
+	pairs := [][2]*node[K, V]{
+		{n.predecessor(), n},
+		{n, n.successor()},
+		{n.predecessor(), n.successor()},
+		{m.root.minimum(), m.root.maximum()},
 	}
-	if comparator(n.key, key) {
-		if n.prev == nil {
-			return nil
+
+	for _, pair := range pairs {
+		less, greater := pair[0], pair[1]
+		if less != nil && greater != nil && less != greater && m.compare(less.key, greater.key) >= 0 {
+			panic(fmt.Errorf("%w: %v is placed before %v", ErrInvalidComparator, less.key, greater.key))
 		}
+	}
+}
+
+// insertFixup restores colors after a red node is attached,
+// only a red node with a red parent can break the rules
+func (m *OrderedMap[K, V]) insertFixup(n *node[K, V]) {
+	for isRed(n.parent) {
+		parent := n.parent
+		grandparent := parent.parent // exists because the root is black
+
+		if parent == grandparent.left {
+			uncle := grandparent.right
+			if isRed(uncle) {
+				parent.color, uncle.color, grandparent.color = black, black, red
+				n = grandparent
+				continue
+			}
 
-		if comparator(key, n.prev.key) {
-			return nil
+			if n == parent.right {
+				n = parent
+				m.rotateLeft(n)
+				parent = n.parent
+			}
+
+			parent.color, grandparent.color = black, red
+			m.rotateRight(grandparent)
+		} else {
+			uncle := grandparent.left
+			if isRed(uncle) {
+				parent.color, uncle.color, grandparent.color = black, black, red
+				n = grandparent
+				continue
+			}
+
+			if n == parent.left {
+				n = parent
+				m.rotateRight(n)
+				parent = n.parent
+			}
+
+			parent.color, grandparent.color = black, red
+			m.rotateLeft(grandparent)
 		}
+	}
+
+	m.root.color = black
+}
 
-		return n.prev.findNode(key, comparator)
+func (m *OrderedMap[K, V]) Erase(key K) {
+	if n := m.find(key); n != nil {
+		m.delete(n)
 	}
+}
+
+func (m *OrderedMap[K, V]) delete(n *node[K, V]) {
+	// child takes place of the removed node, its parent
+	// is tracked separately because child can be nil
+	var child, childParent *node[K, V]
+	removedColor := n.color
+
+	switch {
+	case n.left == nil:
+		child, childParent = n.right, n.parent
+		m.transplant(n, n.right)
+	case n.right == nil:
+		child, childParent = n.left, n.parent
+		m.transplant(n, n.left)
+	default:
+		next := n.right.minimum()
+		removedColor = next.color
+		child = next.right
+		if next.parent == n {
+			childParent = next
+		} else {
+			childParent = next.parent
+			m.transplant(next, next.right)
+			next.right = n.right
+			next.right.parent = next
+		}
 
-	if n.next == nil {
-		return nil
+		m.transplant(n, next)
+		next.left = n.left
+		next.left.parent = next
+		next.color = n.color
 	}
 
-	if comparator(n.next.key, key) {
-		return nil
+	for ancestor := childParent; ancestor != nil; ancestor = ancestor.parent {
+		ancestor.updateCount()
 	}
 
-	return n.next.findNode(key, comparator)
+	m.size--
+	if removedColor == black {
+		m.deleteFixup(child, childParent)
+	}
 }
 
-func (n *node[K, V]) first() *node[K, V] {
-	if n.prev == nil {
-		return n
+// deleteFixup moves the missing black up the tree until it
+// can be compensated by recoloring or rotations
+func (m *OrderedMap[K, V]) deleteFixup(n, parent *node[K, V]) {
+	for n != m.root && !isRed(n) {
+		if n == parent.left {
+			sibling := parent.right
+			if isRed(sibling) {
+				sibling.color, parent.color = black, red
+				m.rotateLeft(parent)
+				sibling = parent.right
+			}
+
+			if !isRed(sibling.left) && !isRed(sibling.right) {
+				sibling.color = red
+				n, parent = parent, parent.parent
+				continue
+			}
+
+			if !isRed(sibling.right) {
+				sibling.left.color, sibling.color = black, red
+				m.rotateRight(sibling)
+				sibling = parent.right
+			}
+
+			sibling.color, parent.color, sibling.right.color = parent.color, black, black
+			m.rotateLeft(parent)
+			n = m.root
+		} else {
+			sibling := parent.left
+			if isRed(sibling) {
+				sibling.color, parent.color = black, red
+				m.rotateRight(parent)
+				sibling = parent.left
+			}
+
+			if !isRed(sibling.left) && !isRed(sibling.right) {
+				sibling.color = red
+				n, parent = parent, parent.parent
+				continue
+			}
+
+			if !isRed(sibling.left) {
+				sibling.right.color, sibling.color = black, red
+				m.rotateLeft(sibling)
+				sibling = parent.left
+			}
+
+			sibling.color, parent.color, sibling.left.color = parent.color, black, black
+			m.rotateRight(parent)
+			n = m.root
+		}
 	}
 
-	return n.prev.first()
+	if n != nil {
+		n.color = black
+	}
 }
 
-func (n *node[K, V]) last() *node[K, V] {
-	if n.next == nil {
-		return n
+// transplant replaces subtree of old by subtree of replacement
+func (m *OrderedMap[K, V]) transplant(old, replacement *node[K, V]) {
+	switch {
+	case old.parent == nil:
+		m.root = replacement
+	case old == old.parent.left:
+		old.parent.left = replacement
+	default:
+		old.parent.right = replacement
 	}
 
-	return n.next.last()
+	if replacement != nil {
+		replacement.parent = old.parent
+	}
 }
 
-type OrderedMap[K comparable, V any] struct {
-	root       *node[K, V]
-	size       int
-	comparator func(a, b K) bool
+func (m *OrderedMap[K, V]) rotateLeft(n *node[K, V]) {
+	child := n.right
+	n.right = child.left
+	if child.left != nil {
+		child.left.parent = n
+	}
+
+	m.transplant(n, child)
+	child.left = n
+	n.parent = child
+
+	child.count = n.count
+	n.updateCount()
 }
 
-func NewOrderedMap[K comparable, V any](comparator func(a, b K) bool) *OrderedMap[K, V] {
-	return &OrderedMap[K, V]{comparator: comparator}
+func (m *OrderedMap[K, V]) rotateRight(n *node[K, V]) {
+	child := n.left
+	n.left = child.right
+	if child.right != nil {
+		child.right.parent = n
+	}
+
+	m.transplant(n, child)
+	child.right = n
+	n.parent = child
+
+	child.count = n.count
+	n.updateCount()
 }
 
-func (m *OrderedMap[K, V]) Insert(key K, value V) {
-	if m.root == nil {
-		m.root = &node[K, V]{
-			data: &value,
-			key:  key,
+func (m *OrderedMap[K, V]) find(key K) *node[K, V] {
+	current := m.root
+	for current != nil {
+		order := m.compare(key, current.key)
+		switch {
+		case order == 0:
+			return current
+		case order < 0:
+			current = current.left
+		default:
+			current = current.right
 		}
-		m.size++
-		return
 	}
 
-	fetchedNode := m.root.fetchNode(key, m.comparator)
-	if fetchedNode.data == nil {
-		m.size++
+	return nil
+}
+
+func (m *OrderedMap[K, V]) Contains(key K) bool {
+	return m.find(key) != nil
+}
+
+func (m *OrderedMap[K, V]) Get(key K) (V, bool) {
+	n := m.find(key)
+	if n == nil {
+		var empty V
+		return empty, false
 	}
-	fetchedNode.data = &value
+
+	return n.value, true
 }
 
-func (m *OrderedMap[K, V]) Erase(key K) {
+// Min returns the smallest key
+func (m *OrderedMap[K, V]) Min() (K, V, bool) {
 	if m.root == nil {
-		return
+		return entry[K, V](nil)
 	}
 
-	if m.root.next == nil && m.root.prev == nil {
-		m.root = nil
-		m.size--
-		return
+	return entry(m.root.minimum())
+}
+
+// Max returns the biggest key
+func (m *OrderedMap[K, V]) Max() (K, V, bool) {
+	if m.root == nil {
+		return entry[K, V](nil)
 	}
 
-	nodeToDelete := m.root.findNode(key, m.comparator)
-	if nodeToDelete == nil {
-		return
+	return entry(m.root.maximum())
+}
+
+// Floor returns the biggest key which is less than or equal to key
+func (m *OrderedMap[K, V]) Floor(key K) (K, V, bool) {
+	return entry(m.floor(key))
+}
+
+// Ceiling returns the smallest key which is greater than or equal to key
+func (m *OrderedMap[K, V]) Ceiling(key K) (K, V, bool) {
+	return entry(m.ceiling(key))
+}
+
+func entry[K any, V any](n *node[K, V]) (K, V, bool) {
+	if n == nil {
+		var key K
+		var value V
+		return key, value, false
 	}
 
-	if nodeToDelete.prev == nil {
-		nodeToDelete.next.prev = nil
-		m.size--
-		return
+	return n.key, n.value, true
+}
+
+func (m *OrderedMap[K, V]) floor(key K) *node[K, V] {
+	var result *node[K, V]
+	current := m.root
+	for current != nil {
+		order := m.compare(key, current.key)
+		switch {
+		case order == 0:
+			return current
+		case order < 0:
+			current = current.left
+		default:
+			result = current
+			current = current.right
+		}
 	}
-	if nodeToDelete.next == nil {
-		nodeToDelete.prev.next = nil
-		m.size--
-		return
+
+	return result
+}
+
+func (m *OrderedMap[K, V]) ceiling(key K) *node[K, V] {
+	var result *node[K, V]
+	current := m.root
+	for current != nil {
+		order := m.compare(key, current.key)
+		switch {
+		case order == 0:
+			return current
+		case order < 0:
+			result = current
+			current = current.left
+		default:
+			current = current.right
+		}
 	}
 
-	nodeToDelete.next.prev = nodeToDelete.prev
-	nodeToDelete.prev.next = nodeToDelete.next
-	m.size--
+	return result
 }
 
-func (m *OrderedMap[K, V]) Contains(key K) bool {
-	if m.root == nil {
-		return false
+// Rank returns number of keys less than key, the key can be absent
+func (m *OrderedMap[K, V]) Rank(key K) int {
+	rank := 0
+	current := m.root
+	for current != nil {
+		order := m.compare(key, current.key)
+		switch {
+		case order == 0:
+			return rank + count(current.left)
+		case order < 0:
+			current = current.left
+		default:
+			rank += count(current.left) + 1
+			current = current.right
+		}
 	}
-	dataNode := m.root.findNode(key, m.comparator)
-	return dataNode != nil
+
+	return rank
 }
 
-func (m *OrderedMap[K, V]) Size() int {
-	return m.size // need to implement
+// Select returns the key with the given rank
+func (m *OrderedMap[K, V]) Select(index int) (K, V, bool) {
+	if index < 0 || index >= m.size {
+		return entry[K, V](nil)
+	}
+
+	current := m.root
+	for {
+		leftCount := count(current.left)
+		switch {
+		case index < leftCount:
+			current = current.left
+		case index == leftCount:
+			return entry(current)
+		default:
+			index -= leftCount + 1
+			current = current.right
+		}
+	}
 }
 
-func (m *OrderedMap[K, V]) ForEach(action func(K, V)) {
-	if m.root == nil {
-		return
+// All iterates keys in ascending order. The map must not be changed
+// during iteration, use Cursor to delete keys while iterating.
+func (m *OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if m.root == nil {
+			return
+		}
+
+		for current := m.root.minimum(); current != nil; current = current.successor() {
+			if !yield(current.key, current.value) {
+				return
+			}
+		}
 	}
-	firstNode := m.root.first()
-	if firstNode == nil {
-		return
+}
+
+// Backward iterates keys in descending order
+func (m *OrderedMap[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if m.root == nil {
+			return
+		}
+
+		for current := m.root.maximum(); current != nil; current = current.predecessor() {
+			if !yield(current.key, current.value) {
+				return
+			}
+		}
+	}
+}
+
+// Range iterates keys from the half-open interval [from, to) in
+// ascending order, so adjacent time ranges do not overlap
+func (m *OrderedMap[K, V]) Range(from, to K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for current := m.ceiling(from); current != nil && m.compare(current.key, to) < 0; current = current.successor() {
+			if !yield(current.key, current.value) {
+				return
+			}
+		}
+	}
+}
+
+// Cursor points to a key of the map or past the end
+type Cursor[K any, V any] struct {
+	m    *OrderedMap[K, V]
+	node *node[K, V]
+}
+
+// First returns cursor at the smallest key
+func (m *OrderedMap[K, V]) First() *Cursor[K, V] {
+	cursor := &Cursor[K, V]{m: m}
+	if m.root != nil {
+		cursor.node = m.root.minimum()
+	}
+
+	return cursor
+}
+
+// Last returns cursor at the biggest key
+func (m *OrderedMap[K, V]) Last() *Cursor[K, V] {
+	cursor := &Cursor[K, V]{m: m}
+	if m.root != nil {
+		cursor.node = m.root.maximum()
 	}
-	currentNode := firstNode
-	for currentNode != nil {
-		value := *currentNode.data
-		action(currentNode.key, value)
-		currentNode = currentNode.next
+
+	return cursor
+}
+
+// Seek returns cursor at the smallest key which is greater than or equal to key
+func (m *OrderedMap[K, V]) Seek(key K) *Cursor[K, V] {
+	return &Cursor[K, V]{m: m, node: m.ceiling(key)}
+}
+
+func (c *Cursor[K, V]) Valid() bool {
+	return c.node != nil
+}
+
+func (c *Cursor[K, V]) Key() K {
+	return c.node.key
+}
+
+func (c *Cursor[K, V]) Value() V {
+	return c.node.value
+}
+
+func (c *Cursor[K, V]) Next() {
+	c.node = c.node.successor()
+}
+
+func (c *Cursor[K, V]) Prev() {
+	c.node = c.node.predecessor()
+}
+
+// Delete removes the current key and moves the cursor to the next one.
+// Nodes are relinked instead of swapping keys, so other nodes stay valid.
+func (c *Cursor[K, V]) Delete() {
+	next := c.node.successor()
+	c.m.delete(c.node)
+	c.node = next
+}
+
+func (m *OrderedMap[K, V]) Size() int {
+	return m.size
+}
+
+func (m *OrderedMap[K, V]) ForEach(action func(K, V)) {
+	for key, value := range m.All() {
+		action(key, value)
 	}
 }
 
 func (m *OrderedMap[K, V]) PrintTree() {
-	firstNode := m.root.first()
-	currentNode := firstNode
-	for currentNode != nil {
-		currentKey := currentNode.key
-		var prevKey K
-		var nextKey K
-		if currentNode.next != nil {
-			nextKey = currentNode.next.key
+	m.ForEach(func(key K, _ V) {
+		n := m.find(key)
+		var parentKey, leftKey, rightKey any
+		if n.parent != nil {
+			parentKey = n.parent.key
+		}
+		if n.left != nil {
+			leftKey = n.left.key
+		}
+		if n.right != nil {
+			rightKey = n.right.key
+		}
+
+		colorName := "red"
+		if n.color == black {
+			colorName = "black"
+		}
+		fmt.Println("key:", key, colorName, "parent:", parentKey, "left:", leftKey, "right:", rightKey)
+	})
+}
+
+// checkTree verifies red-black rules, parent links, order and size
+func checkTree[K any, V any](t *testing.T, m *OrderedMap[K, V]) {
+	t.Helper()
+	if m.root == nil {
+		require.Zero(t, m.size)
+		return
+	}
+	require.Equal(t, black, m.root.color, "root must be black")
+	require.Nil(t, m.root.parent)
+
+	nodes := 0
+	var walk func(n *node[K, V]) int
+	walk = func(n *node[K, V]) int {
+		if n == nil {
+			return 1
+		}
+
+		nodes++
+		require.Equal(t, 1+count(n.left)+count(n.right), n.count, "count is broken at %v", n.key)
+		if isRed(n) {
+			require.False(t, isRed(n.left) || isRed(n.right), "red node %v has red child", n.key)
 		}
-		if currentNode.prev != nil {
-			prevKey = currentNode.prev.key
+		if n.left != nil {
+			require.Same(t, n, n.left.parent)
+			require.Negative(t, m.compare(n.left.key, n.key), "order is broken at %v", n.key)
+		}
+		if n.right != nil {
+			require.Same(t, n, n.right.parent)
+			require.Positive(t, m.compare(n.right.key, n.key), "order is broken at %v", n.key)
 		}
-		fmt.Println("current key:", currentKey, "prev:", prevKey, "next:", nextKey)
 
-		currentNode = currentNode.next
+		leftHeight, rightHeight := walk(n.left), walk(n.right)
+		require.Equal(t, leftHeight, rightHeight, "black height differs at %v", n.key)
+		if n.color == black {
+			return leftHeight + 1
+		}
+		return leftHeight
 	}
+
+	walk(m.root)
+	require.Equal(t, nodes, m.size)
 }
 
 func TestCircularQueue(t *testing.T) {
@@ -426,3 +980,355 @@ func TestStructKeysByField(t *testing.T) {
 	m.ForEach(func(k keyByField, _ string) { ids = append(ids, k.ID) })
 	assert.True(t, reflect.DeepEqual([]int{1, 2, 3}, ids))
 }
+
+func TestTreeInvariants(t *testing.T) {
+	greater := func(a, b int) bool { return a > b }
+	m := NewOrderedMap[int, int](greater)
+	model := make(map[int]int)
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 5_000; i++ {
+		key := random.Intn(500)
+		if random.Intn(3) == 0 {
+			m.Erase(key)
+			delete(model, key)
+		} else {
+			m.Insert(key, i)
+			model[key] = i
+		}
+
+		if i%100 == 0 {
+			checkTree(t, m)
+		}
+	}
+	checkTree(t, m)
+
+	var keys []int
+	m.ForEach(func(key, value int) {
+		keys = append(keys, key)
+		assert.Equal(t, model[key], value)
+	})
+
+	expected := make([]int, 0, len(model))
+	for key := range model {
+		expected = append(expected, key)
+	}
+	slices.Sort(expected)
+	assert.Equal(t, expected, keys)
+}
+
+func TestSortedInsertionDoesNotRecurse(t *testing.T) {
+	// the linked list recursed once per element and
+	// the unbalanced tree would degrade to a list
+	const size = 1_000_000
+	m := NewOrderedMap[int, struct{}](func(a, b int) bool { return a > b })
+	for i := 0; i < size; i++ {
+		m.Insert(i, struct{}{})
+	}
+	assert.Equal(t, size, m.Size())
+	assert.True(t, m.Contains(size-1))
+
+	height := 0
+	for n := m.root; n != nil; n = n.left {
+		height++
+	}
+	assert.LessOrEqual(t, height, 40)
+
+	for i := 0; i < size; i += 2 {
+		m.Erase(i)
+	}
+	assert.Equal(t, size/2, m.Size())
+	assert.False(t, m.Contains(0))
+	assert.True(t, m.Contains(1))
+}
+
+const benchmarkKeysNumber = 1_000_000
+
+func benchmarkMap(b *testing.B) (*OrderedMap[int, int], []int) {
+	b.Helper()
+	random := rand.New(rand.NewSource(1))
+	keys := random.Perm(benchmarkKeysNumber)
+
+	m := NewOrderedMap[int, int](func(a, b int) bool { return a > b })
+	for _, key := range keys {
+		m.Insert(key, key)
+	}
+
+	b.ResetTimer()
+	return m, keys
+}
+
+func BenchmarkOrderedMap_Insert(b *testing.B) {
+	m, _ := benchmarkMap(b)
+	for i := 0; i < b.N; i++ {
+		m.Insert(benchmarkKeysNumber+i, i)
+	}
+}
+
+func BenchmarkOrderedMap_Contains(b *testing.B) {
+	m, keys := benchmarkMap(b)
+	for i := 0; i < b.N; i++ {
+		m.Contains(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkOrderedMap_EraseInsert(b *testing.B) {
+	m, keys := benchmarkMap(b)
+	for i := 0; i < b.N; i++ {
+		key := keys[i%len(keys)]
+		m.Erase(key)
+		m.Insert(key, i)
+	}
+}
+
+func BenchmarkOrderedMap_ForEach(b *testing.B) {
+	m, _ := benchmarkMap(b)
+	for i := 0; i < b.N; i++ {
+		sum := 0
+		m.ForEach(func(key, _ int) { sum += key })
+	}
+}
+
+func newIntMap(keys ...int) *OrderedMap[int, string] {
+	m := NewOrderedMap[int, string](func(a, b int) bool { return a > b })
+	for _, key := range keys {
+		m.Insert(key, fmt.Sprint("v", key))
+	}
+
+	return m
+}
+
+func TestGetMinMax(t *testing.T) {
+	m := newIntMap()
+	_, ok := m.Get(1)
+	assert.False(t, ok)
+	_, _, ok = m.Min()
+	assert.False(t, ok)
+	_, _, ok = m.Max()
+	assert.False(t, ok)
+
+	m = newIntMap(30, 10, 20)
+	value, ok := m.Get(20)
+	assert.True(t, ok)
+	assert.Equal(t, "v20", value)
+
+	key, value, ok := m.Min()
+	assert.True(t, ok)
+	assert.Equal(t, 10, key)
+	assert.Equal(t, "v10", value)
+
+	key, _, _ = m.Max()
+	assert.Equal(t, 30, key)
+}
+
+func TestFloorCeiling(t *testing.T) {
+	m := newIntMap(10, 20, 30)
+
+	tests := []struct {
+		key                  int
+		floor, ceiling       int
+		hasFloor, hasCeiling bool
+	}{
+		{key: 5, ceiling: 10, hasCeiling: true},
+		{key: 10, floor: 10, ceiling: 10, hasFloor: true, hasCeiling: true},
+		{key: 15, floor: 10, ceiling: 20, hasFloor: true, hasCeiling: true},
+		{key: 30, floor: 30, ceiling: 30, hasFloor: true, hasCeiling: true},
+		{key: 35, floor: 30, hasFloor: true},
+	}
+
+	for _, test := range tests {
+		floor, _, ok := m.Floor(test.key)
+		assert.Equal(t, test.hasFloor, ok, "floor of %d", test.key)
+		assert.Equal(t, test.floor, floor, "floor of %d", test.key)
+
+		ceiling, _, ok := m.Ceiling(test.key)
+		assert.Equal(t, test.hasCeiling, ok, "ceiling of %d", test.key)
+		assert.Equal(t, test.ceiling, ceiling, "ceiling of %d", test.key)
+	}
+}
+
+func TestRangeAndBackward(t *testing.T) {
+	m := newIntMap(50, 10, 40, 20, 30)
+
+	var keys []int
+	for key := range m.Range(15, 40) {
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []int{20, 30}, keys)
+
+	keys = nil
+	for key := range m.Range(10, 11) {
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []int{10}, keys)
+
+	keys = nil
+	for range m.Range(41, 50) {
+		keys = append(keys, 0)
+	}
+	assert.Empty(t, keys)
+
+	keys = nil
+	for key, value := range m.Backward() {
+		keys = append(keys, key)
+		assert.Equal(t, fmt.Sprint("v", key), value)
+		if key == 20 {
+			break
+		}
+	}
+	assert.Equal(t, []int{50, 40, 30, 20}, keys)
+
+	keys = nil
+	for key := range m.All() {
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []int{10, 20, 30, 40, 50}, keys)
+}
+
+func TestRankSelect(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	m := newIntMap()
+	for _, key := range random.Perm(1_000) {
+		m.Insert(2*key, "")
+	}
+	for key := 0; key < 2_000; key += 3 {
+		m.Erase(key)
+	}
+	checkTree(t, m)
+
+	index := 0
+	for key := range m.All() {
+		assert.Equal(t, index, m.Rank(key))
+		assert.Equal(t, index+1, m.Rank(key+1), "rank of absent key")
+
+		selected, _, ok := m.Select(index)
+		assert.True(t, ok)
+		assert.Equal(t, key, selected)
+		index++
+	}
+
+	assert.Equal(t, 0, m.Rank(-1))
+	_, _, ok := m.Select(m.Size())
+	assert.False(t, ok)
+	_, _, ok = m.Select(-1)
+	assert.False(t, ok)
+}
+
+func TestCursor(t *testing.T) {
+	m := newIntMap(1, 2, 3, 4, 5, 6, 7, 8)
+
+	// delete even keys while iterating
+	for cursor := m.First(); cursor.Valid(); {
+		if cursor.Key()%2 == 0 {
+			cursor.Delete()
+		} else {
+			cursor.Next()
+		}
+	}
+	checkTree(t, m)
+
+	var keys []int
+	for cursor := m.Last(); cursor.Valid(); cursor.Prev() {
+		keys = append(keys, cursor.Key())
+	}
+	assert.Equal(t, []int{7, 5, 3, 1}, keys)
+
+	cursor := m.Seek(4)
+	assert.Equal(t, 5, cursor.Key())
+	assert.Equal(t, "v5", cursor.Value())
+	cursor.Delete()
+	assert.Equal(t, 7, cursor.Key())
+	cursor.Delete()
+	assert.False(t, cursor.Valid())
+	assert.False(t, m.Seek(8).Valid())
+	assert.Equal(t, 2, m.Size())
+}
+
+func TestOrderedMapOf(t *testing.T) {
+	m := NewOrderedMapOf[string, int]()
+	m.Insert("b", 2)
+	m.Insert("a", 1)
+	m.Insert("c", 3)
+
+	var keys []string
+	for key := range m.All() {
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+}
+
+func TestEqualityFromComparator(t *testing.T) {
+	compareByID := func(a, b keyByField) int { return cmp.Compare(a.ID, b.ID) }
+	lessByID := func(a, b keyByField) bool { return a.ID > b.ID }
+
+	maps := map[string]*OrderedMap[keyByField, string]{
+		"three-way": NewOrderedMapFunc[keyByField, string](compareByID),
+		"bool":      NewOrderedMap[keyByField, string](lessByID),
+	}
+
+	for name, m := range maps {
+		t.Run(name, func(t *testing.T) {
+			m.Insert(keyByField{1, "a"}, "first")
+			m.Insert(keyByField{1, "b"}, "second") // the same key for the comparator
+			m.Insert(keyByField{2, "c"}, "third")
+
+			assert.Equal(t, 2, m.Size())
+			value, ok := m.Get(keyByField{1, "other"})
+			assert.True(t, ok)
+			assert.Equal(t, "second", value)
+
+			m.Erase(keyByField{2, ""})
+			assert.Equal(t, 1, m.Size())
+			checkTree(t, m)
+		})
+	}
+}
+
+func assertInvalidComparator(t *testing.T, action func()) {
+	t.Helper()
+	defer func() {
+		err, _ := recover().(error)
+		assert.ErrorIs(t, err, ErrInvalidComparator)
+	}()
+
+	action()
+}
+
+func TestComparatorValidation(t *testing.T) {
+	// a <= b instead of a < b: the key is not equal to itself
+	notStrict := NewOrderedMap[int, int](func(a, b int) bool { return a >= b }, WithComparatorValidation())
+	assertInvalidComparator(t, func() {
+		notStrict.Insert(1, 1)
+		notStrict.Insert(2, 2)
+	})
+
+	inconsistent := NewOrderedMapFunc[int, int](func(a, b int) int { return -1 }, WithComparatorValidation())
+	assertInvalidComparator(t, func() {
+		inconsistent.Insert(1, 1)
+		inconsistent.Insert(2, 2)
+	})
+
+	// rock-paper-scissors: each key is less than the next one
+	// modulo 3, every pair is consistent but the order is cyclic
+	cyclic := NewOrderedMapFunc[int, int](func(a, b int) int {
+		switch {
+		case a == b:
+			return 0
+		case (a+1)%3 == b:
+			return -1
+		default:
+			return 1
+		}
+	}, WithComparatorValidation())
+	assertInvalidComparator(t, func() {
+		for key := range 3 {
+			cyclic.Insert(key, key)
+		}
+	})
+
+	valid := NewOrderedMapOf[int, int](WithComparatorValidation())
+	for _, key := range rand.New(rand.NewSource(1)).Perm(1_000) {
+		valid.Insert(key, key)
+	}
+	checkTree(t, valid)
+}
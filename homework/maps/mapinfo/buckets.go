@@ -0,0 +1,61 @@
+package mapinfo
+
+import "unsafe"
+
+// hmap is the map header of runtime/map.go before Go 1.24
+type hmap struct {
+	count     int
+	flags     uint8
+	B         uint8 // log2 of buckets number
+	noverflow uint16
+	hash0     uint32
+
+	buckets    unsafe.Pointer
+	oldbuckets unsafe.Pointer // not nil while growing
+	nevacuate  uintptr
+	extra      unsafe.Pointer
+}
+
+// flags of hmap
+const sameSizeGrow = 8
+
+// bucketSize mirrors bmap: 8 top hashes, 8 keys,
+// 8 values and a pointer to the overflow bucket
+func bucketSize(sizes entrySizes) uintptr {
+	keySize, keyAlign := slot(sizes.key)
+	elemSize, elemAlign := slot(sizes.elem)
+	pointerSize := unsafe.Sizeof(uintptr(0))
+
+	size := uintptr(slotsPerBucket) + slotsPerBucket*keySize + slotsPerBucket*elemSize
+	size = alignUp(size, pointerSize) + pointerSize
+	return alignUp(size, max(keyAlign, elemAlign, 1))
+}
+
+func inspectBuckets(header unsafe.Pointer, sizes entrySizes) Info {
+	h := (*hmap)(header)
+	buckets := 1 << h.B
+	bucket := bucketSize(sizes)
+
+	info := Info{
+		Layout:          LayoutBuckets,
+		Len:             h.count,
+		Buckets:         buckets,
+		OverflowBuckets: int(h.noverflow),
+		Capacity:        buckets * slotsPerBucket,
+		Growing:         h.oldbuckets != nil,
+	}
+
+	allocated := buckets + info.OverflowBuckets
+	if info.Growing {
+		// old buckets are twice smaller unless the map
+		// grows to get rid of overflow buckets
+		if h.flags&sameSizeGrow != 0 {
+			allocated += buckets
+		} else {
+			allocated += buckets / 2
+		}
+	}
+
+	info.Bytes = unsafe.Sizeof(*h) + uintptr(allocated)*bucket + uintptr(h.count)*sizes.indirect()
+	return info
+}
@@ -0,0 +1,208 @@
+// Package mapinfo reads the runtime representation of builtin maps to
+// show how much memory they hold. Maps never give memory back after
+// delete, so a map which was big once stays big: Inspect it and rebuild
+// it with Compact when it is mostly empty.
+//
+// The runtime layout is private and changes between releases, so it is
+// read only for releases it was checked against. Other releases get
+// Info with only Len set.
+package mapinfo
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Layout is the map implementation of the running release
+type Layout int
+
+const (
+	LayoutUnknown Layout = iota
+	LayoutBuckets        // hash table with overflow buckets, before Go 1.24
+	LayoutSwiss          // swiss tables, since Go 1.24
+)
+
+func (l Layout) String() string {
+	switch l {
+	case LayoutBuckets:
+		return "buckets"
+	case LayoutSwiss:
+		return "swiss"
+	default:
+		return "unknown"
+	}
+}
+
+// releases which layouts were checked against
+const (
+	firstBucketsRelease = 12
+	firstSwissRelease   = 24
+	lastSwissRelease    = 27
+)
+
+// Info describes memory of a map. Buckets are groups of 8 slots
+// for both layouts, swiss maps have no overflow buckets and grow
+// at once, so OverflowBuckets and Growing are always zero for them.
+type Info struct {
+	Layout          Layout
+	Len             int
+	Buckets         int     // buckets or groups without overflow ones
+	OverflowBuckets int     // approximate when there are more than 2^16 buckets
+	Tables          int     // independent swiss tables
+	Capacity        int     // slots in buckets without overflow ones
+	LoadFactor      float64 // Len / Capacity
+	Growing         bool    // old buckets are not evacuated yet
+	Tombstones      int     // deleted slots which still take capacity
+	Bytes           uintptr // estimated memory of the map
+}
+
+// Known reports whether the layout was read
+func (i Info) Known() bool {
+	return i.Layout != LayoutUnknown
+}
+
+// Oversized reports whether the map uses less than loadFactor of its
+// capacity and is bigger than minCapacity slots. Such a map is worth
+// rebuilding with Compact.
+func (i Info) Oversized(minCapacity int, loadFactor float64) bool {
+	return i.Known() && i.Capacity > minCapacity && i.LoadFactor < loadFactor
+}
+
+func (i Info) String() string {
+	if !i.Known() {
+		return fmt.Sprintf("len=%d layout=unknown", i.Len)
+	}
+
+	return fmt.Sprintf("len=%d layout=%s buckets=%d overflow=%d tables=%d capacity=%d load=%.3f growing=%t tombstones=%d bytes=%d",
+		i.Len, i.Layout, i.Buckets, i.OverflowBuckets, i.Tables, i.Capacity, i.LoadFactor, i.Growing, i.Tombstones, i.Bytes)
+}
+
+// Inspect reads the runtime layout of m. It must not run concurrently
+// with writes to m.
+func Inspect[K comparable, V any](m map[K]V) Info {
+	return inspect(m, layoutOf(runtime.Version()))
+}
+
+// Compact copies m into a map which fits its length
+func Compact[M ~map[K]V, K comparable, V any](m M) M {
+	compacted := make(M, len(m))
+	for key, value := range m {
+		compacted[key] = value
+	}
+
+	return compacted
+}
+
+type eface struct {
+	typ  unsafe.Pointer
+	data unsafe.Pointer
+}
+
+func inspect[K comparable, V any](m map[K]V, layout Layout) Info {
+	var info Info
+	iface := any(m)
+	header := (*eface)(unsafe.Pointer(&iface)).data
+	if header == nil || layout == LayoutUnknown {
+		info.Len = len(m)
+		return info
+	}
+
+	sizes := entrySizes{
+		key:  reflect.TypeFor[K](),
+		elem: reflect.TypeFor[V](),
+	}
+
+	switch layout {
+	case LayoutBuckets:
+		info = inspectBuckets(header, sizes)
+	case LayoutSwiss:
+		info = inspectSwiss(header, sizes)
+	}
+
+	// the layout is wrong when even the length does not match
+	if info.Len != len(m) {
+		return Info{Len: len(m)}
+	}
+
+	if info.Capacity > 0 {
+		info.LoadFactor = float64(info.Len) / float64(info.Capacity)
+	}
+
+	return info
+}
+
+// layoutOf parses versions like go1.22.5 or go1.24rc1,
+// development versions are unknown
+func layoutOf(version string) Layout {
+	release, ok := releaseOf(version)
+	switch {
+	case !ok:
+		return LayoutUnknown
+	case release >= firstBucketsRelease && release < firstSwissRelease:
+		return LayoutBuckets
+	case release >= firstSwissRelease && release <= lastSwissRelease && swissDisabled:
+		return LayoutBuckets
+	case release >= firstSwissRelease && release <= lastSwissRelease:
+		return LayoutSwiss
+	default:
+		return LayoutUnknown
+	}
+}
+
+func releaseOf(version string) (int, bool) {
+	rest, found := strings.CutPrefix(version, "go1.")
+	if !found {
+		return 0, false
+	}
+
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+
+	release, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0, false
+	}
+
+	return release, true
+}
+
+// maps store keys and values bigger than this by pointer
+const maxInlineSize = 128
+
+const slotsPerBucket = 8
+
+type entrySizes struct {
+	key, elem reflect.Type
+}
+
+// slot returns size and alignment of key or value inside a bucket
+func slot(typ reflect.Type) (size, align uintptr) {
+	if typ.Size() > maxInlineSize {
+		return unsafe.Sizeof(uintptr(0)), unsafe.Alignof(uintptr(0))
+	}
+
+	return typ.Size(), uintptr(typ.Align())
+}
+
+// indirect returns bytes allocated outside of buckets for one entry
+func (s entrySizes) indirect() uintptr {
+	var size uintptr
+	if s.key.Size() > maxInlineSize {
+		size += s.key.Size()
+	}
+	if s.elem.Size() > maxInlineSize {
+		size += s.elem.Size()
+	}
+
+	return size
+}
+
+func alignUp(size, align uintptr) uintptr {
+	return (size + align - 1) &^ (align - 1)
+}
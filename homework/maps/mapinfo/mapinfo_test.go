@@ -0,0 +1,144 @@
+package mapinfo
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v ./...
+
+func TestLayoutOf(t *testing.T) {
+	tests := map[string]struct {
+		version string
+		layout  Layout
+	}{
+		"too old":     {version: "go1.8", layout: LayoutUnknown},
+		"buckets":     {version: "go1.12", layout: LayoutBuckets},
+		"last bucket": {version: "go1.23.4", layout: LayoutBuckets},
+		"candidate":   {version: "go1.24rc1", layout: LayoutSwiss},
+		"swiss":       {version: "go1.27.1", layout: LayoutSwiss},
+		"newer":       {version: "go1.28", layout: LayoutUnknown},
+		"development": {version: "devel go1.28-4f2a1c Tue Oct 6 2026", layout: LayoutUnknown},
+		"gccgo":       {version: "go version gccgo", layout: LayoutUnknown},
+		"empty":       {version: "", layout: LayoutUnknown},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.layout, layoutOf(test.version))
+		})
+	}
+}
+
+func requireKnown(t *testing.T, info Info) {
+	t.Helper()
+	if layoutOf(runtime.Version()) == LayoutUnknown {
+		t.Skip("map layout of", runtime.Version(), "is unknown")
+	}
+	require.True(t, info.Known(), info)
+}
+
+func TestInspectSmallMaps(t *testing.T) {
+	var empty map[int]int
+	assert.Equal(t, Info{}, Inspect(empty))
+
+	small := map[int]int{1: 1, 2: 2}
+	info := Inspect(small)
+	requireKnown(t, info)
+	assert.Equal(t, 2, info.Len)
+	assert.Equal(t, 1, info.Buckets)
+	assert.Equal(t, 8, info.Capacity)
+	assert.Equal(t, 0.25, info.LoadFactor)
+	assert.False(t, info.Growing)
+	assert.Positive(t, info.Bytes)
+}
+
+func TestInspectNeverShrinks(t *testing.T) {
+	data := make(map[int]int)
+	before := Inspect(data)
+	requireKnown(t, before)
+
+	for i := range 100_000 {
+		data[i] = i
+	}
+	filled := Inspect(data)
+	assert.Equal(t, 100_000, filled.Len)
+	assert.GreaterOrEqual(t, filled.Capacity, 100_000)
+	assert.Greater(t, filled.LoadFactor, 0.4)
+	assert.False(t, filled.Oversized(1024, 0.25), filled)
+
+	for key := range data {
+		if key >= 10 {
+			delete(data, key)
+		}
+	}
+	deleted := Inspect(data)
+	assert.Equal(t, 10, deleted.Len)
+	assert.Equal(t, filled.Capacity, deleted.Capacity)
+	assert.Equal(t, filled.Buckets, deleted.Buckets)
+	assert.Equal(t, filled.Bytes, deleted.Bytes)
+	assert.True(t, deleted.Oversized(1024, 0.25), deleted)
+
+	compacted := Compact(data)
+	assert.Equal(t, data, compacted)
+	info := Inspect(compacted)
+	assert.False(t, info.Oversized(1024, 0.25), info)
+	assert.Less(t, info.Bytes, deleted.Bytes/100)
+}
+
+func TestInspectTombstones(t *testing.T) {
+	data := make(map[int]int)
+	requireKnown(t, Inspect(data))
+	for i := range 10_000 {
+		data[i] = i
+	}
+	for i := range 10_000 {
+		if i%2 == 0 {
+			delete(data, i)
+		}
+	}
+
+	info := Inspect(data)
+	assert.Equal(t, 5_000, info.Len)
+	if info.Layout == LayoutSwiss {
+		// deletes from full groups keep slots occupied
+		assert.Positive(t, info.Tombstones)
+		assert.Zero(t, info.OverflowBuckets)
+	}
+}
+
+func TestInspectEstimatesMemory(t *testing.T) {
+	var data map[int64]int64
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	allocated := stats.TotalAlloc
+
+	data = make(map[int64]int64, 100_000)
+	for i := range int64(100_000) {
+		data[i] = i
+	}
+	runtime.ReadMemStats(&stats)
+	allocated = stats.TotalAlloc - allocated
+
+	info := Inspect(data)
+	requireKnown(t, info)
+	assert.InEpsilon(t, float64(allocated), float64(info.Bytes), 0.1, info)
+
+	// big values are stored by pointer
+	big := map[int][256]byte{1: {}}
+	small := map[int][8]byte{1: {}}
+	assert.Equal(t, Inspect(small).Bytes+256, Inspect(big).Bytes)
+}
+
+func TestInspectUnknownLayout(t *testing.T) {
+	data := map[string]int{"a": 1, "b": 2}
+	info := inspect(data, LayoutUnknown)
+	assert.Equal(t, Info{Len: 2}, info)
+	assert.False(t, info.Known())
+	assert.False(t, info.Oversized(0, 1))
+	assert.Equal(t, "len=2 layout=unknown", info.String())
+}
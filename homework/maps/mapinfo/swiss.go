@@ -0,0 +1,90 @@
+package mapinfo
+
+import "unsafe"
+
+// swissMap is Map of internal/runtime/maps. Small maps keep up to
+// 8 entries in one group pointed by dirPtr, bigger ones point to
+// a directory of tables.
+type swissMap struct {
+	used        uint64
+	seed        uintptr
+	dirPtr      unsafe.Pointer
+	dirLen      int
+	globalDepth uint8
+	globalShift uint8
+	writing     uint8
+	_           bool
+	clearSeq    uint64
+}
+
+// swissTable is table of internal/runtime/maps
+type swissTable struct {
+	used       uint16
+	capacity   uint16
+	growthLeft uint16
+	localDepth uint8
+	index      int
+
+	groups     unsafe.Pointer
+	lengthMask uint64
+}
+
+// groupSize mirrors a group with interleaved
+// slots: 8 control bytes and 8 key-value pairs
+func groupSize(sizes entrySizes) uintptr {
+	keySize, keyAlign := slot(sizes.key)
+	elemSize, elemAlign := slot(sizes.elem)
+	slotAlign := max(keyAlign, elemAlign, 1)
+
+	slotSize := alignUp(alignUp(keySize, elemAlign)+elemSize, slotAlign)
+	return alignUp(slotsPerBucket+slotsPerBucket*slotSize, max(slotAlign, 8))
+}
+
+// maxGrowth is the number of slots a table fills before it grows,
+// a single group table needs one empty slot to stop probing
+func maxGrowth(capacity int) int {
+	if capacity <= slotsPerBucket {
+		return capacity - 1
+	}
+
+	return capacity * 7 / 8
+}
+
+func inspectSwiss(header unsafe.Pointer, sizes entrySizes) Info {
+	m := (*swissMap)(header)
+	group := groupSize(sizes)
+
+	info := Info{
+		Layout: LayoutSwiss,
+		Len:    int(m.used),
+		Bytes:  unsafe.Sizeof(*m) + uintptr(m.used)*sizes.indirect(),
+	}
+
+	if m.dirLen == 0 {
+		if m.dirPtr != nil {
+			info.Buckets = 1
+			info.Capacity = slotsPerBucket
+			info.Bytes += group
+		}
+
+		return info
+	}
+
+	// a table which has not split yet takes several sequential entries
+	directory := unsafe.Slice((**swissTable)(m.dirPtr), m.dirLen)
+	info.Bytes += uintptr(m.dirLen) * unsafe.Sizeof(directory[0])
+	for i, table := range directory {
+		if i > 0 && table == directory[i-1] {
+			continue
+		}
+
+		capacity := int(table.capacity)
+		info.Tables++
+		info.Buckets += capacity / slotsPerBucket
+		info.Capacity += capacity
+		info.Tombstones += maxGrowth(capacity) - int(table.used) - int(table.growthLeft)
+		info.Bytes += unsafe.Sizeof(*table) + uintptr(capacity/slotsPerBucket)*group
+	}
+
+	return info
+}
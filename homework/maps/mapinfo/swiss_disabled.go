@@ -0,0 +1,6 @@
+//go:build go1.24 && !go1.26 && !goexperiment.swissmap
+
+package mapinfo
+
+// GOEXPERIMENT=noswissmap keeps the old layout in Go 1.24 and 1.25
+const swissDisabled = true
@@ -0,0 +1,5 @@
+//go:build !go1.24 || go1.26 || goexperiment.swissmap
+
+package mapinfo
+
+const swissDisabled = false
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"cmp"
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v homewrok_test.go multimap_test.go
+
+// valueSet keeps values of one key of MultiMap
+type valueSet[V any] interface {
+	insert(value V) bool
+	erase(value V) bool
+	contains(value V) bool
+	len() int
+	all() iter.Seq[V]
+}
+
+type hashValues[V comparable] map[V]struct{}
+
+func (s hashValues[V]) insert(value V) bool {
+	if _, found := s[value]; found {
+		return false
+	}
+
+	s[value] = struct{}{}
+	return true
+}
+
+func (s hashValues[V]) erase(value V) bool {
+	if _, found := s[value]; !found {
+		return false
+	}
+
+	delete(s, value)
+	return true
+}
+
+func (s hashValues[V]) contains(value V) bool {
+	_, found := s[value]
+	return found
+}
+
+func (s hashValues[V]) len() int {
+	return len(s)
+}
+
+func (s hashValues[V]) all() iter.Seq[V] {
+	return maps.Keys(s)
+}
+
+type orderedValues[V any] struct {
+	values *OrderedMap[V, struct{}]
+}
+
+func (s orderedValues[V]) insert(value V) bool {
+	if s.values.Contains(value) {
+		return false
+	}
+
+	s.values.Insert(value, struct{}{})
+	return true
+}
+
+func (s orderedValues[V]) erase(value V) bool {
+	if !s.values.Contains(value) {
+		return false
+	}
+
+	s.values.Erase(value)
+	return true
+}
+
+func (s orderedValues[V]) contains(value V) bool {
+	return s.values.Contains(value)
+}
+
+func (s orderedValues[V]) len() int {
+	return s.values.Size()
+}
+
+func (s orderedValues[V]) all() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for value := range s.values.All() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// MultiMap maps a key to a set of values, a key without values
+// is removed. Values of a key are unordered or sorted depending on
+// the constructor. Iterators must not be used while the map changes.
+type MultiMap[K comparable, V any] struct {
+	values    map[K]valueSet[V]
+	size      int
+	newValues func() valueSet[V]
+}
+
+// NewMultiMap keeps values of a key in a hash set
+func NewMultiMap[K comparable, V comparable]() *MultiMap[K, V] {
+	return newMultiMap[K](func() valueSet[V] { return make(hashValues[V]) })
+}
+
+// NewOrderedMultiMap keeps values of a key sorted
+func NewOrderedMultiMap[K comparable, V cmp.Ordered]() *MultiMap[K, V] {
+	return NewOrderedMultiMapFunc[K](cmp.Compare[V])
+}
+
+// NewOrderedMultiMapFunc keeps values of a key sorted by compare,
+// values are equal when compare returns zero
+func NewOrderedMultiMapFunc[K comparable, V any](compare func(a, b V) int) *MultiMap[K, V] {
+	return newMultiMap[K](func() valueSet[V] {
+		return orderedValues[V]{values: NewOrderedMapFunc[V, struct{}](compare)}
+	})
+}
+
+func newMultiMap[K comparable, V any](newValues func() valueSet[V]) *MultiMap[K, V] {
+	return &MultiMap[K, V]{
+		values:    make(map[K]valueSet[V]),
+		newValues: newValues,
+	}
+}
+
+// Put reports whether the pair was added
+func (m *MultiMap[K, V]) Put(key K, value V) bool {
+	values, found := m.values[key]
+	if !found {
+		values = m.newValues()
+		m.values[key] = values
+	}
+
+	if !values.insert(value) {
+		return false
+	}
+
+	m.size++
+	return true
+}
+
+// Remove reports whether the pair was removed
+func (m *MultiMap[K, V]) Remove(key K, value V) bool {
+	values, found := m.values[key]
+	if !found || !values.erase(value) {
+		return false
+	}
+
+	m.size--
+	if values.len() == 0 {
+		delete(m.values, key)
+	}
+
+	return true
+}
+
+// RemoveAll returns the number of removed values
+func (m *MultiMap[K, V]) RemoveAll(key K) int {
+	values, found := m.values[key]
+	if !found {
+		return 0
+	}
+
+	delete(m.values, key)
+	m.size -= values.len()
+	return values.len()
+}
+
+func (m *MultiMap[K, V]) Contains(key K, value V) bool {
+	values, found := m.values[key]
+	return found && values.contains(value)
+}
+
+func (m *MultiMap[K, V]) ContainsKey(key K) bool {
+	_, found := m.values[key]
+	return found
+}
+
+// Get returns values of the key
+func (m *MultiMap[K, V]) Get(key K) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		values, found := m.values[key]
+		if !found {
+			return
+		}
+
+		for value := range values.all() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// Count returns the number of values of the key
+func (m *MultiMap[K, V]) Count(key K) int {
+	if values, found := m.values[key]; found {
+		return values.len()
+	}
+
+	return 0
+}
+
+// Len returns the number of pairs
+func (m *MultiMap[K, V]) Len() int {
+	return m.size
+}
+
+// KeysLen returns the number of keys with values
+func (m *MultiMap[K, V]) KeysLen() int {
+	return len(m.values)
+}
+
+func (m *MultiMap[K, V]) Keys() iter.Seq[K] {
+	return maps.Keys(m.values)
+}
+
+// All returns every pair, pairs of a key go together
+func (m *MultiMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for key, values := range m.values {
+			for value := range values.all() {
+				if !yield(key, value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Union returns pairs of both maps, values are kept like in m
+func (m *MultiMap[K, V]) Union(other *MultiMap[K, V]) *MultiMap[K, V] {
+	result := m.Clone()
+	for key, value := range other.All() {
+		result.Put(key, value)
+	}
+
+	return result
+}
+
+// Intersection returns pairs which are in both maps
+func (m *MultiMap[K, V]) Intersection(other *MultiMap[K, V]) *MultiMap[K, V] {
+	result := newMultiMap[K](m.newValues)
+	for key, value := range m.All() {
+		if other.Contains(key, value) {
+			result.Put(key, value)
+		}
+	}
+
+	return result
+}
+
+// Difference returns pairs of m which are not in other
+func (m *MultiMap[K, V]) Difference(other *MultiMap[K, V]) *MultiMap[K, V] {
+	result := newMultiMap[K](m.newValues)
+	for key, value := range m.All() {
+		if !other.Contains(key, value) {
+			result.Put(key, value)
+		}
+	}
+
+	return result
+}
+
+func (m *MultiMap[K, V]) Clone() *MultiMap[K, V] {
+	result := newMultiMap[K](m.newValues)
+	for key, value := range m.All() {
+		result.Put(key, value)
+	}
+
+	return result
+}
+
+func TestMultiMap(t *testing.T) {
+	permissions := NewMultiMap[string, string]()
+	assert.True(t, permissions.Put("alice", "read"))
+	assert.True(t, permissions.Put("alice", "write"))
+	assert.False(t, permissions.Put("alice", "read"))
+	assert.True(t, permissions.Put("bob", "read"))
+
+	assert.Equal(t, 3, permissions.Len())
+	assert.Equal(t, 2, permissions.KeysLen())
+	assert.Equal(t, 2, permissions.Count("alice"))
+	assert.Zero(t, permissions.Count("carol"))
+	assert.True(t, permissions.Contains("alice", "write"))
+	assert.False(t, permissions.Contains("bob", "write"))
+	assert.ElementsMatch(t, []string{"read", "write"}, slices.Collect(permissions.Get("alice")))
+	assert.Empty(t, slices.Collect(permissions.Get("carol")))
+	assert.ElementsMatch(t, []string{"alice", "bob"}, slices.Collect(permissions.Keys()))
+
+	assert.True(t, permissions.Remove("bob", "read"))
+	assert.False(t, permissions.Remove("bob", "read"))
+	assert.False(t, permissions.ContainsKey("bob")) // keys without values are removed
+
+	assert.Equal(t, 2, permissions.RemoveAll("alice"))
+	assert.Zero(t, permissions.RemoveAll("alice"))
+	assert.Zero(t, permissions.Len())
+}
+
+func TestOrderedMultiMap(t *testing.T) {
+	tags := NewOrderedMultiMap[string, string]()
+	for _, tag := range []string{"go", "backend", "maps", "backend"} {
+		tags.Put("post-1", tag)
+	}
+	tags.Put("post-2", "frontend")
+
+	assert.Equal(t, []string{"backend", "go", "maps"}, slices.Collect(tags.Get("post-1")))
+	assert.Equal(t, 4, tags.Len())
+
+	// values are equal when compare says so
+	byLength := NewOrderedMultiMapFunc[int](func(a, b string) int { return cmp.Compare(len(a), len(b)) })
+	assert.True(t, byLength.Put(1, "go"))
+	assert.False(t, byLength.Put(1, "js"))
+	assert.True(t, byLength.Put(1, "c"))
+	assert.Equal(t, []string{"c", "go"}, slices.Collect(byLength.Get(1)))
+
+	for key, value := range tags.All() {
+		if key == "post-1" {
+			assert.Equal(t, "backend", value)
+			break
+		}
+	}
+}
+
+func TestMultiMapSetOperations(t *testing.T) {
+	pairs := func(m *MultiMap[string, int]) map[string][]int {
+		result := make(map[string][]int)
+		for key, value := range m.All() {
+			result[key] = append(result[key], value)
+		}
+
+		return result
+	}
+
+	a := NewOrderedMultiMap[string, int]()
+	a.Put("x", 1)
+	a.Put("x", 2)
+	a.Put("y", 3)
+
+	b := NewOrderedMultiMap[string, int]()
+	b.Put("x", 2)
+	b.Put("x", 4)
+	b.Put("z", 5)
+
+	assert.Equal(t, map[string][]int{"x": {1, 2, 4}, "y": {3}, "z": {5}}, pairs(a.Union(b)))
+	assert.Equal(t, map[string][]int{"x": {2}}, pairs(a.Intersection(b)))
+	assert.Equal(t, map[string][]int{"x": {1}, "y": {3}}, pairs(a.Difference(b)))
+	assert.Equal(t, 3, a.Len()) // arguments are not changed
+	assert.Equal(t, 3, b.Len())
+
+	clone := a.Clone()
+	clone.Put("x", 10)
+	assert.False(t, a.Contains("x", 10))
+	assert.Equal(t, 4, clone.Len())
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v homewrok_test.go ordered_set_test.go
+
+var (
+	ErrNoComparator   = errors.New("set is created without comparator")
+	ErrDifferentOrder = errors.New("sets are ordered by different comparators")
+)
+
+// OrderedSet keeps keys sorted, it is the Set of generics lessons
+// backed by OrderedMap. Set operations walk both sets in order instead
+// of searching keys of one set in the other and keep the comparator
+// of the receiver. The zero OrderedSet is an empty set which can be
+// read, but keys can be inserted only into sets made by constructors.
+type OrderedSet[K any] struct {
+	keys *OrderedMap[K, struct{}]
+}
+
+func NewOrderedSetOf[K cmp.Ordered](keys ...K) *OrderedSet[K] {
+	return NewOrderedSetFunc(cmp.Compare[K], keys...)
+}
+
+func NewOrderedSetFunc[K any](compare func(a, b K) int, keys ...K) *OrderedSet[K] {
+	s := &OrderedSet[K]{keys: NewOrderedMapFunc[K, struct{}](compare)}
+	for _, key := range keys {
+		s.Insert(key)
+	}
+
+	return s
+}
+
+func (s *OrderedSet[K]) Insert(key K) {
+	s.keys.Insert(key, struct{}{})
+}
+
+func (s *OrderedSet[K]) Erase(key K) {
+	if s.keys != nil {
+		s.keys.Erase(key)
+	}
+}
+
+func (s *OrderedSet[K]) Contains(key K) bool {
+	return s.keys != nil && s.keys.Contains(key)
+}
+
+func (s *OrderedSet[K]) Len() int {
+	if s.keys == nil {
+		return 0
+	}
+
+	return s.keys.Size()
+}
+
+// All iterates keys in ascending order
+func (s *OrderedSet[K]) All() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		if s.keys == nil {
+			return
+		}
+
+		for key := range s.keys.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+func (s *OrderedSet[K]) Union(other *OrderedSet[K]) *OrderedSet[K] {
+	return s.merge(other, true, true, true)
+}
+
+func (s *OrderedSet[K]) Intersection(other *OrderedSet[K]) *OrderedSet[K] {
+	return s.merge(other, false, true, false)
+}
+
+func (s *OrderedSet[K]) Difference(other *OrderedSet[K]) *OrderedSet[K] {
+	return s.merge(other, true, false, false)
+}
+
+func (s *OrderedSet[K]) SymmetricDifference(other *OrderedSet[K]) *OrderedSet[K] {
+	return s.merge(other, true, false, true)
+}
+
+// IsSubset reports whether every key of s is in other,
+// it stops at the first key which other does not have
+func (s *OrderedSet[K]) IsSubset(other *OrderedSet[K]) bool {
+	if s.Len() > other.Len() {
+		return false
+	}
+	if s.Len() == 0 {
+		return true
+	}
+
+	compare := s.keys.compare
+	right := other.first()
+	for left := s.first(); left.Valid(); left.Next() {
+		for right.Valid() && compare(right.Key(), left.Key()) < 0 {
+			nextInOrder(right, compare)
+		}
+
+		if !right.Valid() || compare(right.Key(), left.Key()) != 0 {
+			return false
+		}
+		nextInOrder(right, compare)
+	}
+
+	return true
+}
+
+func (s *OrderedSet[K]) Equal(other *OrderedSet[K]) bool {
+	return s.Len() == other.Len() && s.IsSubset(other)
+}
+
+// merge walks both sets at once and keeps keys which are only
+// in s, in both sets or only in other. Keys of other are compared
+// by the comparator of s (of other when s is the zero set), so both
+// sets must be ordered the same way, merge panics with ErrDifferentOrder
+// when it meets keys of other out of this order.
+func (s *OrderedSet[K]) merge(other *OrderedSet[K], onlyLeft, both, onlyRight bool) *OrderedSet[K] {
+	keys := s.keys
+	if keys == nil {
+		keys = other.keys
+	}
+	if keys == nil {
+		return &OrderedSet[K]{}
+	}
+
+	compare := keys.compare
+	result := &OrderedSet[K]{keys: NewOrderedMapFunc[K, struct{}](compare)}
+
+	left, right := s.first(), other.first()
+	for left.Valid() || right.Valid() {
+		order := 0
+		switch {
+		case !right.Valid():
+			order = -1
+		case !left.Valid():
+			order = 1
+		default:
+			order = compare(left.Key(), right.Key())
+		}
+
+		switch {
+		case order < 0:
+			if onlyLeft {
+				result.Insert(left.Key())
+			}
+			left.Next()
+		case order > 0:
+			if onlyRight {
+				result.Insert(right.Key())
+			}
+			nextInOrder(right, compare)
+		default:
+			if both {
+				result.Insert(left.Key())
+			}
+			left.Next()
+			nextInOrder(right, compare)
+		}
+	}
+
+	return result
+}
+
+// first returns cursor at the smallest key, it is not valid for empty sets
+func (s *OrderedSet[K]) first() *Cursor[K, struct{}] {
+	if s.keys == nil {
+		return &Cursor[K, struct{}]{}
+	}
+
+	return s.keys.First()
+}
+
+// nextInOrder moves the cursor of another set and checks that
+// its keys are ascending according to compare as well
+func nextInOrder[K any](cursor *Cursor[K, struct{}], compare func(a, b K) int) {
+	previous := cursor.Key()
+	cursor.Next()
+	if cursor.Valid() && compare(previous, cursor.Key()) >= 0 {
+		panic(ErrDifferentOrder)
+	}
+}
+
+// MarshalJSON writes keys as an array in ascending order. It has
+// a value receiver like Set to work for sets stored by value.
+func (s OrderedSet[K]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(slices.AppendSeq(make([]K, 0, s.Len()), s.All()))
+}
+
+// UnmarshalJSON replaces keys of the set, it needs the comparator,
+// so the set has to be created by a constructor
+func (s *OrderedSet[K]) UnmarshalJSON(data []byte) error {
+	if s.keys == nil {
+		return ErrNoComparator
+	}
+
+	var keys []K
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+
+	*s = *NewOrderedSetFunc(s.keys.compare, keys...)
+	return nil
+}
+
+func TestOrderedSet(t *testing.T) {
+	set := NewOrderedSetOf(3, 1, 2, 1)
+	assert.Equal(t, 3, set.Len())
+	assert.Equal(t, []int{1, 2, 3}, slices.Collect(set.All()))
+	assert.True(t, set.Contains(2))
+
+	set.Erase(2)
+	set.Erase(10)
+	assert.False(t, set.Contains(2))
+	assert.Equal(t, []int{1, 3}, slices.Collect(set.All()))
+
+	for key := range set.All() {
+		assert.Equal(t, 1, key)
+		break
+	}
+}
+
+func TestOrderedSetAlgebra(t *testing.T) {
+	a := NewOrderedSetOf(1, 2, 3, 5)
+	b := NewOrderedSetOf(3, 4, 5, 6)
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, slices.Collect(a.Union(b).All()))
+	assert.Equal(t, []int{3, 5}, slices.Collect(a.Intersection(b).All()))
+	assert.Equal(t, []int{1, 2}, slices.Collect(a.Difference(b).All()))
+	assert.Equal(t, []int{1, 2, 4, 6}, slices.Collect(a.SymmetricDifference(b).All()))
+	assert.Equal(t, 4, a.Len())
+	assert.Equal(t, 4, b.Len())
+
+	assert.True(t, a.Intersection(b).IsSubset(b))
+	assert.False(t, a.IsSubset(b))
+	assert.True(t, a.Equal(NewOrderedSetOf(5, 3, 2, 1)))
+	assert.False(t, a.Equal(b))
+
+	empty := NewOrderedSetOf[int]()
+	assert.True(t, empty.IsSubset(a))
+	assert.True(t, a.Union(empty).Equal(a))
+	assert.Zero(t, a.Intersection(empty).Len())
+
+	// keys are equal when the comparator says so
+	foldCase := func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) }
+	names := NewOrderedSetFunc(foldCase, "Go", "rust")
+	other := NewOrderedSetFunc(foldCase, "go", "Zig")
+	assert.Equal(t, []string{"Go"}, slices.Collect(names.Intersection(other).All()))
+	assert.Equal(t, []string{"Go", "rust", "Zig"}, slices.Collect(names.Union(other).All()))
+}
+
+func TestOrderedSetIsSubset(t *testing.T) {
+	a := NewOrderedSetOf(2, 4, 6)
+	assert.True(t, a.IsSubset(NewOrderedSetOf(1, 2, 3, 4, 5, 6)))
+	assert.True(t, a.IsSubset(a))
+	assert.False(t, a.IsSubset(NewOrderedSetOf(2, 4, 5, 7)))
+	assert.False(t, a.IsSubset(NewOrderedSetOf(1, 2, 4)))
+	assert.False(t, a.IsSubset(NewOrderedSetOf(2, 3)))
+}
+
+func TestOrderedSetDifferentOrder(t *testing.T) {
+	ascending := NewOrderedSetOf(1, 2, 3)
+	descending := NewOrderedSetFunc(func(a, b int) int { return b - a }, 1, 2, 3)
+
+	assert.PanicsWithValue(t, ErrDifferentOrder, func() { ascending.Union(descending) })
+	assert.PanicsWithValue(t, ErrDifferentOrder, func() { NewOrderedSetOf(3).IsSubset(descending) })
+}
+
+func TestOrderedSetJSON(t *testing.T) {
+	data, err := json.Marshal(NewOrderedSetOf(10, 2, 33))
+	require.NoError(t, err)
+	assert.Equal(t, `[2,10,33]`, string(data))
+
+	data, err = json.Marshal(NewOrderedSetOf[int]())
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, string(data))
+
+	decoded := NewOrderedSetOf[string]()
+	require.NoError(t, json.Unmarshal([]byte(`["b","a","b"]`), decoded))
+	assert.Equal(t, []string{"a", "b"}, slices.Collect(decoded.All()))
+
+	var zero OrderedSet[string]
+	assert.ErrorIs(t, json.Unmarshal([]byte(`["a"]`), &zero), ErrNoComparator)
+	assert.Error(t, json.Unmarshal([]byte(`[1]`), decoded))
+
+	// sets stored by value are not addressable
+	data, err = json.Marshal(map[string]OrderedSet[int]{"ids": *NewOrderedSetOf(10, 2), "empty": {}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"empty":[],"ids":[2,10]}`, string(data))
+}
+
+func TestOrderedSetZero(t *testing.T) {
+	var zero OrderedSet[int]
+	assert.Zero(t, zero.Len())
+	assert.False(t, zero.Contains(1))
+	assert.Empty(t, slices.Collect(zero.All()))
+	zero.Erase(1)
+
+	set := NewOrderedSetOf(2, 1)
+	assert.True(t, zero.IsSubset(set))
+	assert.Equal(t, []int{1, 2}, slices.Collect(zero.Union(set).All()))
+	assert.Equal(t, []int{1, 2}, slices.Collect(set.Union(&zero).All()))
+	assert.Zero(t, zero.Intersection(&OrderedSet[int]{}).Len())
+	assert.True(t, zero.Equal(&OrderedSet[int]{}))
+}
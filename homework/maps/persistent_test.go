@@ -0,0 +1,482 @@
+package main
+
+import (
+	"cmp"
+	"iter"
+	"maps"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -race homewrok_test.go persistent_test.go
+
+// persistentNode is never changed after creation, so subtrees
+// can be shared by any number of map versions
+type persistentNode[K any, V any] struct {
+	key    K
+	value  V
+	left   *persistentNode[K, V]
+	right  *persistentNode[K, V]
+	height int
+	count  int
+}
+
+func newPersistentNode[K any, V any](key K, value V, left, right *persistentNode[K, V]) *persistentNode[K, V] {
+	return &persistentNode[K, V]{
+		key:    key,
+		value:  value,
+		left:   left,
+		right:  right,
+		height: 1 + max(left.getHeight(), right.getHeight()),
+		count:  1 + left.getCount() + right.getCount(),
+	}
+}
+
+func (n *persistentNode[K, V]) getHeight() int {
+	if n == nil {
+		return 0
+	}
+
+	return n.height
+}
+
+func (n *persistentNode[K, V]) getCount() int {
+	if n == nil {
+		return 0
+	}
+
+	return n.count
+}
+
+// balancedPersistentNode creates a node with AVL balance: heights of
+// subtrees differ at most by one, rotations create new nodes
+// instead of changing existing ones
+func balancedPersistentNode[K any, V any](key K, value V, left, right *persistentNode[K, V]) *persistentNode[K, V] {
+	switch {
+	case left.getHeight() > right.getHeight()+1:
+		if left.left.getHeight() >= left.right.getHeight() {
+			return newPersistentNode(left.key, left.value, left.left,
+				newPersistentNode(key, value, left.right, right))
+		}
+
+		middle := left.right
+		return newPersistentNode(middle.key, middle.value,
+			newPersistentNode(left.key, left.value, left.left, middle.left),
+			newPersistentNode(key, value, middle.right, right))
+	case right.getHeight() > left.getHeight()+1:
+		if right.right.getHeight() >= right.left.getHeight() {
+			return newPersistentNode(right.key, right.value,
+				newPersistentNode(key, value, left, right.left), right.right)
+		}
+
+		middle := right.left
+		return newPersistentNode(middle.key, middle.value,
+			newPersistentNode(key, value, left, middle.left),
+			newPersistentNode(right.key, right.value, middle.right, right.right))
+	default:
+		return newPersistentNode(key, value, left, right)
+	}
+}
+
+// PersistentMap is an immutable ordered map: Insert and Erase return
+// a new version which shares all nodes except O(log n) copied ones
+// on the path to the changed key. Versions are safe for concurrent
+// reads without locks, a copy of the map is an O(1) snapshot.
+type PersistentMap[K any, V any] struct {
+	root    *persistentNode[K, V]
+	compare func(a, b K) int
+}
+
+func NewPersistentMapFunc[K any, V any](compare func(a, b K) int) PersistentMap[K, V] {
+	return PersistentMap[K, V]{compare: compare}
+}
+
+func NewPersistentMapOf[K cmp.Ordered, V any]() PersistentMap[K, V] {
+	return NewPersistentMapFunc[K, V](cmp.Compare[K])
+}
+
+func (m PersistentMap[K, V]) Insert(key K, value V) PersistentMap[K, V] {
+	m.root = m.insert(m.root, key, value)
+	return m
+}
+
+// insert recurses only O(log n) levels deep because the tree is balanced
+func (m PersistentMap[K, V]) insert(n *persistentNode[K, V], key K, value V) *persistentNode[K, V] {
+	if n == nil {
+		return newPersistentNode(key, value, nil, nil)
+	}
+
+	order := m.compare(key, n.key)
+	switch {
+	case order < 0:
+		return balancedPersistentNode(n.key, n.value, m.insert(n.left, key, value), n.right)
+	case order > 0:
+		return balancedPersistentNode(n.key, n.value, n.left, m.insert(n.right, key, value))
+	default:
+		return newPersistentNode(key, value, n.left, n.right)
+	}
+}
+
+// Erase returns the map itself when the key is absent
+func (m PersistentMap[K, V]) Erase(key K) PersistentMap[K, V] {
+	m.root = m.erase(m.root, key)
+	return m
+}
+
+func (m PersistentMap[K, V]) erase(n *persistentNode[K, V], key K) *persistentNode[K, V] {
+	if n == nil {
+		return nil
+	}
+
+	order := m.compare(key, n.key)
+	switch {
+	case order < 0:
+		left := m.erase(n.left, key)
+		if left == n.left {
+			return n // nothing is removed, keep sharing
+		}
+		return balancedPersistentNode(n.key, n.value, left, n.right)
+	case order > 0:
+		right := m.erase(n.right, key)
+		if right == n.right {
+			return n
+		}
+		return balancedPersistentNode(n.key, n.value, n.left, right)
+	case n.left == nil:
+		return n.right
+	case n.right == nil:
+		return n.left
+	default:
+		next := n.right
+		for next.left != nil {
+			next = next.left
+		}
+		return balancedPersistentNode(next.key, next.value, n.left, eraseMinimum(n.right))
+	}
+}
+
+func eraseMinimum[K any, V any](n *persistentNode[K, V]) *persistentNode[K, V] {
+	if n.left == nil {
+		return n.right
+	}
+
+	return balancedPersistentNode(n.key, n.value, eraseMinimum(n.left), n.right)
+}
+
+func (m PersistentMap[K, V]) find(key K) *persistentNode[K, V] {
+	current := m.root
+	for current != nil {
+		order := m.compare(key, current.key)
+		switch {
+		case order == 0:
+			return current
+		case order < 0:
+			current = current.left
+		default:
+			current = current.right
+		}
+	}
+
+	return nil
+}
+
+func (m PersistentMap[K, V]) Get(key K) (V, bool) {
+	n := m.find(key)
+	if n == nil {
+		var empty V
+		return empty, false
+	}
+
+	return n.value, true
+}
+
+func (m PersistentMap[K, V]) Contains(key K) bool {
+	return m.find(key) != nil
+}
+
+func (m PersistentMap[K, V]) Size() int {
+	return m.root.getCount()
+}
+
+// All iterates keys in ascending order, the version can not change
+// during iteration, so no care is needed about concurrent writers
+func (m PersistentMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		var stack []*persistentNode[K, V]
+		current := m.root
+		for current != nil || len(stack) != 0 {
+			for current != nil {
+				stack = append(stack, current)
+				current = current.left
+			}
+
+			current = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !yield(current.key, current.value) {
+				return
+			}
+			current = current.right
+		}
+	}
+}
+
+// AtomicPersistentMap publishes versions of a persistent map: readers
+// take the current version without locks, writers replace it with
+// compare-and-swap and retry when another writer was faster
+type AtomicPersistentMap[K any, V any] struct {
+	current atomic.Pointer[PersistentMap[K, V]]
+}
+
+func NewAtomicPersistentMap[K any, V any](initial PersistentMap[K, V]) *AtomicPersistentMap[K, V] {
+	m := &AtomicPersistentMap[K, V]{}
+	m.current.Store(&initial)
+	return m
+}
+
+// Load returns a snapshot which is not affected by later updates
+func (m *AtomicPersistentMap[K, V]) Load() PersistentMap[K, V] {
+	return *m.current.Load()
+}
+
+// Update applies the function to the current version, the function
+// can be called several times under contention, so it must be pure
+func (m *AtomicPersistentMap[K, V]) Update(update func(PersistentMap[K, V]) PersistentMap[K, V]) {
+	for {
+		current := m.current.Load()
+		next := update(*current)
+		if m.current.CompareAndSwap(current, &next) {
+			return
+		}
+	}
+}
+
+// checkPersistentTree verifies AVL balance, heights, counts and order
+func checkPersistentTree[K any, V any](t *testing.T, m PersistentMap[K, V]) {
+	t.Helper()
+
+	var walk func(n *persistentNode[K, V])
+	walk = func(n *persistentNode[K, V]) {
+		if n == nil {
+			return
+		}
+
+		require.LessOrEqual(t, abs(n.left.getHeight()-n.right.getHeight()), 1, "unbalanced at %v", n.key)
+		require.Equal(t, 1+max(n.left.getHeight(), n.right.getHeight()), n.height)
+		require.Equal(t, 1+n.left.getCount()+n.right.getCount(), n.count)
+		if n.left != nil {
+			require.Negative(t, m.compare(n.left.key, n.key))
+		}
+		if n.right != nil {
+			require.Positive(t, m.compare(n.right.key, n.key))
+		}
+
+		walk(n.left)
+		walk(n.right)
+	}
+
+	walk(m.root)
+}
+
+func abs(value int) int {
+	return max(value, -value)
+}
+
+func collectKeys[K any, V any](m PersistentMap[K, V]) []K {
+	var keys []K
+	for key := range m.All() {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func TestPersistentMapVersions(t *testing.T) {
+	empty := NewPersistentMapOf[int, string]()
+	first := empty.Insert(2, "two").Insert(1, "one")
+	second := first.Insert(3, "three").Insert(1, "ONE")
+	third := second.Erase(2)
+
+	assert.Zero(t, empty.Size())
+	assert.Equal(t, []int{1, 2}, collectKeys(first))
+	assert.Equal(t, []int{1, 2, 3}, collectKeys(second))
+	assert.Equal(t, []int{1, 3}, collectKeys(third))
+
+	value, _ := first.Get(1)
+	assert.Equal(t, "one", value)
+	value, _ = second.Get(1)
+	assert.Equal(t, "ONE", value)
+	assert.True(t, second.Contains(2))
+	assert.False(t, third.Contains(2))
+
+	// erasing an absent key does not copy anything
+	assert.Same(t, third.root, third.Erase(100).root)
+}
+
+func TestPersistentMapSharing(t *testing.T) {
+	m := NewPersistentMapOf[int, int]()
+	for key := range 1_024 {
+		m = m.Insert(key, key)
+	}
+
+	// keys of the right half are changed, the left half is shared
+	updated := m.Insert(1_000, -1)
+	assert.Same(t, m.root.left, updated.root.left)
+	assert.NotSame(t, m.root.right, updated.root.right)
+
+	old := make(map[*persistentNode[int, int]]bool)
+	var collect func(n *persistentNode[int, int])
+	collect = func(n *persistentNode[int, int]) {
+		if n != nil {
+			old[n] = true
+			collect(n.left)
+			collect(n.right)
+		}
+	}
+	collect(m.root)
+
+	shared := 0
+	var countShared func(n *persistentNode[int, int])
+	countShared = func(n *persistentNode[int, int]) {
+		if n == nil {
+			return
+		}
+		if old[n] {
+			shared += n.count
+			return
+		}
+		countShared(n.left)
+		countShared(n.right)
+	}
+	countShared(updated.root)
+
+	// only the path to the key is copied
+	assert.GreaterOrEqual(t, shared, m.Size()-m.root.height)
+}
+
+func TestPersistentMapRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	m := NewPersistentMapOf[int, int]()
+	model := make(map[int]int)
+
+	var versions []PersistentMap[int, int]
+	var models []map[int]int
+	for i := 0; i < 5_000; i++ {
+		key := random.Intn(300)
+		if random.Intn(3) == 0 {
+			m = m.Erase(key)
+			delete(model, key)
+		} else {
+			m = m.Insert(key, i)
+			model[key] = i
+		}
+
+		if i%500 == 0 {
+			versions = append(versions, m)
+			models = append(models, maps.Clone(model))
+		}
+	}
+
+	// old versions are not affected by later changes
+	for i, version := range versions {
+		checkPersistentTree(t, version)
+		require.Equal(t, len(models[i]), version.Size())
+		require.Equal(t, slices.Sorted(maps.Keys(models[i])), collectKeys(version))
+		for key, value := range models[i] {
+			actual, _ := version.Get(key)
+			require.Equal(t, value, actual)
+		}
+	}
+}
+
+func TestAtomicPersistentMap(t *testing.T) {
+	const writers, keysPerWriter = 4, 500
+	config := NewAtomicPersistentMap(NewPersistentMapOf[int, int]())
+
+	var wg sync.WaitGroup
+	wg.Add(writers)
+	for writer := range writers {
+		go func() {
+			defer wg.Done()
+			for i := range keysPerWriter {
+				key := writer*keysPerWriter + i
+				config.Update(func(m PersistentMap[int, int]) PersistentMap[int, int] {
+					return m.Insert(key, key)
+				})
+			}
+		}()
+	}
+
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		previous := 0
+		for previous < writers*keysPerWriter {
+			snapshot := config.Load()
+			size := 0
+			for key, value := range snapshot.All() {
+				assert.Equal(t, key, value)
+				size++
+			}
+
+			assert.Equal(t, snapshot.Size(), size)
+			assert.GreaterOrEqual(t, size, previous, "versions only grow")
+			previous = size
+		}
+	}()
+
+	wg.Wait()
+	readers.Wait()
+	assert.Equal(t, writers*keysPerWriter, config.Load().Size())
+}
+
+const snapshotKeysNumber = 10_000
+
+func BenchmarkSnapshot(b *testing.B) {
+	b.Run("deep copy under lock", func(b *testing.B) {
+		var mutex sync.RWMutex
+		config := make(map[int]int, snapshotKeysNumber)
+		for key := range snapshotKeysNumber {
+			config[key] = key
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mutex.RLock()
+			snapshot := maps.Clone(config)
+			mutex.RUnlock()
+			_ = snapshot
+		}
+	})
+
+	b.Run("persistent", func(b *testing.B) {
+		m := NewPersistentMapOf[int, int]()
+		for key := range snapshotKeysNumber {
+			m = m.Insert(key, key)
+		}
+		config := NewAtomicPersistentMap(m)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = config.Load()
+		}
+	})
+}
+
+func BenchmarkPersistentMap_Insert(b *testing.B) {
+	m := NewPersistentMapOf[int, int]()
+	for key := range snapshotKeysNumber {
+		m = m.Insert(key, key)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m = m.Insert(i%snapshotKeysNumber, i)
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"fmt"
+	"hash/maphash"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -race -bench=. homewrok_test.go sharded_map_test.go
+
+// Hasher maps a key to a shard, the seed is random for every map
+type Hasher[K any] func(seed maphash.Seed, key K) uint64
+
+// ComparableHasher hashes any comparable key like the builtin map does
+func ComparableHasher[K comparable]() Hasher[K] {
+	return maphash.Comparable[K]
+}
+
+// StringHasher is faster than ComparableHasher for string keys
+func StringHasher(seed maphash.Seed, key string) uint64 {
+	return maphash.String(seed, key)
+}
+
+const cacheLineSize = 64 // the most common size
+
+// sizes of shard parts, map[K]V has the same size for all K and V
+const (
+	lockedSize   = unsafe.Sizeof(sync.RWMutex{}) + unsafe.Sizeof(map[int]int(nil))
+	countersSize = unsafe.Sizeof(shardCounters{})
+)
+
+// shardCounters are updated outside of the lock, so they are kept on
+// their own cache line and do not slow down the mutex of the shard
+type shardCounters struct {
+	loads     atomic.Uint64
+	misses    atomic.Uint64
+	stores    atomic.Uint64
+	deletes   atomic.Uint64
+	contended atomic.Uint64 // lock acquisitions which had to wait
+}
+
+type shard[K comparable, V any] struct {
+	mutex  sync.RWMutex
+	values map[K]V
+	_      [cacheLineSize - lockedSize%cacheLineSize]byte
+
+	counters shardCounters
+	_        [cacheLineSize - countersSize%cacheLineSize]byte // neighbour shards are locked independently
+}
+
+func (s *shard[K, V]) lock() {
+	if !s.mutex.TryLock() {
+		s.counters.contended.Add(1)
+		s.mutex.Lock()
+	}
+}
+
+func (s *shard[K, V]) rlock() {
+	if !s.mutex.TryRLock() {
+		s.counters.contended.Add(1)
+		s.mutex.RLock()
+	}
+}
+
+// ShardStats shows how keys and load are spread over shards
+type ShardStats struct {
+	Len       int
+	Loads     uint64
+	Misses    uint64
+	Stores    uint64
+	Deletes   uint64
+	Contended uint64
+}
+
+// ShardedMap splits keys over independently locked maps, so writers
+// of different keys rarely wait for each other
+type ShardedMap[K comparable, V any] struct {
+	shards []shard[K, V]
+	mask   uint64
+	seed   maphash.Seed
+	hasher Hasher[K]
+}
+
+// NewShardedMap rounds shardsNumber up to a power of two, not positive
+// number selects 4 shards per processor
+func NewShardedMap[K comparable, V any](shardsNumber int) *ShardedMap[K, V] {
+	return NewShardedMapFunc[K, V](shardsNumber, ComparableHasher[K]())
+}
+
+func NewShardedMapFunc[K comparable, V any](shardsNumber int, hasher Hasher[K]) *ShardedMap[K, V] {
+	if shardsNumber <= 0 {
+		shardsNumber = 4 * runtime.GOMAXPROCS(0)
+	}
+
+	size := 1
+	for size < shardsNumber {
+		size <<= 1
+	}
+
+	m := &ShardedMap[K, V]{
+		shards: make([]shard[K, V], size),
+		mask:   uint64(size - 1),
+		seed:   maphash.MakeSeed(),
+		hasher: hasher,
+	}
+
+	for i := range m.shards {
+		m.shards[i].values = make(map[K]V)
+	}
+
+	return m
+}
+
+func (m *ShardedMap[K, V]) shard(key K) *shard[K, V] {
+	return &m.shards[m.hasher(m.seed, key)&m.mask]
+}
+
+func (m *ShardedMap[K, V]) Load(key K) (V, bool) {
+	s := m.shard(key)
+	s.rlock()
+	value, found := s.values[key]
+	s.mutex.RUnlock()
+
+	s.counters.loads.Add(1)
+	if !found {
+		s.counters.misses.Add(1)
+	}
+
+	return value, found
+}
+
+func (m *ShardedMap[K, V]) Store(key K, value V) {
+	s := m.shard(key)
+	s.lock()
+	s.values[key] = value
+	s.mutex.Unlock()
+
+	s.counters.stores.Add(1)
+}
+
+// LoadOrStore returns the existing value or stores the given one,
+// loaded reports whether the value was found
+func (m *ShardedMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	s := m.shard(key)
+	s.rlock()
+	actual, loaded = s.values[key]
+	s.mutex.RUnlock()
+
+	s.counters.loads.Add(1)
+	if loaded {
+		return actual, true
+	}
+
+	s.lock()
+	defer s.mutex.Unlock()
+
+	// another goroutine could store the key between locks
+	if actual, loaded = s.values[key]; loaded {
+		return actual, true
+	}
+
+	s.counters.misses.Add(1)
+	s.counters.stores.Add(1)
+	s.values[key] = value
+	return value, false
+}
+
+func (m *ShardedMap[K, V]) Delete(key K) {
+	s := m.shard(key)
+	s.lock()
+	delete(s.values, key)
+	s.mutex.Unlock()
+
+	s.counters.deletes.Add(1)
+}
+
+// Compute replaces the value by the result of update atomically,
+// the key is deleted when keep is false. The shard is locked while
+// update runs, so update must not use the map.
+func (m *ShardedMap[K, V]) Compute(key K, update func(old V, loaded bool) (value V, keep bool)) (V, bool) {
+	s := m.shard(key)
+	s.lock()
+	defer s.mutex.Unlock()
+
+	old, loaded := s.values[key]
+	value, keep := update(old, loaded)
+	if !keep {
+		s.counters.deletes.Add(1)
+		delete(s.values, key)
+		var empty V
+		return empty, false
+	}
+
+	s.counters.stores.Add(1)
+	s.values[key] = value
+	return value, true
+}
+
+// Range calls action for copies of shards one by one, so action can
+// use the map, but it is not a consistent snapshot of the whole map
+func (m *ShardedMap[K, V]) Range(action func(K, V) bool) {
+	type entry struct {
+		key   K
+		value V
+	}
+
+	var entries []entry
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.rlock()
+		entries = entries[:0]
+		for key, value := range s.values {
+			entries = append(entries, entry{key: key, value: value})
+		}
+		s.mutex.RUnlock()
+
+		for _, e := range entries {
+			if !action(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+func (m *ShardedMap[K, V]) Len() int {
+	length := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.rlock()
+		length += len(s.values)
+		s.mutex.RUnlock()
+	}
+
+	return length
+}
+
+func (m *ShardedMap[K, V]) Stats() []ShardStats {
+	stats := make([]ShardStats, len(m.shards))
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mutex.RLock()
+		stats[i].Len = len(s.values)
+		s.mutex.RUnlock()
+
+		stats[i].Loads = s.counters.loads.Load()
+		stats[i].Misses = s.counters.misses.Load()
+		stats[i].Stores = s.counters.stores.Load()
+		stats[i].Deletes = s.counters.deletes.Load()
+		stats[i].Contended = s.counters.contended.Load()
+	}
+
+	return stats
+}
+
+func TestShardedMap(t *testing.T) {
+	m := NewShardedMap[string, int](5)
+	assert.Len(t, m.shards, 8)
+
+	_, found := m.Load("a")
+	assert.False(t, found)
+
+	m.Store("a", 1)
+	m.Store("b", 2)
+	value, found := m.Load("a")
+	assert.True(t, found)
+	assert.Equal(t, 1, value)
+
+	actual, loaded := m.LoadOrStore("a", 10)
+	assert.True(t, loaded)
+	assert.Equal(t, 1, actual)
+	actual, loaded = m.LoadOrStore("c", 3)
+	assert.False(t, loaded)
+	assert.Equal(t, 3, actual)
+
+	m.Delete("b")
+	assert.Equal(t, 2, m.Len())
+
+	value, kept := m.Compute("a", func(old int, loaded bool) (int, bool) { return old + 1, true })
+	assert.True(t, kept)
+	assert.Equal(t, 2, value)
+	_, kept = m.Compute("c", func(int, bool) (int, bool) { return 0, false })
+	assert.False(t, kept)
+	_, found = m.Load("c")
+	assert.False(t, found)
+
+	entries := make(map[string]int)
+	m.Range(func(key string, value int) bool {
+		entries[key] = value
+		return true
+	})
+	assert.Equal(t, map[string]int{"a": 2}, entries)
+}
+
+func TestShardedMapStats(t *testing.T) {
+	// all keys in one shard show a hotspot
+	m := NewShardedMapFunc[string, int](4, func(maphash.Seed, string) uint64 { return 2 })
+	for i := range 10 {
+		m.Store(strconv.Itoa(i), i)
+	}
+	m.Load("1")
+	m.Load("missing")
+	m.Delete("2")
+	m.Compute("3", func(int, bool) (int, bool) { return 0, false })
+
+	stats := m.Stats()
+	require.Len(t, stats, 4)
+	assert.Equal(t, ShardStats{Len: 8, Loads: 2, Misses: 1, Stores: 10, Deletes: 2}, stats[2])
+	assert.Equal(t, ShardStats{}, stats[0])
+
+	// the default hasher spreads keys
+	spread := NewShardedMap[string, int](4)
+	for i := range 1_000 {
+		spread.Store(strconv.Itoa(i), i)
+	}
+	for _, shardStats := range spread.Stats() {
+		assert.Greater(t, shardStats.Len, 150)
+	}
+
+	custom := NewShardedMapFunc[string, int](4, StringHasher)
+	custom.Store("a", 1)
+	value, _ := custom.Load("a")
+	assert.Equal(t, 1, value)
+}
+
+func TestShardLayout(t *testing.T) {
+	var s shard[string, int]
+	// the mutex and the counters start separate cache lines
+	assert.Equal(t, uintptr(cacheLineSize), unsafe.Offsetof(s.counters))
+	assert.Equal(t, uintptr(2*cacheLineSize), unsafe.Sizeof(s))
+}
+
+func TestShardedMapConcurrent(t *testing.T) {
+	const goroutines, increments = 8, 1_000
+	m := NewShardedMap[int, int](0)
+
+	var winners atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for g := range goroutines {
+		go func() {
+			defer wg.Done()
+			for i := range increments {
+				m.Compute(i%10, func(old int, _ bool) (int, bool) { return old + 1, true })
+			}
+			if _, loaded := m.LoadOrStore(-1, g); !loaded {
+				winners.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), winners.Load())
+	for key := range 10 {
+		value, _ := m.Load(key)
+		assert.Equal(t, goroutines*increments/10, value)
+	}
+
+	// action can change the map without a deadlock
+	m.Range(func(key, _ int) bool {
+		m.Delete(key)
+		return true
+	})
+	assert.Zero(t, m.Len())
+}
+
+// lockedMap is the single mutex map from lessons rw_mutex_with_map
+type lockedMap[K comparable, V any] struct {
+	mutex  sync.RWMutex
+	values map[K]V
+}
+
+func (m *lockedMap[K, V]) Load(key K) (V, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	value, found := m.values[key]
+	return value, found
+}
+
+func (m *lockedMap[K, V]) Store(key K, value V) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.values[key] = value
+}
+
+type concurrentMap interface {
+	Load(key string) (int, bool)
+	Store(key string, value int)
+}
+
+type syncMap struct {
+	sync.Map
+}
+
+func (m *syncMap) Load(key string) (int, bool) {
+	value, found := m.Map.Load(key)
+	if !found {
+		return 0, false
+	}
+
+	return value.(int), true
+}
+
+func (m *syncMap) Store(key string, value int) {
+	m.Map.Store(key, value)
+}
+
+const benchmarkSessionsNumber = 10_000
+
+func benchmarkConcurrentMap(b *testing.B, newMap func() concurrentMap) {
+	keys := make([]string, benchmarkSessionsNumber)
+	for i := range keys {
+		keys[i] = fmt.Sprint("session-", i)
+	}
+
+	for _, writePercent := range []int{1, 10, 50} {
+		b.Run(fmt.Sprint("writes=", writePercent, "%"), func(b *testing.B) {
+			m := newMap()
+			for i, key := range keys {
+				m.Store(key, i)
+			}
+
+			var seed atomic.Uint64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := seed.Add(7919)
+				for pb.Next() {
+					i++
+					key := keys[i%benchmarkSessionsNumber]
+					if i%100 < uint64(writePercent) {
+						m.Store(key, int(i))
+					} else {
+						m.Load(key)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkConcurrentMaps(b *testing.B) {
+	b.Run("sharded", func(b *testing.B) {
+		benchmarkConcurrentMap(b, func() concurrentMap { return NewShardedMap[string, int](0) })
+	})
+	b.Run("sharded string hasher", func(b *testing.B) {
+		benchmarkConcurrentMap(b, func() concurrentMap { return NewShardedMapFunc[string, int](0, StringHasher) })
+	})
+	b.Run("sync.Map", func(b *testing.B) {
+		benchmarkConcurrentMap(b, func() concurrentMap { return &syncMap{} })
+	})
+	b.Run("single mutex", func(b *testing.B) {
+		benchmarkConcurrentMap(b, func() concurrentMap { return &lockedMap[string, int]{values: make(map[string]int)} })
+	})
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"hash/maphash"
+	"iter"
+	"math"
+	"math/bits"
+	"math/rand"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -bench=. homewrok_test.go swiss_map_test.go
+
+const (
+	swissGroupSize  = 8
+	ctrlEmpty       = 0x80 // full slots keep 7 bits of the hash, so the high bit is clear
+	ctrlEmptyGroup  = 0x8080808080808080
+	ctrlLowBits     = 0x0101010101010101
+	ctrlHighBits    = 0x8080808080808080
+	defaultMaxLoad  = 7.0 / 8
+	minSwissGroups  = 1
+	swissHashH2Bits = 7
+)
+
+// slotMask has the high bit set in bytes of matching slots
+type slotMask uint64
+
+func (m slotMask) first() int {
+	return bits.TrailingZeros64(uint64(m)) / 8
+}
+
+func (m slotMask) withoutFirst() slotMask {
+	return m & (m - 1)
+}
+
+func (m slotMask) count() int {
+	return bits.OnesCount64(uint64(m))
+}
+
+// swissGroup keeps 8 control bytes in one word, so all slots of
+// the group are compared with a few arithmetic operations
+type swissGroup[K comparable, V any] struct {
+	ctrl   uint64
+	keys   [swissGroupSize]K
+	values [swissGroupSize]V
+}
+
+// match finds bytes equal to h2: xor turns them into zeros and zero bytes
+// are detected by the borrow of subtraction. Borrows can give false
+// positives next to a real match, they are filtered by key comparison.
+func (g *swissGroup[K, V]) match(h2 uint8) slotMask {
+	x := g.ctrl ^ (ctrlLowBits * uint64(h2))
+	return slotMask((x - ctrlLowBits) &^ x & ctrlHighBits)
+}
+
+func (g *swissGroup[K, V]) matchEmpty() slotMask {
+	return slotMask(g.ctrl & ctrlHighBits)
+}
+
+func (g *swissGroup[K, V]) setCtrl(slot int, ctrl uint8) {
+	shift := 8 * slot
+	g.ctrl = g.ctrl&^(0xff<<shift) | uint64(ctrl)<<shift
+}
+
+func (g *swissGroup[K, V]) clearSlot(slot int) {
+	var emptyKey K
+	var emptyValue V
+	g.keys[slot], g.values[slot] = emptyKey, emptyValue
+	g.setCtrl(slot, ctrlEmpty)
+}
+
+type swissConfig struct {
+	maxLoad float64
+}
+
+type SwissMapOption func(*swissConfig)
+
+// WithMaxLoadFactor sets share of occupied slots which triggers growth,
+// lower values make probes shorter and use more memory. Values are
+// clamped to [0.1, 7/8], NaN keeps the default.
+func WithMaxLoadFactor(maxLoad float64) SwissMapOption {
+	return func(config *swissConfig) {
+		if math.IsNaN(maxLoad) {
+			maxLoad = defaultMaxLoad
+		}
+
+		config.maxLoad = min(max(maxLoad, 0.1), defaultMaxLoad)
+	}
+}
+
+// SwissMap is an open-addressing hash table: the low 7 bits of the hash
+// are kept in control bytes, the bits above them select the first group
+// and groups are probed linearly until a group with an empty slot.
+// Deletion moves keys back instead of leaving tombstones, so lookups
+// never walk over deleted slots.
+type SwissMap[K comparable, V any] struct {
+	groups  []swissGroup[K, V]
+	mask    uint64
+	length  int
+	maxLoad float64
+	seed    maphash.Seed
+	hash    func(seed maphash.Seed, key K) uint64
+}
+
+// NewSwissMap reserves space for capacity keys
+func NewSwissMap[K comparable, V any](capacity int, options ...SwissMapOption) *SwissMap[K, V] {
+	config := swissConfig{maxLoad: defaultMaxLoad}
+	for _, option := range options {
+		option(&config)
+	}
+
+	m := &SwissMap[K, V]{
+		maxLoad: config.maxLoad,
+		seed:    maphash.MakeSeed(),
+		hash:    maphash.Comparable[K],
+	}
+	m.resize(m.groupsFor(capacity))
+	return m
+}
+
+// groupsFor returns power of two number of groups for length keys
+func (m *SwissMap[K, V]) groupsFor(length int) int {
+	groups := minSwissGroups
+	for float64(groups*swissGroupSize)*m.maxLoad < float64(length) {
+		groups <<= 1
+	}
+
+	return groups
+}
+
+func (m *SwissMap[K, V]) split(key K) (h1 uint64, h2 uint8) {
+	hash := m.hash(m.seed, key)
+	return hash >> swissHashH2Bits, uint8(hash & (1<<swissHashH2Bits - 1))
+}
+
+// find returns the group and the slot of the key, when the key is absent
+// the slot is -1 and the group is the first one with an empty slot
+func (m *SwissMap[K, V]) find(key K) (group uint64, slot int) {
+	h1, h2 := m.split(key)
+	group = h1 & m.mask
+	for {
+		g := &m.groups[group]
+		for matches := g.match(h2); matches != 0; matches = matches.withoutFirst() {
+			if slot := matches.first(); g.keys[slot] == key {
+				return group, slot
+			}
+		}
+
+		// the load factor guarantees an empty slot somewhere
+		if g.matchEmpty() != 0 {
+			return group, -1
+		}
+
+		group = (group + 1) & m.mask
+	}
+}
+
+func (m *SwissMap[K, V]) Get(key K) (V, bool) {
+	group, slot := m.find(key)
+	if slot < 0 {
+		var empty V
+		return empty, false
+	}
+
+	return m.groups[group].values[slot], true
+}
+
+func (m *SwissMap[K, V]) Contains(key K) bool {
+	_, slot := m.find(key)
+	return slot >= 0
+}
+
+func (m *SwissMap[K, V]) Put(key K, value V) {
+	group, slot := m.find(key)
+	if slot >= 0 {
+		m.groups[group].values[slot] = value
+		return
+	}
+
+	if float64(m.length+1) > m.maxLoad*float64(m.Cap()) {
+		m.resize(2 * len(m.groups))
+		group, _ = m.find(key)
+	}
+
+	m.insertInto(group, key, value)
+}
+
+func (m *SwissMap[K, V]) insertInto(group uint64, key K, value V) {
+	_, h2 := m.split(key)
+	g := &m.groups[group]
+	slot := g.matchEmpty().first()
+	g.keys[slot], g.values[slot] = key, value
+	g.setCtrl(slot, h2)
+	m.length++
+}
+
+// Delete removes the key and refills the hole: a key behind the group
+// which is reachable only through it is moved into the hole, and the
+// same is repeated for the new hole until a group which had an empty
+// slot, no probe sequence passes such groups
+func (m *SwissMap[K, V]) Delete(key K) bool {
+	hole, slot := m.find(key)
+	if slot < 0 {
+		return false
+	}
+
+	m.groups[hole].clearSlot(slot)
+	m.length--
+
+	for m.groups[hole].matchEmpty().count() == 1 { // the group was full
+		next, nextSlot, found := m.findDisplaced(hole)
+		if !found {
+			return true
+		}
+
+		g, nextGroup := &m.groups[hole], &m.groups[next]
+		g.keys[slot], g.values[slot] = nextGroup.keys[nextSlot], nextGroup.values[nextSlot]
+		_, h2 := m.split(g.keys[slot])
+		g.setCtrl(slot, h2)
+		nextGroup.clearSlot(nextSlot)
+
+		hole, slot = next, nextSlot
+	}
+
+	return true
+}
+
+// findDisplaced looks for a key after the hole whose probe
+// sequence passes the hole group
+func (m *SwissMap[K, V]) findDisplaced(hole uint64) (group uint64, slot int, found bool) {
+	for group = (hole + 1) & m.mask; group != hole; group = (group + 1) & m.mask {
+		g := &m.groups[group]
+		for slot = 0; slot < swissGroupSize; slot++ {
+			if g.ctrl>>(8*slot)&ctrlEmpty != 0 {
+				continue
+			}
+
+			h1, _ := m.split(g.keys[slot])
+			home := h1 & m.mask
+			if (hole-home)&m.mask < (group-home)&m.mask {
+				return group, slot, true
+			}
+		}
+
+		if g.matchEmpty() != 0 {
+			break
+		}
+	}
+
+	return 0, 0, false
+}
+
+func (m *SwissMap[K, V]) Len() int {
+	return m.length
+}
+
+// Cap returns number of slots
+func (m *SwissMap[K, V]) Cap() int {
+	return len(m.groups) * swissGroupSize
+}
+
+func (m *SwissMap[K, V]) LoadFactor() float64 {
+	return float64(m.length) / float64(m.Cap())
+}
+
+// Reserve grows the table, so n keys can be stored without rehashing
+func (m *SwissMap[K, V]) Reserve(n int) {
+	if groups := m.groupsFor(n); groups > len(m.groups) {
+		m.resize(groups)
+	}
+}
+
+// Shrink releases memory after many deletions, unlike builtin maps
+func (m *SwissMap[K, V]) Shrink() {
+	if groups := m.groupsFor(m.length); groups < len(m.groups) {
+		m.resize(groups)
+	}
+}
+
+func (m *SwissMap[K, V]) Clear() {
+	clear(m.groups)
+	for i := range m.groups {
+		m.groups[i].ctrl = ctrlEmptyGroup
+	}
+	m.length = 0
+}
+
+// MemoryFootprint returns bytes used by the table, memory referenced
+// by keys and values (strings, slices, pointers) is not counted
+func (m *SwissMap[K, V]) MemoryFootprint() uintptr {
+	return unsafe.Sizeof(*m) + uintptr(len(m.groups))*unsafe.Sizeof(swissGroup[K, V]{})
+}
+
+func (m *SwissMap[K, V]) resize(groupsNumber int) {
+	old := m.groups
+	m.groups = make([]swissGroup[K, V], groupsNumber)
+	m.mask = uint64(groupsNumber - 1)
+	m.length = 0
+	for i := range m.groups {
+		m.groups[i].ctrl = ctrlEmptyGroup
+	}
+
+	for i := range old {
+		g := &old[i]
+		for slot := 0; slot < swissGroupSize; slot++ {
+			if g.ctrl>>(8*slot)&ctrlEmpty == 0 {
+				group, _ := m.find(g.keys[slot])
+				m.insertInto(group, g.keys[slot], g.values[slot])
+			}
+		}
+	}
+}
+
+// All iterates keys in the table order, the map must not
+// be changed during iteration
+func (m *SwissMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for i := range m.groups {
+			g := &m.groups[i]
+			for slot := 0; slot < swissGroupSize; slot++ {
+				if g.ctrl>>(8*slot)&ctrlEmpty == 0 && !yield(g.keys[slot], g.values[slot]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// checkSwissMap verifies that every key is found from its home group
+func checkSwissMap[K comparable, V any](t *testing.T, m *SwissMap[K, V]) {
+	t.Helper()
+
+	length := 0
+	for key, value := range m.All() {
+		length++
+		actual, found := m.Get(key)
+		require.True(t, found, "key %v is not reachable", key)
+		require.Equal(t, value, actual)
+	}
+
+	require.Equal(t, m.length, length)
+	require.LessOrEqual(t, m.LoadFactor(), m.maxLoad)
+}
+
+func TestSwissGroupMatch(t *testing.T) {
+	var g swissGroup[int, int]
+	g.ctrl = ctrlEmptyGroup
+	g.setCtrl(1, 5)
+	g.setCtrl(4, 5)
+	g.setCtrl(6, 7)
+
+	var slots []int
+	for matches := g.match(5); matches != 0; matches = matches.withoutFirst() {
+		slots = append(slots, matches.first())
+	}
+	assert.Equal(t, []int{1, 4}, slots)
+	assert.Zero(t, g.match(3))
+	assert.Equal(t, 5, g.matchEmpty().count())
+
+	g.clearSlot(4)
+	assert.Equal(t, 6, g.matchEmpty().count())
+}
+
+func TestSwissMap(t *testing.T) {
+	m := NewSwissMap[string, int](0)
+	assert.Equal(t, 8, m.Cap())
+
+	m.Put("a", 1)
+	m.Put("b", 2)
+	m.Put("a", 3)
+	assert.Equal(t, 2, m.Len())
+
+	value, found := m.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, 3, value)
+	_, found = m.Get("c")
+	assert.False(t, found)
+
+	assert.True(t, m.Delete("a"))
+	assert.False(t, m.Delete("a"))
+	assert.False(t, m.Contains("a"))
+	assert.True(t, m.Contains("b"))
+
+	m.Clear()
+	assert.Zero(t, m.Len())
+	assert.False(t, m.Contains("b"))
+}
+
+func TestSwissMapRandom(t *testing.T) {
+	hashes := map[string]func(maphash.Seed, int) uint64{
+		"maphash": maphash.Comparable[int],
+		// few home groups give long probe sequences and many moves on delete
+		"collisions": func(_ maphash.Seed, key int) uint64 { return uint64(key%5)<<swissHashH2Bits | uint64(key%3) },
+	}
+
+	for name, hash := range hashes {
+		t.Run(name, func(t *testing.T) {
+			random := rand.New(rand.NewSource(1))
+			m := NewSwissMap[int, int](0)
+			m.hash = hash
+			model := make(map[int]int)
+
+			for i := 0; i < 20_000; i++ {
+				key := random.Intn(1_000)
+				switch random.Intn(3) {
+				case 0:
+					_, exists := model[key]
+					assert.Equal(t, exists, m.Delete(key))
+					delete(model, key)
+				default:
+					m.Put(key, i)
+					model[key] = i
+				}
+
+				if i%1_000 == 0 {
+					checkSwissMap(t, m)
+				}
+			}
+
+			checkSwissMap(t, m)
+			require.Equal(t, len(model), m.Len())
+			for key, value := range model {
+				actual, found := m.Get(key)
+				require.True(t, found)
+				require.Equal(t, value, actual)
+			}
+		})
+	}
+}
+
+func TestSwissMapInvalidLoadFactor(t *testing.T) {
+	for _, maxLoad := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 0, 1} {
+		m := NewSwissMap[int, int](0, WithMaxLoadFactor(maxLoad))
+		for key := range 100 {
+			m.Put(key, key)
+		}
+
+		assert.Equal(t, 100, m.Len(), "max load %v", maxLoad)
+		assert.LessOrEqual(t, m.LoadFactor(), defaultMaxLoad)
+	}
+}
+
+func TestSwissMapCapacity(t *testing.T) {
+	m := NewSwissMap[int, int](100, WithMaxLoadFactor(0.5))
+	assert.Equal(t, 256, m.Cap())
+
+	m.Reserve(1_000)
+	assert.Equal(t, 2_048, m.Cap())
+	for key := range 1_000 {
+		m.Put(key, key)
+	}
+	assert.Equal(t, 2_048, m.Cap(), "reserved map must not grow")
+	assert.InDelta(t, 1_000.0/2_048, m.LoadFactor(), 1e-9)
+
+	footprint := m.MemoryFootprint()
+	assert.Greater(t, footprint, uintptr(2_048*16))
+
+	for key := range 990 {
+		m.Delete(key)
+	}
+	m.Shrink()
+	assert.Equal(t, 32, m.Cap())
+	assert.Less(t, m.MemoryFootprint(), footprint)
+	checkSwissMap(t, m)
+}
+
+const swissBenchmarkKeys = 1 << 20
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+func BenchmarkSwissMap_Put(b *testing.B) {
+	b.Run("builtin", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			before := heapInUse()
+			m := make(map[int32]int32)
+			for key := int32(0); key < swissBenchmarkKeys; key++ {
+				m[key] = key
+			}
+			b.ReportMetric(float64(heapInUse()-before)/swissBenchmarkKeys, "bytes/key")
+			runtime.KeepAlive(m)
+		}
+	})
+	b.Run("swiss", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			before := heapInUse()
+			m := NewSwissMap[int32, int32](0)
+			for key := int32(0); key < swissBenchmarkKeys; key++ {
+				m.Put(key, key)
+			}
+			b.ReportMetric(float64(heapInUse()-before)/swissBenchmarkKeys, "bytes/key")
+			runtime.KeepAlive(m)
+		}
+	})
+}
+
+func BenchmarkSwissMap_Get(b *testing.B) {
+	builtin := make(map[int32]int32, swissBenchmarkKeys)
+	swiss := NewSwissMap[int32, int32](swissBenchmarkKeys)
+	for key := int32(0); key < swissBenchmarkKeys; key++ {
+		builtin[key] = key
+		swiss.Put(key, key)
+	}
+
+	b.Run("builtin", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = builtin[int32(i*7919)%swissBenchmarkKeys]
+		}
+	})
+	b.Run("swiss", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			swiss.Get(int32(i*7919) % swissBenchmarkKeys)
+		}
+	})
+}
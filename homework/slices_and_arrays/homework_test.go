@@ -1,7 +1,9 @@
 package main
 
 import (
+	"iter"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,27 +11,35 @@ import (
 
 // go test -v homework_test.go
 
-type IntNum interface {
-	int8 | int16 | int32 | int64 | int
+// CircularQueue is a ring buffer with fixed capacity, growable
+// queues double their capacity instead of rejecting values
+type CircularQueue[T any] struct {
+	values   []T
+	front    int
+	rear     int
+	length   int
+	growable bool
 }
 
-type CircularQueue[T IntNum] struct {
-	values []T
-	front  int
-	rear   int
-	length int
-}
-
-func NewCircularQueue[T IntNum](size int) CircularQueue[T] {
+func NewCircularQueue[T any](size int) CircularQueue[T] {
 	return CircularQueue[T]{
 		values: make([]T, size),
 		rear:   -1,
 	}
 }
 
+func NewGrowableCircularQueue[T any](size int) CircularQueue[T] {
+	queue := NewCircularQueue[T](size)
+	queue.growable = true
+	return queue
+}
+
 func (q *CircularQueue[T]) Push(value T) bool {
 	if q.Full() {
-		return false
+		if !q.growable {
+			return false
+		}
+		q.grow()
 	}
 
 	q.rear = (q.rear + 1) % len(q.values)
@@ -39,6 +49,23 @@ func (q *CircularQueue[T]) Push(value T) bool {
 	return true
 }
 
+// PushOverwrite pushes the value replacing the oldest one
+// when the queue is full and reports whether it happened
+func (q *CircularQueue[T]) PushOverwrite(value T) bool {
+	if !q.Full() || q.growable {
+		return !q.Push(value)
+	}
+	if len(q.values) == 0 {
+		return false
+	}
+
+	q.rear = q.front
+	q.values[q.rear] = value
+	q.front = (q.front + 1) % len(q.values)
+
+	return true
+}
+
 func (q *CircularQueue[T]) Pop() bool {
 	if q.Empty() {
 		return false
@@ -50,21 +77,85 @@ func (q *CircularQueue[T]) Pop() bool {
 	return true
 }
 
-func (q *CircularQueue[T]) Front() T {
+// PopBack removes the newest value, so the queue can be used as a deque
+func (q *CircularQueue[T]) PopBack() bool {
+	if q.Empty() {
+		return false
+	}
+
+	var empty T
+	q.values[q.rear] = empty
+	q.rear = (q.rear - 1 + len(q.values)) % len(q.values)
+	q.length--
+
+	return true
+}
+
+func (q *CircularQueue[T]) Front() (T, bool) {
 	if q.Empty() {
-		return -1
+		var empty T
+		return empty, false
 	}
 
-	return q.values[q.front]
+	return q.values[q.front], true
 }
 
-func (q *CircularQueue[T]) Back() T {
+func (q *CircularQueue[T]) Back() (T, bool) {
 	if q.Empty() {
-		return -1
+		var empty T
+		return empty, false
 	}
 
 	v := q.values[q.rear]
-	return v
+	return v, true
+}
+
+// At returns i-th value counting from the front
+func (q *CircularQueue[T]) At(i int) (T, bool) {
+	if i < 0 || i >= q.length {
+		var empty T
+		return empty, false
+	}
+
+	return q.values[(q.front+i)%len(q.values)], true
+}
+
+// All iterates values from the front to the back
+func (q *CircularQueue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := 0; i < q.length; i++ {
+			if !yield(q.values[(q.front+i)%len(q.values)]) {
+				return
+			}
+		}
+	}
+}
+
+// Drain removes all values and returns them from the front to the back
+func (q *CircularQueue[T]) Drain() []T {
+	values := make([]T, 0, q.length)
+	for value := range q.All() {
+		values = append(values, value)
+	}
+
+	q.Clear()
+	return values
+}
+
+// Clear removes all values and releases references to them
+func (q *CircularQueue[T]) Clear() {
+	clear(q.values)
+	q.front = 0
+	q.rear = -1
+	q.length = 0
+}
+
+func (q *CircularQueue[T]) Len() int {
+	return q.length
+}
+
+func (q *CircularQueue[T]) Cap() int {
+	return len(q.values)
 }
 
 func (q *CircularQueue[T]) Empty() bool {
@@ -75,6 +166,32 @@ func (q *CircularQueue[T]) Full() bool {
 	return q.length == len(q.values)
 }
 
+// grow doubles the capacity and moves values to the beginning keeping the order
+func (q *CircularQueue[T]) grow() {
+	values := make([]T, max(2*len(q.values), 1))
+	for i := 0; i < q.length; i++ {
+		values[i] = q.values[(q.front+i)%len(q.values)]
+	}
+
+	q.values = values
+	q.front = 0
+	q.rear = q.length - 1
+}
+
+func assertFront[T any](t *testing.T, queue *CircularQueue[T], expected T) {
+	t.Helper()
+	value, ok := queue.Front()
+	assert.True(t, ok)
+	assert.Equal(t, expected, value)
+}
+
+func assertBack[T any](t *testing.T, queue *CircularQueue[T], expected T) {
+	t.Helper()
+	value, ok := queue.Back()
+	assert.True(t, ok)
+	assert.Equal(t, expected, value)
+}
+
 func TestCircularQueue_Generic(t *testing.T) {
 	t.Run("int", func(t *testing.T) {
 		queue := NewCircularQueue[int](3)
@@ -82,60 +199,60 @@ func TestCircularQueue_Generic(t *testing.T) {
 		assert.True(t, queue.Push(2))
 		assert.True(t, queue.Push(3))
 		assert.False(t, queue.Push(4))
-		assert.Equal(t, 1, queue.Front())
-		assert.Equal(t, 3, queue.Back())
+		assertFront(t, &queue, 1)
+		assertBack(t, &queue, 3)
 		assert.True(t, queue.Pop())
 		assert.True(t, queue.Push(4))
-		assert.Equal(t, 2, queue.Front())
-		assert.Equal(t, 4, queue.Back())
+		assertFront(t, &queue, 2)
+		assertBack(t, &queue, 4)
 	})
 	t.Run("int8", func(t *testing.T) {
 		queue := NewCircularQueue[int8](2)
 		assert.True(t, queue.Push(10))
 		assert.True(t, queue.Push(20))
 		assert.False(t, queue.Push(30))
-		assert.Equal(t, int8(10), queue.Front())
-		assert.Equal(t, int8(20), queue.Back())
+		assertFront(t, &queue, int8(10))
+		assertBack(t, &queue, int8(20))
 		assert.True(t, queue.Pop())
 		assert.True(t, queue.Push(30))
-		assert.Equal(t, int8(20), queue.Front())
-		assert.Equal(t, int8(30), queue.Back())
+		assertFront(t, &queue, int8(20))
+		assertBack(t, &queue, int8(30))
 	})
 	t.Run("int16", func(t *testing.T) {
 		queue := NewCircularQueue[int16](2)
 		assert.True(t, queue.Push(1000))
 		assert.True(t, queue.Push(2000))
 		assert.False(t, queue.Push(3000))
-		assert.Equal(t, int16(1000), queue.Front())
-		assert.Equal(t, int16(2000), queue.Back())
+		assertFront(t, &queue, int16(1000))
+		assertBack(t, &queue, int16(2000))
 		assert.True(t, queue.Pop())
 		assert.True(t, queue.Push(3000))
-		assert.Equal(t, int16(2000), queue.Front())
-		assert.Equal(t, int16(3000), queue.Back())
+		assertFront(t, &queue, int16(2000))
+		assertBack(t, &queue, int16(3000))
 	})
 	t.Run("int32", func(t *testing.T) {
 		queue := NewCircularQueue[int32](2)
 		assert.True(t, queue.Push(100000))
 		assert.True(t, queue.Push(200000))
 		assert.False(t, queue.Push(300000))
-		assert.Equal(t, int32(100000), queue.Front())
-		assert.Equal(t, int32(200000), queue.Back())
+		assertFront(t, &queue, int32(100000))
+		assertBack(t, &queue, int32(200000))
 		assert.True(t, queue.Pop())
 		assert.True(t, queue.Push(300000))
-		assert.Equal(t, int32(200000), queue.Front())
-		assert.Equal(t, int32(300000), queue.Back())
+		assertFront(t, &queue, int32(200000))
+		assertBack(t, &queue, int32(300000))
 	})
 	t.Run("int64", func(t *testing.T) {
 		queue := NewCircularQueue[int64](2)
 		assert.True(t, queue.Push(10000000000))
 		assert.True(t, queue.Push(20000000000))
 		assert.False(t, queue.Push(30000000000))
-		assert.Equal(t, int64(10000000000), queue.Front())
-		assert.Equal(t, int64(20000000000), queue.Back())
+		assertFront(t, &queue, int64(10000000000))
+		assertBack(t, &queue, int64(20000000000))
 		assert.True(t, queue.Pop())
 		assert.True(t, queue.Push(30000000000))
-		assert.Equal(t, int64(20000000000), queue.Front())
-		assert.Equal(t, int64(30000000000), queue.Back())
+		assertFront(t, &queue, int64(20000000000))
+		assertBack(t, &queue, int64(30000000000))
 	})
 }
 
@@ -146,8 +263,10 @@ func TestCircularQueue(t *testing.T) {
 	assert.True(t, queue.Empty())
 	assert.False(t, queue.Full())
 
-	assert.Equal(t, -1, queue.Front())
-	assert.Equal(t, -1, queue.Back())
+	_, ok := queue.Front()
+	assert.False(t, ok)
+	_, ok = queue.Back()
+	assert.False(t, ok)
 	assert.False(t, queue.Pop())
 
 	assert.True(t, queue.Push(1))
@@ -160,8 +279,8 @@ func TestCircularQueue(t *testing.T) {
 	assert.False(t, queue.Empty())
 	assert.True(t, queue.Full())
 
-	assert.Equal(t, 1, queue.Front())
-	assert.Equal(t, 3, queue.Back())
+	assertFront(t, &queue, 1)
+	assertBack(t, &queue, 3)
 
 	assert.True(t, queue.Pop())
 	assert.False(t, queue.Empty())
@@ -170,8 +289,8 @@ func TestCircularQueue(t *testing.T) {
 
 	assert.True(t, reflect.DeepEqual([]int{4, 2, 3}, queue.values))
 
-	assert.Equal(t, 2, queue.Front())
-	assert.Equal(t, 4, queue.Back())
+	assertFront(t, &queue, 2)
+	assertBack(t, &queue, 4)
 
 	assert.True(t, queue.Pop())
 	assert.True(t, queue.Pop())
@@ -200,8 +319,8 @@ func TestCircularQueue_CyclePushPop(t *testing.T) {
 
 	assert.True(t, reflect.DeepEqual([]int{40, 20, 30}, queue.values))
 
-	assert.Equal(t, 20, queue.Front())
-	assert.Equal(t, 40, queue.Back())
+	assertFront(t, &queue, 20)
+	assertBack(t, &queue, 40)
 
 	assert.True(t, queue.Pop())
 	assert.True(t, queue.Pop())
@@ -220,15 +339,15 @@ func TestCircularQueue_SizeOne(t *testing.T) {
 	assert.True(t, reflect.DeepEqual([]int{5}, queue.values))
 
 	assert.False(t, queue.Push(6))
-	assert.Equal(t, 5, queue.Front())
-	assert.Equal(t, 5, queue.Back())
+	assertFront(t, &queue, 5)
+	assertBack(t, &queue, 5)
 	assert.True(t, queue.Pop())
 
 	assert.True(t, reflect.DeepEqual([]int{5}, queue.values))
 
 	assert.True(t, queue.Empty())
 	assert.True(t, queue.Push(7))
-	assert.Equal(t, 7, queue.Front())
+	assertFront(t, &queue, 7)
 
 	assert.True(t, reflect.DeepEqual([]int{7}, queue.values))
 
@@ -256,8 +375,8 @@ func TestCircularQueue_ManyOperations(t *testing.T) {
 	assert.True(t, reflect.DeepEqual([]int{5, 2, 3, 4}, queue.values))
 
 	assert.False(t, queue.Push(6))
-	assert.Equal(t, 2, queue.Front())
-	assert.Equal(t, 5, queue.Back())
+	assertFront(t, &queue, 2)
+	assertBack(t, &queue, 5)
 	assert.True(t, queue.Pop())
 	assert.True(t, queue.Pop())
 	assert.True(t, queue.Pop())
@@ -274,7 +393,7 @@ func TestCircularQueue_FullEmptyCycle(t *testing.T) {
 		assert.True(t, queue.Push(i))
 		assert.True(t, queue.Push(i+1))
 		assert.True(t, queue.Full())
-		assert.Equal(t, i+1, queue.Back())
+		assertBack(t, &queue, i+1)
 		assert.True(t, queue.Pop())
 		assert.True(t, queue.Pop())
 		assert.True(t, queue.Empty())
@@ -298,14 +417,138 @@ func TestCircularQueue_ValuesNotLost(t *testing.T) {
 
 	assert.True(t, reflect.DeepEqual([]int{4, 2, 3}, queue.values))
 
-	assert.Equal(t, 2, queue.Front())
-	assert.Equal(t, 4, queue.Back())
+	assertFront(t, &queue, 2)
+	assertBack(t, &queue, 4)
 	assert.True(t, queue.Pop())
 	assert.True(t, queue.Pop())
 
 	assert.True(t, reflect.DeepEqual([]int{4, 2, 3}, queue.values))
 
 	assert.False(t, queue.Empty())
-	assert.Equal(t, 4, queue.Front())
-	assert.Equal(t, 4, queue.Back())
+	assertFront(t, &queue, 4)
+	assertBack(t, &queue, 4)
+}
+
+type logEntry struct {
+	level   string
+	message string
+}
+
+func TestCircularQueue_Structs(t *testing.T) {
+	queue := NewCircularQueue[logEntry](2)
+	assert.True(t, queue.Push(logEntry{"info", "started"}))
+	assert.True(t, queue.Push(logEntry{"error", "failed"}))
+
+	assertFront(t, &queue, logEntry{"info", "started"})
+	assertBack(t, &queue, logEntry{"error", "failed"})
+
+	queue.Clear()
+	_, ok := queue.Front()
+	assert.False(t, ok)
+	assert.Equal(t, []logEntry{{}, {}}, queue.values) // references are released
+}
+
+func TestCircularQueue_PushOverwrite(t *testing.T) {
+	queue := NewCircularQueue[int](3)
+	assert.False(t, queue.PushOverwrite(1))
+	assert.False(t, queue.PushOverwrite(2))
+	assert.False(t, queue.PushOverwrite(3))
+	assert.True(t, queue.PushOverwrite(4))
+	assert.True(t, queue.PushOverwrite(5))
+
+	assert.Equal(t, 3, queue.Len())
+	assertFront(t, &queue, 3)
+	assertBack(t, &queue, 5)
+	assert.Equal(t, []int{3, 4, 5}, slices.Collect(queue.All()))
+
+	assert.True(t, queue.Pop())
+	assert.False(t, queue.PushOverwrite(6))
+	assert.Equal(t, []int{4, 5, 6}, slices.Collect(queue.All()))
+
+	empty := NewCircularQueue[int](0)
+	assert.False(t, empty.PushOverwrite(1))
+	assert.True(t, empty.Empty())
+}
+
+func TestCircularQueue_At(t *testing.T) {
+	queue := NewCircularQueue[string](3)
+	queue.Push("a")
+	queue.Push("b")
+	queue.Pop()
+	queue.Push("c")
+	queue.Push("d")
+
+	for i, expected := range []string{"b", "c", "d"} {
+		value, ok := queue.At(i)
+		assert.True(t, ok)
+		assert.Equal(t, expected, value)
+	}
+
+	_, ok := queue.At(3)
+	assert.False(t, ok)
+	_, ok = queue.At(-1)
+	assert.False(t, ok)
+}
+
+func TestCircularQueue_PopBack(t *testing.T) {
+	queue := NewCircularQueue[int](3)
+	assert.False(t, queue.PopBack())
+
+	queue.Push(1)
+	queue.Push(2)
+	queue.Pop()
+	queue.Push(3)
+	queue.Push(4) // rear wrapped to the beginning
+
+	assert.True(t, queue.PopBack())
+	assertBack(t, &queue, 3)
+	assert.True(t, queue.PopBack())
+	assertBack(t, &queue, 2)
+	assertFront(t, &queue, 2)
+
+	assert.True(t, queue.Push(5))
+	assert.Equal(t, []int{2, 5}, queue.Drain())
+}
+
+func TestCircularQueue_AllAndDrain(t *testing.T) {
+	queue := NewCircularQueue[int](4)
+	for i := 1; i <= 6; i++ {
+		queue.PushOverwrite(i)
+	}
+
+	var values []int
+	for value := range queue.All() {
+		if value == 5 {
+			break
+		}
+		values = append(values, value)
+	}
+	assert.Equal(t, []int{3, 4}, values)
+
+	assert.Equal(t, []int{3, 4, 5, 6}, queue.Drain())
+	assert.True(t, queue.Empty())
+	assert.Empty(t, queue.Drain())
+	assert.True(t, queue.Push(7))
+	assert.Equal(t, []int{7}, slices.Collect(queue.All()))
+}
+
+func TestCircularQueue_Growable(t *testing.T) {
+	queue := NewGrowableCircularQueue[int](2)
+	assert.True(t, queue.Push(1))
+	assert.True(t, queue.Push(2))
+	assert.True(t, queue.Pop())
+	assert.True(t, queue.Push(3)) // wraps around
+	assert.True(t, queue.Push(4)) // grows
+	assert.False(t, queue.PushOverwrite(5))
+
+	assert.Equal(t, 4, queue.Cap())
+	assert.Equal(t, []int{2, 3, 4, 5}, slices.Collect(queue.All()))
+	assertFront(t, &queue, 2)
+	assertBack(t, &queue, 5)
+
+	zero := NewGrowableCircularQueue[int](0)
+	for i := 0; i < 10; i++ {
+		assert.True(t, zero.Push(i))
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, zero.Drain())
 }
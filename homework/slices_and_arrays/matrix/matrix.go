@@ -0,0 +1,374 @@
+// Package matrix implements a dense matrix stored in one slice.
+// Rows, columns, submatrices and transposition are views which share
+// the storage, element (i, j) is data[offset + i*rowStride + j*colStride].
+// Hot loops live in *Kernel functions which work on contiguous rows
+// without bounds checks, it is verified by tests with
+// -gcflags=-d=ssa/check_bce.
+package matrix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrShape = errors.New("matrix shapes do not match")
+
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+type Matrix[T Number] struct {
+	data      []T
+	offset    int
+	rows      int
+	cols      int
+	rowStride int
+	colStride int
+}
+
+// New returns zero matrix with rows*cols elements stored row by row
+func New[T Number](rows, cols int) Matrix[T] {
+	if rows < 0 || cols < 0 {
+		panic(fmt.Sprintf("matrix: negative shape %dx%d", rows, cols))
+	}
+
+	return Matrix[T]{
+		data:      make([]T, rows*cols),
+		rows:      rows,
+		cols:      cols,
+		rowStride: cols,
+		colStride: 1,
+	}
+}
+
+// FromRows copies jagged slices into a dense matrix
+func FromRows[T Number](values [][]T) (Matrix[T], error) {
+	cols := 0
+	if len(values) != 0 {
+		cols = len(values[0])
+	}
+
+	m := New[T](len(values), cols)
+	for i, row := range values {
+		if len(row) != cols {
+			return Matrix[T]{}, fmt.Errorf("%w: row %d has %d values instead of %d", ErrShape, i, len(row), cols)
+		}
+		copy(m.data[i*cols:], row)
+	}
+
+	return m, nil
+}
+
+func (m Matrix[T]) Rows() int {
+	return m.rows
+}
+
+func (m Matrix[T]) Cols() int {
+	return m.cols
+}
+
+func (m Matrix[T]) At(i, j int) T {
+	return m.data[m.index(i, j)]
+}
+
+func (m Matrix[T]) Set(i, j int, value T) {
+	m.data[m.index(i, j)] = value
+}
+
+// index checks both coordinates, otherwise an out of range
+// column would silently address the next row
+func (m Matrix[T]) index(i, j int) int {
+	if uint(i) >= uint(m.rows) || uint(j) >= uint(m.cols) {
+		panic(fmt.Sprintf("matrix: index (%d, %d) out of range %dx%d", i, j, m.rows, m.cols))
+	}
+
+	return m.offset + i*m.rowStride + j*m.colStride
+}
+
+// Row returns 1xCols view of the i-th row
+func (m Matrix[T]) Row(i int) Matrix[T] {
+	return m.Slice(i, i+1, 0, m.cols)
+}
+
+// Col returns Rowsx1 view of the j-th column
+func (m Matrix[T]) Col(j int) Matrix[T] {
+	return m.Slice(0, m.rows, j, j+1)
+}
+
+// Slice returns view of rows [r0, r1) and columns [c0, c1)
+func (m Matrix[T]) Slice(r0, r1, c0, c1 int) Matrix[T] {
+	if r0 < 0 || r0 > r1 || r1 > m.rows || c0 < 0 || c0 > c1 || c1 > m.cols {
+		panic(fmt.Sprintf("matrix: slice [%d:%d, %d:%d] out of range %dx%d", r0, r1, c0, c1, m.rows, m.cols))
+	}
+
+	view := m
+	view.offset = m.offset + r0*m.rowStride + c0*m.colStride
+	view.rows = r1 - r0
+	view.cols = c1 - c0
+	return view
+}
+
+// Step returns view of every rowStep-th row and every colStep-th column
+func (m Matrix[T]) Step(rowStep, colStep int) Matrix[T] {
+	if rowStep <= 0 || colStep <= 0 {
+		panic(fmt.Sprintf("matrix: step (%d, %d) must be positive", rowStep, colStep))
+	}
+
+	view := m
+	view.rows = (m.rows + rowStep - 1) / rowStep
+	view.cols = (m.cols + colStep - 1) / colStep
+	view.rowStride *= rowStep
+	view.colStride *= colStep
+	return view
+}
+
+// T returns transposed view without copying
+func (m Matrix[T]) T() Matrix[T] {
+	view := m
+	view.rows, view.cols = m.cols, m.rows
+	view.rowStride, view.colStride = m.colStride, m.rowStride
+	return view
+}
+
+// Contiguous reports whether elements of each row are adjacent,
+// kernels are used only for such rows
+func (m Matrix[T]) Contiguous() bool {
+	return m.colStride == 1 || m.cols <= 1
+}
+
+// row returns elements of the i-th row of a contiguous matrix
+func (m Matrix[T]) row(i int) []T {
+	start := m.offset + i*m.rowStride
+	end := start + m.cols
+	return m.data[start:end:end]
+}
+
+// Clone copies the matrix into new dense storage
+func (m Matrix[T]) Clone() Matrix[T] {
+	clone := New[T](m.rows, m.cols)
+	for i := 0; i < m.rows; i++ {
+		destination := clone.row(i)
+		if m.Contiguous() {
+			copy(destination, m.row(i))
+			continue
+		}
+
+		for j := range destination {
+			destination[j] = m.At(i, j)
+		}
+	}
+
+	return clone
+}
+
+// ToRows copies the matrix into jagged slices
+func (m Matrix[T]) ToRows() [][]T {
+	dense := m.dense()
+	rows := make([][]T, m.rows)
+	for i := range rows {
+		rows[i] = append([]T(nil), dense.row(i)...)
+	}
+
+	return rows
+}
+
+// dense returns the matrix itself when rows are contiguous or its copy
+func (m Matrix[T]) dense() Matrix[T] {
+	if m.Contiguous() {
+		return m
+	}
+
+	return m.Clone()
+}
+
+func (m Matrix[T]) sameShape(other Matrix[T]) error {
+	if m.rows != other.rows || m.cols != other.cols {
+		return fmt.Errorf("%w: %dx%d and %dx%d", ErrShape, m.rows, m.cols, other.rows, other.cols)
+	}
+
+	return nil
+}
+
+// Add returns new matrix m + other
+func (m Matrix[T]) Add(other Matrix[T]) (Matrix[T], error) {
+	if err := m.sameShape(other); err != nil {
+		return Matrix[T]{}, err
+	}
+
+	a, b := m.dense(), other.dense()
+	result := New[T](m.rows, m.cols)
+	for i := 0; i < m.rows; i++ {
+		addKernel(result.row(i), a.row(i), b.row(i))
+	}
+
+	return result, nil
+}
+
+// Scale returns new matrix alpha * m
+func (m Matrix[T]) Scale(alpha T) Matrix[T] {
+	a := m.dense()
+	result := New[T](m.rows, m.cols)
+	for i := 0; i < m.rows; i++ {
+		axpyKernel(result.row(i), alpha, a.row(i))
+	}
+
+	return result
+}
+
+// Mul returns new matrix m * other. Rows of the result are accumulated
+// from rows of other (i-k-j order), so the inner loop reads memory
+// sequentially; transposed operands are copied once for it.
+func (m Matrix[T]) Mul(other Matrix[T]) (Matrix[T], error) {
+	if m.cols != other.rows {
+		return Matrix[T]{}, fmt.Errorf("%w: %dx%d and %dx%d", ErrShape, m.rows, m.cols, other.rows, other.cols)
+	}
+
+	a, b := m.dense(), other.dense()
+	result := New[T](m.rows, other.cols)
+	for i := 0; i < a.rows; i++ {
+		destination := result.row(i)
+		for k, value := range a.row(i) {
+			if value != 0 {
+				axpyKernel(destination, value, b.row(k))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Dot returns sum of products of corresponding elements
+func (m Matrix[T]) Dot(other Matrix[T]) (T, error) {
+	if err := m.sameShape(other); err != nil {
+		return 0, err
+	}
+
+	var total T
+	a, b := m.dense(), other.dense()
+	for i := 0; i < m.rows; i++ {
+		total += dotKernel(a.row(i), b.row(i))
+	}
+
+	return total, nil
+}
+
+func (m Matrix[T]) Sum() T {
+	var total T
+	for i := 0; i < m.rows; i++ {
+		if m.Contiguous() {
+			total += sumKernel(m.row(i))
+			continue
+		}
+
+		total += sumStrided(m.data, m.offset+i*m.rowStride, m.colStride, m.cols)
+	}
+
+	return total
+}
+
+// Min returns the smallest element, false for empty matrices
+func (m Matrix[T]) Min() (T, bool) {
+	return m.reduce(minKernel[T])
+}
+
+// Max returns the biggest element, false for empty matrices
+func (m Matrix[T]) Max() (T, bool) {
+	return m.reduce(maxKernel[T])
+}
+
+func (m Matrix[T]) reduce(kernel func(T, []T) T) (T, bool) {
+	if m.rows == 0 || m.cols == 0 {
+		return 0, false
+	}
+
+	a := m.dense()
+	result := a.At(0, 0)
+	for i := 0; i < a.rows; i++ {
+		result = kernel(result, a.row(i))
+	}
+
+	return result, true
+}
+
+func (m Matrix[T]) String() string {
+	var builder strings.Builder
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			if j != 0 {
+				builder.WriteByte(' ')
+			}
+			fmt.Fprint(&builder, m.At(i, j))
+		}
+		builder.WriteByte('\n')
+	}
+
+	return builder.String()
+}
+
+// Kernels reslice arguments to the length of the first one before
+// loops, so the compiler proves all indexes and removes bounds checks.
+
+func addKernel[T Number](destination, a, b []T) {
+	a = a[:len(destination)]
+	b = b[:len(destination)]
+	for i := range destination {
+		destination[i] = a[i] + b[i]
+	}
+}
+
+// axpyKernel computes destination += alpha * x
+func axpyKernel[T Number](destination []T, alpha T, x []T) {
+	x = x[:len(destination)]
+	for i := range destination {
+		destination[i] += alpha * x[i]
+	}
+}
+
+func dotKernel[T Number](a, b []T) T {
+	var total T
+	b = b[:len(a)]
+	for i := range a {
+		total += a[i] * b[i]
+	}
+
+	return total
+}
+
+func sumKernel[T Number](values []T) T {
+	var total T
+	for _, value := range values {
+		total += value
+	}
+
+	return total
+}
+
+func minKernel[T Number](result T, values []T) T {
+	for _, value := range values {
+		result = min(result, value)
+	}
+
+	return result
+}
+
+func maxKernel[T Number](result T, values []T) T {
+	for _, value := range values {
+		result = max(result, value)
+	}
+
+	return result
+}
+
+// sumStrided walks a row of a transposed or stepped view, every
+// access is checked because the compiler can not prove the stride
+func sumStrided[T Number](data []T, start, stride, n int) T {
+	var total T
+	for i := 0; i < n; i++ {
+		total += data[start+i*stride]
+	}
+
+	return total
+}
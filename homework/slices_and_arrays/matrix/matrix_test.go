@@ -0,0 +1,291 @@
+package matrix
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -bench=. ./...
+
+func mustFromRows[T Number](t testing.TB, values [][]T) Matrix[T] {
+	m, err := FromRows(values)
+	require.NoError(t, err)
+	return m
+}
+
+func TestMatrix_Views(t *testing.T) {
+	m := mustFromRows(t, [][]int{
+		{1, 2, 3, 4},
+		{5, 6, 7, 8},
+		{9, 10, 11, 12},
+	})
+
+	assert.Equal(t, 3, m.Rows())
+	assert.Equal(t, 4, m.Cols())
+	assert.Equal(t, 7, m.At(1, 2))
+
+	assert.Equal(t, [][]int{{5, 6, 7, 8}}, m.Row(1).ToRows())
+	assert.Equal(t, [][]int{{2}, {6}, {10}}, m.Col(1).ToRows())
+	assert.Equal(t, [][]int{{6, 7}, {10, 11}}, m.Slice(1, 3, 1, 3).ToRows())
+	assert.Equal(t, [][]int{{1, 3}, {9, 11}}, m.Step(2, 2).ToRows())
+	assert.Equal(t, [][]int{{1, 5, 9}, {2, 6, 10}, {3, 7, 11}, {4, 8, 12}}, m.T().ToRows())
+	assert.Equal(t, [][]int{{7, 11}, {8, 12}}, m.T().Slice(2, 4, 1, 3).ToRows())
+	assert.Equal(t, m.ToRows(), m.T().T().ToRows())
+
+	// views share the storage
+	m.T().Set(3, 0, 100)
+	assert.Equal(t, 100, m.At(0, 3))
+	m.Slice(1, 3, 1, 3).Set(0, 0, 200)
+	assert.Equal(t, 200, m.At(1, 1))
+
+	// clones do not
+	clone := m.T().Clone()
+	assert.True(t, clone.Contiguous())
+	clone.Set(0, 0, -1)
+	assert.Equal(t, 1, m.At(0, 0))
+}
+
+func TestMatrix_Bounds(t *testing.T) {
+	m := New[float64](2, 3)
+	assert.Panics(t, func() { m.At(0, 3) }, "column must not wrap to the next row")
+	assert.Panics(t, func() { m.At(2, 0) })
+	assert.Panics(t, func() { m.Set(-1, 0, 1) })
+	assert.Panics(t, func() { m.Slice(0, 3, 0, 1) })
+	assert.Panics(t, func() { m.Slice(1, 0, 0, 1) })
+	assert.Panics(t, func() { m.Step(0, 1) })
+	assert.Panics(t, func() { New[int](-1, 1) })
+
+	_, err := FromRows([][]int{{1, 2}, {3}})
+	assert.ErrorIs(t, err, ErrShape)
+}
+
+func TestMatrix_Arithmetic(t *testing.T) {
+	a := mustFromRows(t, [][]float64{
+		{1, 2},
+		{3, 4},
+		{5, 6},
+	})
+	b := mustFromRows(t, [][]float64{
+		{1, 0, 2},
+		{0, 1, 3},
+	})
+
+	product, err := a.Mul(b)
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 2, 8}, {3, 4, 18}, {5, 6, 28}}, product.ToRows())
+
+	// transposed operands give the same result
+	product, err = b.T().Mul(a.T())
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 3, 5}, {2, 4, 6}, {8, 18, 28}}, product.ToRows())
+
+	_, err = a.Mul(a)
+	assert.ErrorIs(t, err, ErrShape)
+
+	sum, err := a.Add(b.T())
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{2, 2}, {3, 5}, {7, 9}}, sum.ToRows())
+
+	_, err = a.Add(b)
+	assert.ErrorIs(t, err, ErrShape)
+
+	assert.Equal(t, [][]float64{{2, 4}, {6, 8}, {10, 12}}, a.Scale(2).ToRows())
+
+	dot, err := a.Col(0).Dot(a.Col(1))
+	require.NoError(t, err)
+	assert.Equal(t, 1*2+3*4+5*6.0, dot)
+	_, err = a.Dot(b)
+	assert.ErrorIs(t, err, ErrShape)
+}
+
+func TestMatrix_Reductions(t *testing.T) {
+	m := mustFromRows(t, [][]int{
+		{3, -1, 4},
+		{1, 5, -9},
+	})
+
+	for name, view := range map[string]Matrix[int]{"dense": m, "transposed": m.T()} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, 3, view.Sum())
+			minimum, ok := view.Min()
+			assert.True(t, ok)
+			assert.Equal(t, -9, minimum)
+			maximum, ok := view.Max()
+			assert.True(t, ok)
+			assert.Equal(t, 5, maximum)
+		})
+	}
+
+	assert.Equal(t, -5, m.Col(2).Sum())
+	_, ok := m.Slice(0, 0, 0, 3).Max()
+	assert.False(t, ok)
+	assert.Equal(t, "3 -1 4\n1 5 -9\n", m.String())
+}
+
+var bceLinePattern = regexp.MustCompile(`matrix\.go:(\d+):\d+: Found (\w+)`)
+
+// TestKernelsWithoutBoundsChecks compiles the package with bounds check
+// diagnostics and requires loops of kernels to be free of them
+func TestKernelsWithoutBoundsChecks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles the package")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool is not found")
+	}
+
+	// generic kernels are compiled only when instantiated, the test
+	// binary instantiates them, so it is compiled instead of the package
+	command := exec.Command(goTool, "test", "-c", "-o", filepath.Join(t.TempDir(), "matrix.test"), "-gcflags=-d=ssa/check_bce", ".")
+	output, err := command.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	checks := make(map[int]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if match := bceLinePattern.FindStringSubmatch(scanner.Text()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			checks[line] = match[2]
+		}
+	}
+
+	loops := loopLines(t, "matrix.go")
+	var kernels int
+	for function, lines := range loops {
+		if !strings.HasSuffix(function, "Kernel") {
+			continue
+		}
+
+		kernels++
+		for _, line := range lines {
+			assert.Empty(t, checks[line], "%s has bounds check in loop at line %d", function, line)
+		}
+	}
+	assert.Equal(t, 6, kernels)
+
+	// make sure the diagnostics work at all
+	var stridedChecks int
+	for _, line := range loops["sumStrided"] {
+		if checks[line] != "" {
+			stridedChecks++
+		}
+	}
+	assert.NotZero(t, stridedChecks, "strided loop must have bounds checks")
+}
+
+// loopLines returns lines of loop bodies by functions
+func loopLines(t *testing.T, path string) map[string][]int {
+	files := token.NewFileSet()
+	file, err := parser.ParseFile(files, path, nil, 0)
+	require.NoError(t, err)
+
+	loops := make(map[string][]int)
+	for _, declaration := range file.Decls {
+		function, ok := declaration.(*ast.FuncDecl)
+		if !ok || function.Recv != nil {
+			continue
+		}
+
+		ast.Inspect(function.Body, func(node ast.Node) bool {
+			var body *ast.BlockStmt
+			switch loop := node.(type) {
+			case *ast.ForStmt:
+				body = loop.Body
+			case *ast.RangeStmt:
+				body = loop.Body
+			default:
+				return true
+			}
+
+			from, to := files.Position(body.Lbrace).Line, files.Position(body.Rbrace).Line
+			for line := from; line <= to; line++ {
+				loops[function.Name.Name] = append(loops[function.Name.Name], line)
+			}
+			return true
+		})
+	}
+
+	return loops
+}
+
+const benchmarkSize = 128
+
+func benchmarkRows() [][]float64 {
+	rows := make([][]float64, benchmarkSize)
+	for i := range rows {
+		rows[i] = make([]float64, benchmarkSize)
+		for j := range rows[i] {
+			rows[i][j] = float64(i*benchmarkSize + j)
+		}
+	}
+
+	return rows
+}
+
+var Result float64
+
+func BenchmarkMul(b *testing.B) {
+	rows := benchmarkRows()
+	b.Run("jagged", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			result := make([][]float64, benchmarkSize)
+			for i := range result {
+				result[i] = make([]float64, benchmarkSize)
+				for j := range result[i] {
+					for k := range rows[i] {
+						result[i][j] += rows[i][k] * rows[k][j]
+					}
+				}
+			}
+			Result = result[0][0]
+		}
+	})
+
+	m := mustFromRows(b, rows)
+	b.Run("matrix", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			result, _ := m.Mul(m)
+			Result = result.At(0, 0)
+		}
+	})
+}
+
+func BenchmarkSum(b *testing.B) {
+	rows := benchmarkRows()
+	b.Run("jagged", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			var total float64
+			for i := range rows {
+				for j := range rows[i] {
+					total += rows[i][j]
+				}
+			}
+			Result = total
+		}
+	})
+
+	m := mustFromRows(b, rows)
+	b.Run("matrix", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			Result = m.Sum()
+		}
+	})
+	b.Run("transposed", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			Result = m.T().Sum()
+		}
+	})
+}
@@ -0,0 +1,294 @@
+// Package retention finds small slices and strings which keep big
+// arrays alive. It is meant for tests: register big buffers with Track,
+// build the value which outlives them and Check it.
+package retention
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"unsafe"
+)
+
+// Retention describes an array kept alive by the checked value
+type Retention struct {
+	Buffer     string   // name of the tracked buffer or path of the slice
+	Size       int      // bytes kept alive
+	Referenced int      // bytes visible through slices and strings
+	Paths      []string // where the references are found
+}
+
+// Unreachable returns bytes which are retained but can not be read
+func (r Retention) Unreachable() int {
+	return r.Size - r.Referenced
+}
+
+func (r Retention) String() string {
+	return fmt.Sprintf("%s: %d of %d bytes referenced by %v", r.Buffer, r.Referenced, r.Size, r.Paths)
+}
+
+// untracked arrays are reported when slices and strings
+// use less than 1/wasteRatio of their bytes
+const wasteRatio = 4
+
+type buffer struct {
+	name       string
+	start, end uintptr
+}
+
+type reference struct {
+	path       string
+	start, end uintptr // referenced bytes
+	capacity   uintptr // bytes of capacity for slices
+}
+
+type Checker struct {
+	buffers []buffer
+}
+
+// Track registers a slice or a string which must not be retained
+func (c *Checker) Track(name string, value any) {
+	v := reflect.ValueOf(value)
+	start, end, _, ok := extent(v)
+	if !ok {
+		panic(fmt.Sprintf("retention: slice or string expected instead of %s", v.Type()))
+	}
+
+	c.buffers = append(c.buffers, buffer{name: name, start: start, end: end})
+}
+
+// Check walks everything reachable from the value and reports tracked
+// buffers which are referenced and untracked arrays which are used
+// a little. An untracked array is seen from its first referenced byte
+// to the end of the widest capacity, so a slice at the end of a big
+// array is found only with other references to the array, Track
+// buffers to find it alone. Tracked buffers should be kept alive by the
+// caller (runtime.KeepAlive), otherwise their addresses can be reused.
+func (c *Checker) Check(value any) []Retention {
+	w := walker{visited: make(map[visit]struct{})}
+	w.walk(reflect.ValueOf(value), "value")
+
+	var retentions []Retention
+	for _, buffer := range c.buffers {
+		retention := Retention{Buffer: buffer.name, Size: int(buffer.end - buffer.start)}
+		var ranges [][2]uintptr
+		for _, ref := range w.references {
+			if ref.start < buffer.start || ref.start >= buffer.end {
+				continue
+			}
+
+			retention.Paths = append(retention.Paths, ref.path)
+			ranges = append(ranges, [2]uintptr{ref.start, min(ref.end, buffer.end)})
+		}
+
+		if len(retention.Paths) != 0 {
+			retention.Referenced = unionLength(ranges)
+			retentions = append(retentions, retention)
+		}
+	}
+
+	var untracked []reference
+	for _, ref := range w.references {
+		if !c.tracked(ref.start) {
+			untracked = append(untracked, ref)
+		}
+	}
+
+	return append(retentions, arrayRetentions(untracked)...)
+}
+
+// arrayRetentions groups references by arrays: slices of one array
+// have capacity up to its end, so their capacities overlap. An array
+// is reported once for all its references, like a tracked buffer.
+func arrayRetentions(references []reference) []Retention {
+	slices.SortStableFunc(references, func(a, b reference) int {
+		return cmp.Compare(a.start, b.start)
+	})
+
+	var retentions []Retention
+	for len(references) != 0 {
+		end := references[0].start + references[0].capacity
+		n := 1
+		for n < len(references) && references[n].start < end {
+			end = max(end, references[n].start+references[n].capacity)
+			n++
+		}
+
+		array := references[:n]
+		references = references[n:]
+
+		ranges := make([][2]uintptr, 0, len(array))
+		paths := make([]string, 0, len(array))
+		for _, ref := range array {
+			ranges = append(ranges, [2]uintptr{ref.start, ref.end})
+			paths = append(paths, ref.path)
+		}
+
+		size, referenced := end-array[0].start, unionLength(ranges)
+		if size <= wasteRatio*uintptr(referenced) {
+			continue
+		}
+
+		retentions = append(retentions, Retention{
+			Buffer:     array[0].path,
+			Size:       int(size),
+			Referenced: referenced,
+			Paths:      paths,
+		})
+	}
+
+	return retentions
+}
+
+// Unreachable sums unreachable bytes of all retentions
+func (c *Checker) Unreachable(value any) int {
+	total := 0
+	for _, retention := range c.Check(value) {
+		total += retention.Unreachable()
+	}
+
+	return total
+}
+
+func (c *Checker) tracked(address uintptr) bool {
+	for _, buffer := range c.buffers {
+		if address >= buffer.start && address < buffer.end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extent returns referenced bytes and capacity of a slice or a string
+func extent(v reflect.Value) (start, end, capacity uintptr, ok bool) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 {
+			return 0, 0, 0, false
+		}
+		start = uintptr(unsafe.Pointer(unsafe.StringData(v.String())))
+		size := uintptr(v.Len())
+		return start, start + size, size, true
+	case reflect.Slice:
+		if v.Cap() == 0 {
+			return 0, 0, 0, false
+		}
+		start = v.Pointer()
+		size := v.Type().Elem().Size()
+		return start, start + uintptr(v.Len())*size, uintptr(v.Cap()) * size, true
+	default:
+		return 0, 0, 0, false
+	}
+}
+
+func unionLength(ranges [][2]uintptr) int {
+	slices.SortFunc(ranges, func(a, b [2]uintptr) int {
+		return cmp.Compare(a[0], b[0])
+	})
+
+	var total, covered uintptr
+	for _, r := range ranges {
+		start := max(r[0], covered)
+		if r[1] > start {
+			total += r[1] - start
+			covered = r[1]
+		}
+	}
+
+	return int(total)
+}
+
+type visit struct {
+	address uintptr
+	typ     reflect.Type
+	length  int
+}
+
+type walker struct {
+	visited    map[visit]struct{}
+	references []reference
+}
+
+// seen marks the value and reports whether it was visited before,
+// it breaks cycles and skips arrays shared by several slices
+func (w *walker) seen(address uintptr, typ reflect.Type, length int) bool {
+	key := visit{address: address, typ: typ, length: length}
+	if _, found := w.visited[key]; found {
+		return true
+	}
+
+	w.visited[key] = struct{}{}
+	return false
+}
+
+// walk reads unexported fields too, reflect allows it while
+// values are not converted back to interfaces
+func (w *walker) walk(v reflect.Value, path string) {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice:
+		start, end, capacity, ok := extent(v)
+		if !ok {
+			return
+		}
+		w.references = append(w.references, reference{path: path, start: start, end: end, capacity: capacity})
+
+		if v.Kind() == reflect.Slice && hasReferences(v.Type().Elem()) && !w.seen(start, v.Type(), v.Len()) {
+			for i := 0; i < v.Len(); i++ {
+				w.walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case reflect.Array:
+		if !hasReferences(v.Type().Elem()) {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			w.walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			w.walk(v.Field(i), path+"."+v.Type().Field(i).Name)
+		}
+	case reflect.Pointer:
+		if v.IsNil() || w.seen(v.Pointer(), v.Type(), 0) {
+			return
+		}
+		w.walk(v.Elem(), "(*"+path+")")
+	case reflect.Interface:
+		if !v.IsNil() {
+			w.walk(v.Elem(), path)
+		}
+	case reflect.Map:
+		if v.IsNil() || w.seen(v.Pointer(), v.Type(), 0) {
+			return
+		}
+		for iterator := v.MapRange(); iterator.Next(); {
+			key := fmt.Sprint(iterator.Key())
+			w.walk(iterator.Key(), fmt.Sprintf("%s{key %s}", path, key))
+			w.walk(iterator.Value(), fmt.Sprintf("%s[%s]", path, key))
+		}
+	default:
+		// numbers have no references, channels and functions are not inspected
+	}
+}
+
+// hasReferences reports whether values of the type can contain
+// slices or strings, so elements of []byte are not walked one by one
+func hasReferences(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Pointer, reflect.Interface, reflect.Map:
+		return true
+	case reflect.Array:
+		return hasReferences(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasReferences(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
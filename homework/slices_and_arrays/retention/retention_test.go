@@ -0,0 +1,149 @@
+package retention
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"golang_course/homework/slices_and_arrays/sliceutil"
+)
+
+// go test -v ./...
+
+type header struct {
+	name  string
+	value []byte
+}
+
+type request struct {
+	method  string
+	headers []header
+	meta    map[string]any
+	parent  *request
+}
+
+const bodySize = 1 << 20
+
+func readBody() []byte {
+	body := make([]byte, bodySize)
+	copy(body, "GET\nHost: example.com\nAccept: */*\n\n")
+	return body
+}
+
+// parse mimics a parser which keeps parts of the body,
+// detached parts are copied when they are small
+func parse(body []byte, detach bool) *request {
+	subslice := func(i, j int) []byte { return body[i:j] }
+	substring := func(s string, i, j int) string { return s[i:j] }
+	if detach {
+		subslice = func(i, j int) []byte { return sliceutil.Subslice(body, i, j) }
+		substring = sliceutil.Substring
+	}
+
+	text := unsafe.String(unsafe.SliceData(body), len(body))
+	lineEnd := bytes.IndexByte(body, '\n')
+	request := &request{method: substring(text, 0, lineEnd), meta: make(map[string]any)}
+
+	for i := 0; i < 2; i++ {
+		lineStart := lineEnd + 1
+		lineEnd = lineStart + bytes.IndexByte(body[lineStart:], '\n')
+		separator := lineStart + bytes.Index(body[lineStart:lineEnd], []byte(": "))
+		request.headers = append(request.headers, header{
+			name:  string(body[lineStart:separator]), // conversion copies
+			value: subslice(separator+2, lineEnd),
+		})
+	}
+
+	request.meta["host"] = request.headers[0].value
+	request.parent = request // cycles must not hang the checker
+	return request
+}
+
+func TestCheckerFindsRetention(t *testing.T) {
+	body := readBody()
+	request := parse(body, false)
+
+	var checker Checker
+	checker.Track("body", body)
+
+	retentions := checker.Check(request)
+	require.Len(t, retentions, 1)
+	assert.Equal(t, "body", retentions[0].Buffer)
+	assert.Equal(t, bodySize, retentions[0].Size)
+	assert.Equal(t, len("GET")+len("example.com")+len("*/*"), retentions[0].Referenced)
+	assert.ElementsMatch(t, []string{
+		"(*value).method",
+		"(*value).headers[0].value",
+		"(*value).headers[1].value",
+		"(*value).meta[host]",
+	}, retentions[0].Paths)
+	assert.Equal(t, bodySize-retentions[0].Referenced, checker.Unreachable(request))
+
+	runtime.KeepAlive(body)
+}
+
+func TestCheckerAfterDetach(t *testing.T) {
+	body := readBody()
+	request := parse(body, true)
+
+	var checker Checker
+	checker.Track("body", body)
+	assert.Empty(t, checker.Check(request))
+	assert.Zero(t, checker.Unreachable(request))
+
+	runtime.KeepAlive(body)
+}
+
+func TestCheckerReportsUntrackedSlices(t *testing.T) {
+	buffer := make([]int64, 2, 100)
+	value := map[string]any{
+		"small":  buffer[:1],
+		"clip":   buffer[:2:2],
+		"nested": &[]any{[2][]int64{nil, buffer[1:2]}},
+	}
+
+	// the array is reported once for all slices
+	var checker Checker
+	retentions := checker.Check(value)
+	require.Len(t, retentions, 1)
+	assert.Equal(t, 800, retentions[0].Size)
+	assert.Equal(t, 16, retentions[0].Referenced)
+	assert.ElementsMatch(t, []string{"value[small]", "value[clip]", "(*value[nested])[0][1]"}, retentions[0].Paths)
+	assert.Equal(t, 800-16, checker.Unreachable(value))
+
+	// used capacity is not a waste
+	assert.Empty(t, checker.Check(make([]int64, 30, 100)))
+}
+
+func TestCheckerReportsUntrackedTails(t *testing.T) {
+	buffer := make([]byte, 1000)
+	value := []any{buffer[:10:10], buffer[990:], buffer[500:510]}
+
+	// capacity of the tail is small, it is measured
+	// from the first referenced byte of the array
+	var checker Checker
+	retentions := checker.Check(value)
+	require.Len(t, retentions, 1)
+	assert.Equal(t, "value[2]", retentions[0].Buffer)
+	assert.Equal(t, 500, retentions[0].Size)
+	assert.Equal(t, 20, retentions[0].Referenced)
+	assert.Equal(t, []string{"value[2]", "value[1]"}, retentions[0].Paths)
+
+	// a tracked buffer is found from any slice
+	checker.Track("buffer", buffer)
+	retentions = checker.Check(buffer[990:])
+	require.Len(t, retentions, 1)
+	assert.Equal(t, "buffer", retentions[0].Buffer)
+	assert.Equal(t, 1000, retentions[0].Size)
+
+	runtime.KeepAlive(buffer)
+}
+
+func TestTrackPanics(t *testing.T) {
+	var checker Checker
+	assert.Panics(t, func() { checker.Track("number", 1) })
+}
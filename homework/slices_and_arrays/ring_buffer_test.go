@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -race -bench=. homework_test.go ring_buffer_test.go
+
+// cacheLineSize is the most common size of cache lines on amd64 and arm64
+const cacheLineSize = 64
+
+// indices of producers and consumers are placed into different
+// cache lines, otherwise every write invalidates the line of the other side
+type cacheLinePad [cacheLineSize]byte
+
+// Ring is a non-blocking queue, see BlockingRing for waiting
+type Ring[T any] interface {
+	Push(value T) bool
+	Pop() (T, bool)
+	Cap() int
+}
+
+// SPSCRing is a lock-free ring for exactly one producer and one consumer
+type SPSCRing[T any] struct {
+	_          cacheLinePad
+	head       atomic.Uint64 // next position to read, written by consumer
+	cachedTail uint64        // consumer copy of tail to avoid reading it every time
+	_          cacheLinePad
+	tail       atomic.Uint64 // next position to write, written by producer
+	cachedHead uint64        // producer copy of head
+	_          cacheLinePad
+	mask       uint64
+	values     []T
+}
+
+// NewSPSCRing rounds capacity up to a power of two, so
+// positions are mapped to indexes by mask instead of division
+func NewSPSCRing[T any](capacity int) *SPSCRing[T] {
+	size := powerOfTwo(capacity)
+	return &SPSCRing[T]{
+		mask:   uint64(size - 1),
+		values: make([]T, size),
+	}
+}
+
+func (r *SPSCRing[T]) Cap() int {
+	return len(r.values)
+}
+
+// Push must be called only by the producer
+func (r *SPSCRing[T]) Push(value T) bool {
+	tail := r.tail.Load()
+	if tail-r.cachedHead == uint64(len(r.values)) {
+		r.cachedHead = r.head.Load()
+		if tail-r.cachedHead == uint64(len(r.values)) {
+			return false
+		}
+	}
+
+	r.values[tail&r.mask] = value
+	r.tail.Store(tail + 1)
+	return true
+}
+
+// PushBatch pushes as many values as possible and returns their number,
+// consumer sees all of them at once
+func (r *SPSCRing[T]) PushBatch(values []T) int {
+	tail := r.tail.Load()
+	free := uint64(len(r.values)) - (tail - r.cachedHead)
+	if free < uint64(len(values)) {
+		r.cachedHead = r.head.Load()
+		free = uint64(len(r.values)) - (tail - r.cachedHead)
+	}
+
+	n := min(uint64(len(values)), free)
+	for i := uint64(0); i < n; i++ {
+		r.values[(tail+i)&r.mask] = values[i]
+	}
+
+	r.tail.Store(tail + n)
+	return int(n)
+}
+
+// Pop must be called only by the consumer
+func (r *SPSCRing[T]) Pop() (T, bool) {
+	head := r.head.Load()
+	if head == r.cachedTail {
+		r.cachedTail = r.tail.Load()
+		if head == r.cachedTail {
+			var empty T
+			return empty, false
+		}
+	}
+
+	var empty T
+	index := head & r.mask
+	value := r.values[index]
+	r.values[index] = empty // avoid memory leak
+	r.head.Store(head + 1)
+	return value, true
+}
+
+// PopBatch fills values and returns number of popped values
+func (r *SPSCRing[T]) PopBatch(values []T) int {
+	head := r.head.Load()
+	if r.cachedTail-head < uint64(len(values)) {
+		r.cachedTail = r.tail.Load()
+	}
+
+	var empty T
+	n := min(uint64(len(values)), r.cachedTail-head)
+	for i := uint64(0); i < n; i++ {
+		index := (head + i) & r.mask
+		values[i] = r.values[index]
+		r.values[index] = empty
+	}
+
+	r.head.Store(head + n)
+	return int(n)
+}
+
+type mpmcCell[T any] struct {
+	// position which is expected by the next operation with the cell:
+	// equals to position for push and position+1 for pop
+	sequence atomic.Uint64
+	value    T
+}
+
+// MPMCRing is a lock-free bounded ring for many producers and consumers,
+// every cell has a sequence number which tells whose turn it is
+type MPMCRing[T any] struct {
+	_     cacheLinePad
+	head  atomic.Uint64 // next position to read
+	_     cacheLinePad
+	tail  atomic.Uint64 // next position to write
+	_     cacheLinePad
+	mask  uint64
+	cells []mpmcCell[T]
+}
+
+// NewMPMCRing rounds capacity up to a power of two and at least
+// two cells: with one cell sequences of a pushed and a popped
+// cell are equal, so producers overwrite values which are not popped
+func NewMPMCRing[T any](capacity int) *MPMCRing[T] {
+	size := powerOfTwo(max(capacity, 2))
+	ring := &MPMCRing[T]{
+		mask:  uint64(size - 1),
+		cells: make([]mpmcCell[T], size),
+	}
+
+	for i := range ring.cells {
+		ring.cells[i].sequence.Store(uint64(i))
+	}
+
+	return ring
+}
+
+func (r *MPMCRing[T]) Cap() int {
+	return len(r.cells)
+}
+
+func (r *MPMCRing[T]) Push(value T) bool {
+	position := r.tail.Load()
+	for {
+		cell := &r.cells[position&r.mask]
+		difference := int64(cell.sequence.Load() - position)
+		switch {
+		case difference == 0:
+			if r.tail.CompareAndSwap(position, position+1) {
+				cell.value = value
+				cell.sequence.Store(position + 1)
+				return true
+			}
+			position = r.tail.Load()
+		case difference < 0:
+			return false // the cell is not popped yet, ring is full
+		default:
+			position = r.tail.Load() // another producer took the position
+		}
+	}
+}
+
+func (r *MPMCRing[T]) Pop() (T, bool) {
+	position := r.head.Load()
+	for {
+		cell := &r.cells[position&r.mask]
+		difference := int64(cell.sequence.Load() - (position + 1))
+		switch {
+		case difference == 0:
+			if r.head.CompareAndSwap(position, position+1) {
+				var empty T
+				value := cell.value
+				cell.value = empty // avoid memory leak
+				cell.sequence.Store(position + r.mask + 1)
+				return value, true
+			}
+			position = r.head.Load()
+		case difference < 0:
+			var empty T
+			return empty, false // the cell is not pushed yet, ring is empty
+		default:
+			position = r.head.Load()
+		}
+	}
+}
+
+// PushBatch pushes values one by one, other producers can interleave them
+func (r *MPMCRing[T]) PushBatch(values []T) int {
+	for i, value := range values {
+		if !r.Push(value) {
+			return i
+		}
+	}
+
+	return len(values)
+}
+
+func (r *MPMCRing[T]) PopBatch(values []T) int {
+	for i := range values {
+		value, ok := r.Pop()
+		if !ok {
+			return i
+		}
+		values[i] = value
+	}
+
+	return len(values)
+}
+
+func powerOfTwo(capacity int) int {
+	size := 1
+	for size < capacity {
+		size <<= 1
+	}
+
+	return size
+}
+
+// BlockingRing waits while the ring is full or empty: it spins
+// first for low latency and then sleeps to not burn the CPU
+type BlockingRing[T any] struct {
+	ring Ring[T]
+}
+
+func NewBlockingRing[T any](ring Ring[T]) BlockingRing[T] {
+	return BlockingRing[T]{ring: ring}
+}
+
+func (b BlockingRing[T]) Push(ctx context.Context, value T) error {
+	var wait backoff
+	for !b.ring.Push(value) {
+		if err := wait.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b BlockingRing[T]) Pop(ctx context.Context) (T, error) {
+	var wait backoff
+	for {
+		if value, ok := b.ring.Pop(); ok {
+			return value, nil
+		}
+
+		if err := wait.wait(ctx); err != nil {
+			var empty T
+			return empty, err
+		}
+	}
+}
+
+const (
+	backoffSpins    = 100
+	backoffMaxSleep = time.Millisecond
+)
+
+type backoff struct {
+	attempts int
+	sleep    time.Duration
+}
+
+func (b *backoff) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.attempts++
+	if b.attempts <= backoffSpins {
+		runtime.Gosched()
+		return nil
+	}
+
+	b.sleep = min(max(2*b.sleep, time.Microsecond), backoffMaxSleep)
+	timer := time.NewTimer(b.sleep)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+var rings = map[string]func(capacity int) Ring[int]{
+	"spsc": func(capacity int) Ring[int] { return NewSPSCRing[int](capacity) },
+	"mpmc": func(capacity int) Ring[int] { return NewMPMCRing[int](capacity) },
+}
+
+func TestRing(t *testing.T) {
+	for name, newRing := range rings {
+		t.Run(name, func(t *testing.T) {
+			ring := newRing(3)
+			assert.Equal(t, 4, ring.Cap())
+
+			_, ok := ring.Pop()
+			assert.False(t, ok)
+
+			for i := 0; i < 10; i++ {
+				for j := 0; j < 4; j++ {
+					assert.True(t, ring.Push(i*4+j))
+				}
+				assert.False(t, ring.Push(-1))
+
+				for j := 0; j < 4; j++ {
+					value, ok := ring.Pop()
+					assert.True(t, ok)
+					assert.Equal(t, i*4+j, value)
+				}
+				_, ok = ring.Pop()
+				assert.False(t, ok)
+			}
+		})
+	}
+}
+
+func TestRingBatch(t *testing.T) {
+	type batchRing interface {
+		Ring[int]
+		PushBatch([]int) int
+		PopBatch([]int) int
+	}
+
+	for name, newRing := range rings {
+		t.Run(name, func(t *testing.T) {
+			ring := newRing(4).(batchRing)
+			assert.Equal(t, 3, ring.PushBatch([]int{1, 2, 3}))
+			assert.Equal(t, 1, ring.PushBatch([]int{4, 5, 6}))
+
+			values := make([]int, 3)
+			assert.Equal(t, 3, ring.PopBatch(values))
+			assert.Equal(t, []int{1, 2, 3}, values)
+
+			assert.Equal(t, 2, ring.PushBatch([]int{5, 6}))
+			assert.Equal(t, 3, ring.PopBatch(values))
+			assert.Equal(t, []int{4, 5, 6}, values)
+			assert.Equal(t, 0, ring.PopBatch(values))
+		})
+	}
+}
+
+func TestRingReleasesValues(t *testing.T) {
+	spsc := NewSPSCRing[*int](1)
+	spsc.Push(new(int))
+	spsc.Pop()
+	assert.Nil(t, spsc.values[0])
+
+	mpmc := NewMPMCRing[*int](1)
+	mpmc.Push(new(int))
+	mpmc.Pop()
+	assert.Nil(t, mpmc.cells[0].value)
+}
+
+func TestMPMCRingMinimalCapacity(t *testing.T) {
+	for _, capacity := range []int{0, 1} {
+		ring := NewMPMCRing[int](capacity)
+		assert.Equal(t, 2, ring.Cap())
+		assert.True(t, ring.Push(1))
+		assert.True(t, ring.Push(2))
+		assert.False(t, ring.Push(3))
+
+		for _, expected := range []int{1, 2} {
+			value, ok := ring.Pop()
+			assert.True(t, ok)
+			assert.Equal(t, expected, value)
+		}
+		_, ok := ring.Pop()
+		assert.False(t, ok)
+	}
+}
+
+func TestRingLayout(t *testing.T) {
+	// rings are not aligned to cache lines, so the last byte of one
+	// side and the first byte of the other one must be a line apart
+	var spsc SPSCRing[int]
+	consumerEnd := unsafe.Offsetof(spsc.cachedTail) + unsafe.Sizeof(spsc.cachedTail)
+	assert.GreaterOrEqual(t, unsafe.Offsetof(spsc.tail)-consumerEnd+1, uintptr(cacheLineSize))
+
+	var mpmc MPMCRing[int]
+	headEnd := unsafe.Offsetof(mpmc.head) + unsafe.Sizeof(mpmc.head)
+	assert.GreaterOrEqual(t, unsafe.Offsetof(mpmc.tail)-headEnd+1, uintptr(cacheLineSize))
+}
+
+func TestSPSCRingConcurrent(t *testing.T) {
+	const valuesNumber = 100_000
+	ring := NewBlockingRing[int](NewSPSCRing[int](64))
+
+	go func() {
+		for i := 0; i < valuesNumber; i++ {
+			_ = ring.Push(context.Background(), i)
+		}
+	}()
+
+	for i := 0; i < valuesNumber; i++ {
+		value, err := ring.Pop(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+}
+
+func TestMPMCRingConcurrent(t *testing.T) {
+	const producersNumber, consumersNumber = 4, 4
+	const valuesNumber = 20_000
+	ring := NewBlockingRing[int](NewMPMCRing[int](64))
+
+	var producers sync.WaitGroup
+	producers.Add(producersNumber)
+	for p := 0; p < producersNumber; p++ {
+		go func() {
+			defer producers.Done()
+			for i := 1; i <= valuesNumber; i++ {
+				_ = ring.Push(context.Background(), i)
+			}
+		}()
+	}
+
+	var sum atomic.Int64
+	var consumers sync.WaitGroup
+	consumers.Add(consumersNumber)
+	for c := 0; c < consumersNumber; c++ {
+		go func() {
+			defer consumers.Done()
+			for i := 0; i < producersNumber*valuesNumber/consumersNumber; i++ {
+				value, _ := ring.Pop(context.Background())
+				sum.Add(int64(value))
+			}
+		}()
+	}
+
+	producers.Wait()
+	consumers.Wait()
+	assert.Equal(t, int64(producersNumber*valuesNumber*(valuesNumber+1)/2), sum.Load())
+}
+
+func TestBlockingRingCancel(t *testing.T) {
+	ring := NewBlockingRing[int](NewSPSCRing[int](1))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := ring.Pop(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, ring.Push(ctx, 1))
+	assert.ErrorIs(t, ring.Push(ctx, 2), context.DeadlineExceeded)
+}
+
+const benchmarkRingCapacity = 1024
+
+func benchmarkRing(b *testing.B, push func(int), pop func()) {
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < b.N; i++ {
+			pop()
+		}
+		close(done)
+	}()
+
+	for i := 0; i < b.N; i++ {
+		push(i)
+	}
+	<-done
+}
+
+func BenchmarkSPSCRing(b *testing.B) {
+	ring := NewBlockingRing[int](NewSPSCRing[int](benchmarkRingCapacity))
+	benchmarkRing(b,
+		func(value int) { _ = ring.Push(context.Background(), value) },
+		func() { _, _ = ring.Pop(context.Background()) },
+	)
+}
+
+func BenchmarkMPMCRing(b *testing.B) {
+	ring := NewBlockingRing[int](NewMPMCRing[int](benchmarkRingCapacity))
+	benchmarkRing(b,
+		func(value int) { _ = ring.Push(context.Background(), value) },
+		func() { _, _ = ring.Pop(context.Background()) },
+	)
+}
+
+func BenchmarkChannel(b *testing.B) {
+	channel := make(chan int, benchmarkRingCapacity)
+	benchmarkRing(b,
+		func(value int) { channel <- value },
+		func() { <-channel },
+	)
+}
+
+func BenchmarkMutexCircularQueue(b *testing.B) {
+	var mutex sync.Mutex
+	queue := NewCircularQueue[int](benchmarkRingCapacity)
+	benchmarkRing(b,
+		func(value int) {
+			for {
+				mutex.Lock()
+				pushed := queue.Push(value)
+				mutex.Unlock()
+				if pushed {
+					return
+				}
+				runtime.Gosched()
+			}
+		},
+		func() {
+			for {
+				mutex.Lock()
+				popped := queue.Pop()
+				mutex.Unlock()
+				if popped {
+					return
+				}
+				runtime.Gosched()
+			}
+		},
+	)
+}
@@ -0,0 +1,302 @@
+// Package sliceutil contains generic slice helpers. Helpers which change
+// elements (Insert, RemoveAt, RemoveIf, Dedup, DedupUnstable and Compact)
+// work in place like package slices: the result shares the array with the
+// argument and removed elements are zeroed. Helpers which return parts of
+// the argument (Chunk, Partition, Clip, Shrink and Subslice) clip them,
+// so appending to a part never overwrites elements of the argument.
+package sliceutil
+
+import (
+	"iter"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// GrowthPolicy returns new capacity for a slice with capacity
+// oldCapacity which has to fit at least needed elements
+type GrowthPolicy func(oldCapacity, needed int) int
+
+// Doubling doubles capacity until it fits
+func Doubling(oldCapacity, needed int) int {
+	capacity := max(oldCapacity, 1)
+	for capacity < needed {
+		capacity *= 2
+	}
+
+	return capacity
+}
+
+// GoGrowth is the policy of the runtime without rounding up to size
+// classes: small slices double, big ones grow smoothly from 2x to 1.25x
+func GoGrowth(oldCapacity, needed int) int {
+	const threshold = 256
+
+	if needed > 2*oldCapacity {
+		return needed
+	}
+	if oldCapacity < threshold {
+		return 2 * oldCapacity
+	}
+
+	capacity := oldCapacity
+	for capacity < needed {
+		capacity += (capacity + 3*threshold) >> 2
+	}
+
+	return capacity
+}
+
+// Exact allocates only what is needed, appending in a loop is quadratic
+func Exact(_, needed int) int {
+	return needed
+}
+
+// Grow makes capacity for n more elements according to the policy
+func Grow[S ~[]E, E any](policy GrowthPolicy, s S, n int) S {
+	if n < 0 {
+		panic("sliceutil: negative grow size")
+	}
+
+	needed := len(s) + n
+	if needed <= cap(s) {
+		return s
+	}
+
+	grown := make(S, len(s), max(policy(cap(s), needed), needed))
+	copy(grown, s)
+	return grown
+}
+
+// Append is append with a custom growth policy
+func Append[S ~[]E, E any](policy GrowthPolicy, s S, values ...E) S {
+	s = Grow(policy, s, len(values))
+	length := len(s)
+	s = s[:length+len(values)]
+	copy(s[length:], values)
+	return s
+}
+
+// Copy is the builtin copy: it copies min(len(dst), len(src)) elements
+// and handles overlapping slices like memmove
+func Copy[E any](dst, src []E) int {
+	n := min(len(dst), len(src))
+	if n == 0 {
+		return 0
+	}
+
+	dst, src = dst[:n], src[:n]
+	if uintptr(unsafe.Pointer(&dst[0])) > uintptr(unsafe.Pointer(&src[0])) && overlaps(dst, src) {
+		// destination is after the source, copy from the end
+		// to not overwrite elements before they are read
+		for i := n - 1; i >= 0; i-- {
+			dst[i] = src[i]
+		}
+		return n
+	}
+
+	for i := 0; i < n; i++ {
+		dst[i] = src[i]
+	}
+
+	return n
+}
+
+// overlaps reports whether slices share elements of the same array
+func overlaps[E any](a, b []E) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+
+	size := unsafe.Sizeof(a[0])
+	if size == 0 {
+		return false
+	}
+
+	aStart, bStart := uintptr(unsafe.Pointer(&a[0])), uintptr(unsafe.Pointer(&b[0]))
+	aEnd, bEnd := aStart+uintptr(len(a))*size, bStart+uintptr(len(b))*size
+	return aStart < bEnd && bStart < aEnd
+}
+
+// Insert inserts values at index i, values can be a part of s
+func Insert[S ~[]E, E any](s S, i int, values ...E) S {
+	_ = s[i:] // bounds check
+
+	if overlaps(s[:cap(s)], values) {
+		values = slices.Clone(values) // shifting would change them
+	}
+
+	n := len(values)
+	length := len(s)
+	s = Grow(GoGrowth, s, n)[:length+n]
+	copy(s[i+n:], s[i:length])
+	copy(s[i:], values)
+	return s
+}
+
+// RemoveAt removes elements s[i:j] keeping the order
+// and zeroes the tail so removed values can be collected
+func RemoveAt[S ~[]E, E any](s S, i, j int) S {
+	_ = s[i:j:len(s)] // bounds check
+
+	if i == j {
+		return s
+	}
+
+	length := len(s)
+	s = append(s[:i], s[j:]...)
+	clear(s[len(s):length])
+	return s
+}
+
+// RemoveIf removes elements matching the predicate keeping the order
+func RemoveIf[S ~[]E, E any](s S, remove func(E) bool) S {
+	kept := 0
+	for _, value := range s {
+		if !remove(value) {
+			s[kept] = value
+			kept++
+		}
+	}
+
+	clear(s[kept:])
+	return s[:kept]
+}
+
+// Chunk iterates consecutive parts of size n, the last one can be
+// shorter; capacity of parts is clipped, so appending to a part
+// allocates instead of overwriting the next one
+func Chunk[S ~[]E, E any](s S, n int) iter.Seq[S] {
+	if n <= 0 {
+		panic("sliceutil: chunk size must be positive")
+	}
+
+	return func(yield func(S) bool) {
+		for i := 0; i < len(s); i += n {
+			end := min(i+n, len(s))
+			if !yield(s[i:end:end]) {
+				return
+			}
+		}
+	}
+}
+
+// Partition reorders s so elements matching the predicate go first,
+// relative order of both parts is kept. Parts are clipped.
+func Partition[S ~[]E, E any](s S, match func(E) bool) (matched, rest S) {
+	var buffer S
+	kept := 0
+	for _, value := range s {
+		if match(value) {
+			s[kept] = value
+			kept++
+		} else {
+			buffer = append(buffer, value)
+		}
+	}
+
+	copy(s[kept:], buffer)
+	return s[:kept:kept], s[kept:len(s):len(s)]
+}
+
+// Dedup removes repeated values keeping their first occurrences
+func Dedup[S ~[]E, E comparable](s S) S {
+	seen := make(map[E]struct{}, len(s))
+	return RemoveIf(s, func(value E) bool {
+		if _, found := seen[value]; found {
+			return true
+		}
+
+		seen[value] = struct{}{}
+		return false
+	})
+}
+
+// DedupUnstable removes repeated values like Dedup, but a duplicate
+// is replaced by the last element instead of shifting the rest,
+// so only duplicates are moved and the order is not kept
+func DedupUnstable[S ~[]E, E comparable](s S) S {
+	seen := make(map[E]struct{}, len(s))
+	length := len(s)
+	for i := 0; i < length; {
+		if _, found := seen[s[i]]; found {
+			length--
+			s[i] = s[length]
+			continue
+		}
+
+		seen[s[i]] = struct{}{}
+		i++
+	}
+
+	clear(s[length:])
+	return s[:length]
+}
+
+// Compact replaces runs of equal elements with a single copy
+// keeping the order, it is Dedup for sorted slices
+func Compact[S ~[]E, E comparable](s S) S {
+	if len(s) < 2 {
+		return s
+	}
+
+	kept := 1
+	for i := 1; i < len(s); i++ {
+		if s[i] != s[kept-1] {
+			s[kept] = s[i]
+			kept++
+		}
+	}
+
+	clear(s[kept:])
+	return s[:kept]
+}
+
+// Clip removes spare capacity, so append to the result never writes
+// into the array visible through s (see lessons dangerous_append)
+func Clip[S ~[]E, E any](s S) S {
+	return s[:len(s):len(s)]
+}
+
+// parts shorter than 1/detachRatio of the array are copied by
+// Shrink, Subslice and Substring instead of sharing the array
+const detachRatio = 4
+
+// Shrink copies s into a new array when it uses a small part
+// of its capacity, so the part does not retain a huge array
+// (see lessons big_slice_incorrect). Shrink always clips.
+func Shrink[S ~[]E, E any](s S) S {
+	if cap(s) > detachRatio*len(s) {
+		shrunk := make(S, len(s))
+		copy(shrunk, s)
+		return shrunk
+	}
+
+	return Clip(s)
+}
+
+// Subslice returns clipped s[i:j] or its copy when it is small relative
+// to the capacity of s, use it for values which outlive s. The capacity
+// of s is used instead of the capacity of s[i:j], which is small
+// for parts at the end of a big array.
+func Subslice[S ~[]E, E any](s S, i, j int) S {
+	part := s[i:j:j]
+	if cap(s) > detachRatio*len(part) {
+		detached := make(S, len(part))
+		copy(detached, part)
+		return detached
+	}
+
+	return part
+}
+
+// Substring returns s[i:j] or its copy when it is small relative to s
+// (see lessons leak_with_string). Unlike slices strings have no capacity,
+// so the decision is made by the length of s.
+func Substring(s string, i, j int) string {
+	if len(s) > detachRatio*(j-i) {
+		return strings.Clone(s[i:j])
+	}
+
+	return s[i:j]
+}
@@ -0,0 +1,289 @@
+package sliceutil
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -bench=. sliceutil_test.go sliceutil.go
+
+func TestGrowthPolicies(t *testing.T) {
+	assert.Equal(t, 1, Doubling(0, 1))
+	assert.Equal(t, 16, Doubling(4, 9))
+	assert.Equal(t, 8, GoGrowth(4, 5))
+	assert.Equal(t, 20, GoGrowth(4, 20))
+	assert.Equal(t, 512, GoGrowth(256, 257)) // 256 + (256+768)/4
+	assert.Equal(t, 1024+448, GoGrowth(1024, 1025))
+	assert.Equal(t, 9, Exact(4, 9))
+}
+
+func TestAppend(t *testing.T) {
+	for name, policy := range map[string]GrowthPolicy{"doubling": Doubling, "go": GoGrowth, "exact": Exact} {
+		t.Run(name, func(t *testing.T) {
+			var data []int
+			var capacities []int
+			for i := 0; i < 10; i++ {
+				data = Append(policy, data, i)
+				capacities = append(capacities, cap(data))
+			}
+
+			assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, data)
+			if name == "exact" {
+				assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, capacities)
+			} else {
+				assert.Equal(t, []int{1, 2, 4, 4, 8, 8, 8, 8, 16, 16}, capacities)
+			}
+		})
+	}
+}
+
+func TestCopy(t *testing.T) {
+	dst := make([]int, 3)
+	assert.Equal(t, 3, Copy(dst, []int{1, 2, 3, 4, 5}))
+	assert.Equal(t, []int{1, 2, 3}, dst)
+
+	data := []int{1, 2, 3, 4, 5}
+	assert.Equal(t, 4, Copy(data[1:], data))
+	assert.Equal(t, []int{1, 1, 2, 3, 4}, data)
+
+	data = []int{1, 2, 3, 4, 5}
+	assert.Equal(t, 4, Copy(data, data[1:]))
+	assert.Equal(t, []int{2, 3, 4, 5, 5}, data)
+}
+
+func TestInsert(t *testing.T) {
+	data := make([]int, 3, 10)
+	copy(data, []int{1, 2, 3})
+
+	data = Insert(data, 1, 10, 11)
+	assert.Equal(t, []int{1, 10, 11, 2, 3}, data)
+	data = Insert(data, 5, 12)
+	assert.Equal(t, []int{1, 10, 11, 2, 3, 12}, data)
+	data = Insert(data, 0)
+	assert.Equal(t, []int{1, 10, 11, 2, 3, 12}, data)
+
+	// inserted values are a part of the slice which is shifted
+	data = Insert(data, 0, data[1:4]...)
+	assert.Equal(t, []int{10, 11, 2, 1, 10, 11, 2, 3, 12}, data)
+
+	assert.Panics(t, func() { Insert(data, 10, 1) })
+}
+
+func TestRemoveAt(t *testing.T) {
+	values := []*int{new(int), new(int), new(int), new(int)}
+	data := slices.Clone(values)
+
+	data = RemoveAt(data, 1, 3)
+	assert.Equal(t, []*int{values[0], values[3]}, data)
+	assert.Nil(t, data[:4][2], "removed values must be released")
+	assert.Nil(t, data[:4][3])
+
+	assert.Equal(t, data, RemoveAt(data, 1, 1))
+	assert.Panics(t, func() { RemoveAt(data, 1, 3) })
+}
+
+func TestRemoveIf(t *testing.T) {
+	data := []int{1, 2, 3, 4, 5, 6}
+	data = RemoveIf(data, func(value int) bool { return value%2 == 0 })
+	assert.Equal(t, []int{1, 3, 5}, data)
+	assert.Equal(t, []int{1, 3, 5, 0, 0, 0}, data[:6])
+}
+
+func TestChunk(t *testing.T) {
+	data := []int{1, 2, 3, 4, 5}
+	var chunks [][]int
+	for chunk := range Chunk(data, 2) {
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, chunks)
+
+	// appending to a chunk must not change the next one
+	_ = append(chunks[0], 100)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, data)
+
+	assert.Panics(t, func() { Chunk(data, 0) })
+}
+
+func TestPartition(t *testing.T) {
+	data := []int{1, 2, 3, 4, 5, 6, 7}
+	even, odd := Partition(data, func(value int) bool { return value%2 == 0 })
+	assert.Equal(t, []int{2, 4, 6}, even)
+	assert.Equal(t, []int{1, 3, 5, 7}, odd)
+	assert.Equal(t, []int{2, 4, 6, 1, 3, 5, 7}, data)
+
+	_ = append(even, 100)
+	assert.Equal(t, 1, odd[0])
+}
+
+func TestDedupAndCompact(t *testing.T) {
+	assert.Equal(t, []int{3, 1, 2}, Dedup([]int{3, 1, 3, 2, 1, 2}))
+	assert.Equal(t, []int{3, 1, 3, 2}, Compact([]int{3, 3, 1, 3, 2, 2}))
+
+	data := []int{3, 1, 3, 2, 1, 2}
+	assert.Equal(t, []int{3, 1, 2}, DedupUnstable(data))
+	assert.Equal(t, []int{3, 1, 2, 0, 0, 0}, data)
+	assert.Equal(t, []string{"b", "a"}, DedupUnstable([]string{"b", "b", "a", "b"}))
+	assert.Empty(t, DedupUnstable([]int(nil)))
+	assert.Empty(t, Compact([]int{}))
+	assert.Equal(t, []string{"a"}, Compact([]string{"a", "a"}))
+}
+
+func TestClipAndShrink(t *testing.T) {
+	// see lessons dangerous_append
+	data := make([]int, 4, 5)
+	clipped := Clip(data)
+	_ = append(clipped, 5)
+	assert.Equal(t, 0, data[:5][4])
+
+	// see lessons big_slice_incorrect
+	big := make([]byte, 1<<20)
+	part := Shrink(big[10:30])
+	assert.Equal(t, 20, cap(part))
+	part[0] = 1
+	assert.Equal(t, byte(0), big[10])
+
+	// small part of a small array is clipped without copying
+	part = Shrink(big[:1<<19])
+	assert.Equal(t, 1<<19, cap(part))
+	part[0] = 1
+	assert.Equal(t, byte(1), big[0])
+}
+
+func TestSubsliceAndSubstring(t *testing.T) {
+	body := make([]byte, 1<<10)
+	header := Subslice(body, 10, 20)
+	assert.Len(t, header, 10)
+	assert.Equal(t, 10, cap(header))
+	assert.NotSame(t, &body[10], &header[0])
+
+	// a part at the end has small capacity but retains the whole body
+	tail := Subslice(body, len(body)-10, len(body))
+	assert.Equal(t, body[len(body)-10:], tail)
+	assert.NotSame(t, &body[len(body)-10], &tail[0])
+	assert.Panics(t, func() { Subslice(body, 20, 10) })
+
+	// big part is not worth copying
+	part := Subslice(body, 0, 512)
+	assert.Equal(t, 512, cap(part))
+	assert.Same(t, &body[0], &part[0])
+
+	text := strings.Repeat("a", 1<<10)
+	small := Substring(text, 10, 20)
+	assert.Equal(t, text[10:20], small)
+	assert.NotSame(t, unsafe.StringData(text[10:]), unsafe.StringData(small))
+	assert.Same(t, unsafe.StringData(text), unsafe.StringData(Substring(text, 0, 512)))
+}
+
+const benchmarkSize = 1 << 12
+
+func benchmarkData() []int {
+	random := rand.New(rand.NewSource(1))
+	data := make([]int, benchmarkSize)
+	for i := range data {
+		data[i] = random.Intn(benchmarkSize / 4)
+	}
+
+	return data
+}
+
+func BenchmarkAppend(b *testing.B) {
+	b.Run("builtin", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var data []int
+			for j := 0; j < benchmarkSize; j++ {
+				data = append(data, j)
+			}
+		}
+	})
+
+	for name, policy := range map[string]GrowthPolicy{"doubling": Doubling, "go": GoGrowth} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var data []int
+				for j := 0; j < benchmarkSize; j++ {
+					data = Append(policy, data, j)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	b.Run("slices", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			data := make([]int, 0, 256)
+			for j := 0; j < 256; j++ {
+				data = slices.Insert(data, len(data)/2, j)
+			}
+		}
+	})
+	b.Run("sliceutil", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			data := make([]int, 0, 256)
+			for j := 0; j < 256; j++ {
+				data = Insert(data, len(data)/2, j)
+			}
+		}
+	})
+}
+
+func BenchmarkRemoveIf(b *testing.B) {
+	source := benchmarkData()
+	data := make([]int, len(source))
+	even := func(value int) bool { return value%2 == 0 }
+
+	b.Run("slices", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			copy(data, source)
+			_ = slices.DeleteFunc(data, even)
+		}
+	})
+	b.Run("sliceutil", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			copy(data, source)
+			_ = RemoveIf(data, even)
+		}
+	})
+}
+
+func BenchmarkCompact(b *testing.B) {
+	source := benchmarkData()
+	slices.Sort(source)
+	data := make([]int, len(source))
+
+	b.Run("slices", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			copy(data, source)
+			_ = slices.Compact(data)
+		}
+	})
+	b.Run("sliceutil", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			copy(data, source)
+			_ = Compact(data)
+		}
+	})
+}
+
+func BenchmarkChunk(b *testing.B) {
+	data := benchmarkData()
+	b.Run("slices", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for chunk := range slices.Chunk(data, 16) {
+				_ = chunk
+			}
+		}
+	})
+	b.Run("sliceutil", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for chunk := range Chunk(data, 16) {
+				_ = chunk
+			}
+		}
+	})
+}
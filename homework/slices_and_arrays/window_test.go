@@ -0,0 +1,507 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v homework_test.go window_test.go
+
+const defaultRelativeAccuracy = 0.01
+
+type windowSample struct {
+	value    float64
+	at       time.Time
+	sequence uint64 // identifies the sample in min and max queues
+}
+
+// SlidingWindow aggregates the last samples by number or by age.
+// Sum, mean and variance are updated in O(1), min and max are kept
+// in monotonic queues with amortised O(1) and percentiles are
+// approximated by logarithmic buckets with bounded relative error.
+type SlidingWindow struct {
+	samples  CircularQueue[windowSample]
+	size     int           // for count windows
+	duration time.Duration // for time windows
+	clock    func() time.Time
+	sequence uint64
+
+	sum  float64
+	mean float64
+	m2   float64 // sum of squared differences from the mean, see Welford
+
+	minimums CircularQueue[windowSample] // increasing values
+	maximums CircularQueue[windowSample] // decreasing values
+	sketch   quantileSketch
+	accuracy float64
+}
+
+type WindowOption func(*SlidingWindow)
+
+func WithWindowClock(clock func() time.Time) WindowOption {
+	return func(window *SlidingWindow) {
+		window.clock = clock
+	}
+}
+
+// WithRelativeAccuracy sets relative error of percentiles between
+// 0 and 1 exclusive, better accuracy requires more buckets
+func WithRelativeAccuracy(accuracy float64) WindowOption {
+	if !(accuracy > 0 && accuracy < 1) { // NaN too
+		panic("relative accuracy must be between 0 and 1")
+	}
+
+	return func(window *SlidingWindow) {
+		window.accuracy = accuracy
+	}
+}
+
+// NewCountWindow keeps the last size samples
+func NewCountWindow(size int, options ...WindowOption) *SlidingWindow {
+	if size <= 0 {
+		panic("window size must be positive")
+	}
+
+	window := newSlidingWindow(NewCircularQueue[windowSample](size), options)
+	window.size = size
+	return window
+}
+
+// NewTimeWindow keeps samples which are not older than duration
+func NewTimeWindow(duration time.Duration, options ...WindowOption) *SlidingWindow {
+	if duration <= 0 {
+		panic("window duration must be positive")
+	}
+
+	window := newSlidingWindow(NewGrowableCircularQueue[windowSample](16), options)
+	window.duration = duration
+	return window
+}
+
+func newSlidingWindow(samples CircularQueue[windowSample], options []WindowOption) *SlidingWindow {
+	window := &SlidingWindow{
+		samples:  samples,
+		clock:    time.Now,
+		minimums: NewGrowableCircularQueue[windowSample](16),
+		maximums: NewGrowableCircularQueue[windowSample](16),
+		accuracy: defaultRelativeAccuracy,
+	}
+
+	for _, option := range options {
+		option(window)
+	}
+
+	window.sketch = newQuantileSketch(window.accuracy)
+	return window
+}
+
+// Add reports false and ignores NaN and infinite values,
+// they break the sum and can not be put into buckets
+func (w *SlidingWindow) Add(value float64) bool {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return false
+	}
+
+	now := w.clock()
+	w.expire(now)
+	if w.size != 0 && w.samples.Full() {
+		w.evict()
+	}
+
+	w.sequence++
+	sample := windowSample{value: value, at: now, sequence: w.sequence}
+	w.samples.Push(sample)
+
+	n := float64(w.samples.Len())
+	delta := value - w.mean
+	w.mean += delta / n
+	w.m2 += delta * (value - w.mean)
+	w.sum += value
+
+	for back, ok := w.minimums.Back(); ok && back.value > value; back, ok = w.minimums.Back() {
+		w.minimums.PopBack()
+	}
+	w.minimums.Push(sample)
+
+	for back, ok := w.maximums.Back(); ok && back.value < value; back, ok = w.maximums.Back() {
+		w.maximums.PopBack()
+	}
+	w.maximums.Push(sample)
+
+	w.sketch.add(value)
+	return true
+}
+
+// expire removes samples which left the time window
+func (w *SlidingWindow) expire(now time.Time) {
+	if w.duration == 0 {
+		return
+	}
+
+	threshold := now.Add(-w.duration)
+	for front, ok := w.samples.Front(); ok && !front.at.After(threshold); front, ok = w.samples.Front() {
+		w.evict()
+	}
+}
+
+// evict removes the oldest sample
+func (w *SlidingWindow) evict() {
+	sample, _ := w.samples.Front()
+	w.samples.Pop()
+
+	if w.samples.Empty() {
+		// start from scratch to not accumulate rounding errors
+		w.sum, w.mean, w.m2 = 0, 0, 0
+	} else {
+		n := float64(w.samples.Len())
+		delta := sample.value - w.mean
+		w.mean -= delta / n
+		w.m2 -= delta * (sample.value - w.mean)
+		w.sum -= sample.value
+	}
+
+	if front, _ := w.minimums.Front(); front.sequence == sample.sequence {
+		w.minimums.Pop()
+	}
+	if front, _ := w.maximums.Front(); front.sequence == sample.sequence {
+		w.maximums.Pop()
+	}
+
+	w.sketch.remove(sample.value)
+}
+
+func (w *SlidingWindow) Len() int {
+	w.expire(w.clock())
+	return w.samples.Len()
+}
+
+func (w *SlidingWindow) Sum() float64 {
+	w.expire(w.clock())
+	return w.sum
+}
+
+func (w *SlidingWindow) Mean() float64 {
+	w.expire(w.clock())
+	return w.mean
+}
+
+// Variance returns population variance of samples in the window
+func (w *SlidingWindow) Variance() float64 {
+	w.expire(w.clock())
+	if w.samples.Empty() {
+		return 0
+	}
+
+	return max(w.m2/float64(w.samples.Len()), 0)
+}
+
+func (w *SlidingWindow) Min() (float64, bool) {
+	w.expire(w.clock())
+	sample, ok := w.minimums.Front()
+	return sample.value, ok
+}
+
+func (w *SlidingWindow) Max() (float64, bool) {
+	w.expire(w.clock())
+	sample, ok := w.maximums.Front()
+	return sample.value, ok
+}
+
+// Percentile returns approximate p-th percentile, p is between 0 and 100
+func (w *SlidingWindow) Percentile(p float64) (float64, bool) {
+	w.expire(w.clock())
+	if w.samples.Empty() {
+		return 0, false
+	}
+
+	minimum, _ := w.minimums.Front()
+	maximum, _ := w.maximums.Front()
+	value := w.sketch.quantile(min(max(p, 0), 100) / 100)
+	return min(max(value, minimum.value), maximum.value), true
+}
+
+// values closer to zero than this are counted together
+const minIndexableValue = 1e-9
+
+// quantileSketch counts values in buckets with exponentially growing
+// bounds, so every value in a bucket is close to its representative
+type quantileSketch struct {
+	gamma    float64
+	logGamma float64
+	positive map[int]int
+	negative map[int]int // by index of the absolute value
+	zeros    int
+	total    int
+}
+
+func newQuantileSketch(accuracy float64) quantileSketch {
+	gamma := (1 + accuracy) / (1 - accuracy)
+	return quantileSketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		positive: make(map[int]int),
+		negative: make(map[int]int),
+	}
+}
+
+func (s *quantileSketch) index(value float64) int {
+	return int(math.Ceil(math.Log(value) / s.logGamma))
+}
+
+// representative is within relative accuracy from all values of the bucket
+func (s *quantileSketch) representative(index int) float64 {
+	return 2 * math.Pow(s.gamma, float64(index)) / (s.gamma + 1)
+}
+
+func (s *quantileSketch) add(value float64) {
+	s.update(value, 1)
+}
+
+func (s *quantileSketch) remove(value float64) {
+	s.update(value, -1)
+}
+
+func (s *quantileSketch) update(value float64, delta int) {
+	s.total += delta
+	switch {
+	case value > minIndexableValue:
+		updateBucket(s.positive, s.index(value), delta)
+	case value < -minIndexableValue:
+		updateBucket(s.negative, s.index(-value), delta)
+	default:
+		s.zeros += delta
+	}
+}
+
+// updateBucket deletes empty buckets, otherwise a drifting
+// signal would leave a trail of them
+func updateBucket(buckets map[int]int, index int, delta int) {
+	buckets[index] += delta
+	if buckets[index] == 0 {
+		delete(buckets, index)
+	}
+}
+
+// quantile walks buckets from the smallest value, q is between 0 and 1
+func (s *quantileSketch) quantile(q float64) float64 {
+	rank := int(math.Round(q * float64(s.total-1)))
+
+	negative := sortedIndexes(s.negative)
+	for i := len(negative) - 1; i >= 0; i-- {
+		rank -= s.negative[negative[i]]
+		if rank < 0 {
+			return -s.representative(negative[i])
+		}
+	}
+
+	rank -= s.zeros
+	if rank < 0 {
+		return 0
+	}
+
+	positive := sortedIndexes(s.positive)
+	for _, index := range positive {
+		rank -= s.positive[index]
+		if rank < 0 {
+			return s.representative(index)
+		}
+	}
+
+	return 0 // unreachable while counts are consistent
+}
+
+func sortedIndexes(buckets map[int]int) []int {
+	indexes := make([]int, 0, len(buckets))
+	for index := range buckets {
+		indexes = append(indexes, index)
+	}
+
+	slices.Sort(indexes)
+	return indexes
+}
+
+type windowClock struct {
+	now time.Time
+}
+
+func (c *windowClock) Now() time.Time {
+	return c.now
+}
+
+func (c *windowClock) Advance(duration time.Duration) {
+	c.now = c.now.Add(duration)
+}
+
+func TestSlidingWindow_Empty(t *testing.T) {
+	window := NewCountWindow(3)
+	assert.Equal(t, 0, window.Len())
+	assert.Zero(t, window.Sum())
+	assert.Zero(t, window.Mean())
+	assert.Zero(t, window.Variance())
+
+	_, ok := window.Min()
+	assert.False(t, ok)
+	_, ok = window.Max()
+	assert.False(t, ok)
+	_, ok = window.Percentile(50)
+	assert.False(t, ok)
+}
+
+func TestSlidingWindow_Count(t *testing.T) {
+	window := NewCountWindow(3)
+	for _, value := range []float64{5, 1, 3, 4} {
+		window.Add(value)
+	}
+
+	// 5 left the window
+	assert.Equal(t, 3, window.Len())
+	assert.Equal(t, 8.0, window.Sum())
+	assert.InDelta(t, 8.0/3, window.Mean(), 1e-9)
+	assert.InDelta(t, 14.0/9, window.Variance(), 1e-9)
+
+	minimum, _ := window.Min()
+	assert.Equal(t, 1.0, minimum)
+	maximum, _ := window.Max()
+	assert.Equal(t, 4.0, maximum)
+
+	window.Add(2)
+	window.Add(2)
+	minimum, _ = window.Min()
+	assert.Equal(t, 2.0, minimum)
+	maximum, _ = window.Max()
+	assert.Equal(t, 4.0, maximum)
+}
+
+func TestSlidingWindow_Time(t *testing.T) {
+	clock := &windowClock{now: time.Unix(0, 0)}
+	window := NewTimeWindow(time.Minute, WithWindowClock(clock.Now))
+
+	window.Add(10)
+	clock.Advance(30 * time.Second)
+	window.Add(20)
+	clock.Advance(20 * time.Second)
+	window.Add(30)
+
+	assert.Equal(t, 3, window.Len())
+	assert.Equal(t, 60.0, window.Sum())
+
+	clock.Advance(10 * time.Second) // the first sample is exactly one minute old
+	assert.Equal(t, 2, window.Len())
+	assert.Equal(t, 50.0, window.Sum())
+	minimum, _ := window.Min()
+	assert.Equal(t, 20.0, minimum)
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, 0, window.Len())
+	assert.Zero(t, window.Sum())
+	_, ok := window.Max()
+	assert.False(t, ok)
+
+	window.Add(-1)
+	maximum, _ := window.Max()
+	assert.Equal(t, -1.0, maximum)
+}
+
+func TestSlidingWindow_Percentile(t *testing.T) {
+	window := NewCountWindow(100)
+	for i := 1; i <= 100; i++ {
+		window.Add(float64(i))
+	}
+
+	median, _ := window.Percentile(50)
+	assert.InEpsilon(t, 51.0, median, defaultRelativeAccuracy)
+	p99, _ := window.Percentile(99)
+	assert.InEpsilon(t, 99.0, p99, defaultRelativeAccuracy)
+	p100, _ := window.Percentile(100)
+	assert.Equal(t, 100.0, p100)
+	p0, _ := window.Percentile(0)
+	assert.Equal(t, 1.0, p0)
+}
+
+func TestSlidingWindow_InvalidValues(t *testing.T) {
+	for _, accuracy := range []float64{0, -0.1, 1, 2, math.NaN(), math.Inf(1)} {
+		assert.Panics(t, func() { WithRelativeAccuracy(accuracy) }, "accuracy %v", accuracy)
+	}
+
+	window := NewCountWindow(10)
+	assert.True(t, window.Add(1))
+	assert.False(t, window.Add(math.NaN()))
+	assert.False(t, window.Add(math.Inf(1)))
+	assert.False(t, window.Add(math.Inf(-1)))
+	assert.True(t, window.Add(3))
+
+	assert.Equal(t, 2, window.Len())
+	assert.Equal(t, 4.0, window.Sum())
+	median, _ := window.Percentile(50)
+	assert.False(t, math.IsNaN(median))
+	p100, _ := window.Percentile(100)
+	assert.InEpsilon(t, 3.0, p100, defaultRelativeAccuracy)
+}
+
+func TestSlidingWindow_Random(t *testing.T) {
+	const size = 50
+	random := rand.New(rand.NewSource(1))
+	window := NewCountWindow(size, WithRelativeAccuracy(0.02))
+
+	var values []float64
+	for i := 0; i < 2_000; i++ {
+		value := math.Round(random.NormFloat64()*1_000) / 10
+		if i%10 == 0 {
+			value = 0
+		}
+
+		window.Add(value)
+		values = append(values, value)
+		if len(values) > size {
+			values = values[1:]
+		}
+
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		mean := sum / float64(len(values))
+
+		var variance float64
+		for _, v := range values {
+			variance += (v - mean) * (v - mean)
+		}
+		variance /= float64(len(values))
+
+		require.InDelta(t, sum, window.Sum(), 1e-6)
+		require.InDelta(t, mean, window.Mean(), 1e-6)
+		require.InDelta(t, variance, window.Variance(), 1e-6)
+
+		minimum, _ := window.Min()
+		require.Equal(t, slices.Min(values), minimum)
+		maximum, _ := window.Max()
+		require.Equal(t, slices.Max(values), maximum)
+
+		sorted := slices.Sorted(slices.Values(values))
+		for _, p := range []float64{10, 50, 90, 99} {
+			expected := sorted[int(math.Round(p/100*float64(len(sorted)-1)))]
+			actual, _ := window.Percentile(p)
+			require.InDelta(t, expected, actual, math.Abs(expected)*0.02+1e-9, "p%v", p)
+		}
+	}
+}
+
+func BenchmarkSlidingWindow_Add(b *testing.B) {
+	window := NewCountWindow(1024)
+	random := rand.New(rand.NewSource(1))
+	values := make([]float64, 4096)
+	for i := range values {
+		values[i] = random.ExpFloat64() * 100
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		window.Add(values[i%len(values)])
+	}
+}
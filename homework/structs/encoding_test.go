@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -bench=. homework_test.go encoding_test.go
+
+// Wire format of GamePerson, all words are little endian and
+// contain the same bit-packed values as in memory:
+//
+//	version(1) | x(4) | y(4) | z(4) | gold(4) | stats(4) | flags(2) | name(42)
+const (
+	gamePersonEncodingVersion = 1
+	GamePersonEncodedSize     = 1 + 4*5 + 2 + maxNameLength
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported game person encoding version")
+	ErrInvalidGamePerson  = errors.New("invalid game person")
+)
+
+func (p *GamePerson) MarshalBinary() ([]byte, error) {
+	return p.AppendBinary(make([]byte, 0, GamePersonEncodedSize))
+}
+
+func (p *GamePerson) AppendBinary(data []byte) ([]byte, error) {
+	data = append(data, gamePersonEncodingVersion)
+	data = binary.LittleEndian.AppendUint32(data, uint32(p.x))
+	data = binary.LittleEndian.AppendUint32(data, uint32(p.y))
+	data = binary.LittleEndian.AppendUint32(data, uint32(p.z))
+	data = binary.LittleEndian.AppendUint32(data, p.gold)
+	data = binary.LittleEndian.AppendUint32(data, p.stats)
+	data = binary.LittleEndian.AppendUint16(data, p.flags)
+	data = append(data, p.name[:]...)
+	return data, nil
+}
+
+func (p *GamePerson) UnmarshalBinary(data []byte) error {
+	if len(data) != GamePersonEncodedSize {
+		return fmt.Errorf("%w: %d bytes instead of %d", ErrInvalidGamePerson, len(data), GamePersonEncodedSize)
+	}
+	if data[0] != gamePersonEncodingVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, data[0])
+	}
+
+	var person GamePerson
+	data = data[1:]
+	person.x = int32(binary.LittleEndian.Uint32(data[0:]))
+	person.y = int32(binary.LittleEndian.Uint32(data[4:]))
+	person.z = int32(binary.LittleEndian.Uint32(data[8:]))
+	person.gold = binary.LittleEndian.Uint32(data[12:])
+	person.stats = binary.LittleEndian.Uint32(data[16:])
+	person.flags = binary.LittleEndian.Uint16(data[20:])
+	copy(person.name[:], data[22:])
+
+	if err := person.validate(); err != nil {
+		return err
+	}
+
+	*p = person
+	return nil
+}
+
+// validate checks values which can be represented by bits but not by options
+func (p *GamePerson) validate() error {
+	limits := []struct {
+		name  string
+		value int
+		max   int
+	}{
+		{"mana", p.Mana(), maxMana},
+		{"health", p.Health(), maxHealth},
+		{"respect", p.Respect(), maxRespect},
+		{"strength", p.Strength(), maxStrength},
+		{"experience", p.Experience(), maxExperience},
+		{"level", p.Level(), maxLevel},
+		{"type", p.Type(), WarriorGamePersonType},
+	}
+
+	for _, limit := range limits {
+		if limit.value > limit.max {
+			return fmt.Errorf("%w: %s %d is greater than %d", ErrInvalidGamePerson, limit.name, limit.value, limit.max)
+		}
+	}
+
+	usedFlags := uint16(levelField.set(0, 1<<levelField.width-1) | typeField.set(0, 1<<typeField.width-1))
+	usedFlags |= houseFlag | gunFlag | familyFlag
+	if p.flags&^usedFlags != 0 {
+		return fmt.Errorf("%w: unknown flags %#x", ErrInvalidGamePerson, p.flags&^usedFlags)
+	}
+
+	length := bytes.IndexByte(p.name[:], 0)
+	if length >= 0 && bytes.ContainsFunc(p.name[length:], func(r rune) bool { return r != 0 }) {
+		return fmt.Errorf("%w: name has bytes after zero padding", ErrInvalidGamePerson)
+	}
+
+	return nil
+}
+
+// GamePersonEncoder writes fixed size records of game persons to the stream
+type GamePersonEncoder struct {
+	writer io.Writer
+	buffer []byte
+}
+
+func NewGamePersonEncoder(writer io.Writer) *GamePersonEncoder {
+	return &GamePersonEncoder{
+		writer: writer,
+		buffer: make([]byte, 0, GamePersonEncodedSize),
+	}
+}
+
+func (e *GamePersonEncoder) Encode(person *GamePerson) error {
+	e.buffer, _ = person.AppendBinary(e.buffer[:0])
+	_, err := e.writer.Write(e.buffer)
+	return err
+}
+
+// GamePersonDecoder reads records written by GamePersonEncoder
+type GamePersonDecoder struct {
+	reader io.Reader
+	buffer [GamePersonEncodedSize]byte
+}
+
+func NewGamePersonDecoder(reader io.Reader) *GamePersonDecoder {
+	return &GamePersonDecoder{reader: reader}
+}
+
+// Decode returns io.EOF when there are no more records
+func (d *GamePersonDecoder) Decode(person *GamePerson) error {
+	if _, err := io.ReadFull(d.reader, d.buffer[:]); err != nil {
+		return err
+	}
+
+	return person.UnmarshalBinary(d.buffer[:])
+}
+
+func newTestGamePerson() GamePerson {
+	return MustNewGamePerson(
+		WithName("aaaaaaaaaaaaa_bbbbbbbbbbbbb_cccccccccccccc"),
+		WithCoordinates(math.MinInt32, math.MaxInt32, -1),
+		WithGold(math.MaxUint32),
+		WithMana(maxMana),
+		WithHealth(500),
+		WithRespect(maxRespect),
+		WithStrength(1),
+		WithExperience(maxExperience),
+		WithLevel(maxLevel),
+		WithHouse(),
+		WithGun(),
+		WithFamily(),
+		WithType(WarriorGamePersonType),
+	)
+}
+
+func TestGamePersonBinaryRoundTrip(t *testing.T) {
+	persons := []GamePerson{
+		{},
+		newTestGamePerson(),
+		MustNewGamePerson(WithName("ÿ"), WithType(BlacksmithGamePersonType), WithFamily()),
+	}
+
+	for _, person := range persons {
+		data, err := person.MarshalBinary()
+		require.NoError(t, err)
+		assert.Len(t, data, GamePersonEncodedSize)
+
+		var decoded GamePerson
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		assert.Equal(t, person, decoded)
+		assert.True(t, utf8.ValidString(decoded.Name()))
+	}
+}
+
+func TestGamePersonBinaryLayout(t *testing.T) {
+	person := MustNewGamePerson(
+		WithName("ab"),
+		WithCoordinates(1, -1, 2),
+		WithGold(3),
+		WithMana(4),
+		WithLevel(5),
+		WithGun(),
+		WithType(WarriorGamePersonType),
+	)
+
+	data, err := person.MarshalBinary()
+	require.NoError(t, err)
+
+	expected := []byte{
+		gamePersonEncodingVersion,
+		1, 0, 0, 0,
+		0xff, 0xff, 0xff, 0xff,
+		2, 0, 0, 0,
+		3, 0, 0, 0,
+		4, 0, 0, 0,
+		0x25, 0x01, // level 5 | gun | warrior type << 7
+		'a', 'b',
+	}
+	expected = append(expected, make([]byte, maxNameLength-2)...)
+	assert.Equal(t, expected, data)
+}
+
+func TestGamePersonUnmarshalErrors(t *testing.T) {
+	person := newTestGamePerson()
+	valid, err := person.MarshalBinary()
+	require.NoError(t, err)
+
+	corrupt := func(change func(data []byte)) []byte {
+		data := bytes.Clone(valid)
+		change(data)
+		return data
+	}
+
+	tests := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"short":   {data: valid[:10], err: ErrInvalidGamePerson},
+		"version": {data: corrupt(func(data []byte) { data[0] = 2 }), err: ErrUnsupportedVersion},
+		"mana": {data: corrupt(func(data []byte) {
+			binary.LittleEndian.PutUint32(data[17:], manaField.set(person.stats, maxMana+1))
+		}), err: ErrInvalidGamePerson},
+		"type": {data: corrupt(func(data []byte) {
+			binary.LittleEndian.PutUint16(data[21:], uint16(typeField.set(uint32(person.flags), 3)))
+		}), err: ErrInvalidGamePerson},
+		"unknown flags": {data: corrupt(func(data []byte) { data[22] |= 0x80 }), err: ErrInvalidGamePerson},
+		"name after padding": {data: corrupt(func(data []byte) {
+			copy(data[23:], "a\x00b")
+		}), err: ErrInvalidGamePerson},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			decoded := newTestGamePerson()
+			err := decoded.UnmarshalBinary(test.data)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, person, decoded) // not changed on error
+		})
+	}
+}
+
+func TestGamePersonStream(t *testing.T) {
+	persons := []GamePerson{
+		newTestGamePerson(),
+		MustNewGamePerson(WithName("second"), WithGold(10)),
+		MustNewGamePerson(WithName(strings.Repeat("z", maxNameLength))),
+	}
+
+	var stream bytes.Buffer
+	encoder := NewGamePersonEncoder(&stream)
+	for i := range persons {
+		require.NoError(t, encoder.Encode(&persons[i]))
+	}
+	assert.Equal(t, len(persons)*GamePersonEncodedSize, stream.Len())
+
+	var decoded []GamePerson
+	decoder := NewGamePersonDecoder(&stream)
+	for {
+		var person GamePerson
+		err := decoder.Decode(&person)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		decoded = append(decoded, person)
+	}
+
+	assert.Equal(t, persons, decoded)
+}
+
+func TestGamePersonStreamTruncated(t *testing.T) {
+	person := newTestGamePerson()
+	data, err := person.MarshalBinary()
+	require.NoError(t, err)
+
+	decoder := NewGamePersonDecoder(bytes.NewReader(data[:GamePersonEncodedSize-1]))
+	assert.ErrorIs(t, decoder.Decode(&person), io.ErrUnexpectedEOF)
+}
+
+func BenchmarkGamePersonEncoder(b *testing.B) {
+	person := newTestGamePerson()
+	encoder := NewGamePersonEncoder(io.Discard)
+	b.ReportAllocs()
+	b.SetBytes(GamePersonEncodedSize)
+
+	for i := 0; i < b.N; i++ {
+		_ = encoder.Encode(&person)
+	}
+}
+
+func BenchmarkGamePersonUnmarshal(b *testing.B) {
+	person := newTestGamePerson()
+	data, _ := person.MarshalBinary()
+	b.ReportAllocs()
+	b.SetBytes(GamePersonEncodedSize)
+
+	for i := 0; i < b.N; i++ {
+		_ = person.UnmarshalBinary(data)
+	}
+}
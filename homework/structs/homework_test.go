@@ -1,91 +1,142 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"math"
+	"strings"
 	"testing"
 	"unsafe"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"golang_course/homework/structs/layout"
 )
 
-type Option func(*GamePerson)
+var ErrInvalidOption = errors.New("invalid game person option")
+
+// Option sets a field of GamePerson and rejects values which
+// cannot be stored without truncation
+type Option func(*GamePerson) error
 
-func WithName(name string) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
+func WithName(name string) Option {
+	return func(person *GamePerson) error {
+		if len(name) > maxNameLength || strings.IndexByte(name, 0) >= 0 {
+			return fmt.Errorf("%w: name %q: up to %d bytes without zero bytes expected", ErrInvalidOption, name, maxNameLength)
+		}
+
+		person.name = [maxNameLength]byte{}
+		copy(person.name[:], name)
+		return nil
 	}
 }
 
-func WithCoordinates(x, y, z int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
+func WithCoordinates(x, y, z int) Option {
+	return func(person *GamePerson) error {
+		err := errors.Join(
+			checkRange("x", x, math.MinInt32, math.MaxInt32),
+			checkRange("y", y, math.MinInt32, math.MaxInt32),
+			checkRange("z", z, math.MinInt32, math.MaxInt32),
+		)
+		if err != nil {
+			return err
+		}
+
+		person.x = int32(x)
+		person.y = int32(y)
+		person.z = int32(z)
+		return nil
 	}
 }
 
-func WithGold(gold int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
+// WithGold takes uint32 which is stored as is,
+// int can not hold all values on 32-bit platforms
+func WithGold(gold uint32) Option {
+	return func(person *GamePerson) error {
+		person.gold = gold
+		return nil
 	}
 }
 
-func WithMana(mana int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
-	}
+func WithMana(mana int) Option {
+	return withStat("mana", mana, maxMana, manaField)
 }
 
-func WithHealth(health int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
-	}
+func WithHealth(health int) Option {
+	return withStat("health", health, maxHealth, healthField)
 }
 
-func WithRespect(respect int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
-	}
+func WithRespect(respect int) Option {
+	return withStat("respect", respect, maxRespect, respectField)
 }
 
-func WithStrength(strength int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
-	}
+func WithStrength(strength int) Option {
+	return withStat("strength", strength, maxStrength, strengthField)
 }
 
-func WithExperience(experience int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
+func WithExperience(experience int) Option {
+	return withStat("experience", experience, maxExperience, experienceField)
+}
+
+func WithLevel(level int) Option {
+	return withFlags("level", level, 0, maxLevel, levelField)
+}
+
+func WithHouse() Option {
+	return func(person *GamePerson) error {
+		person.flags |= houseFlag
+		return nil
 	}
 }
 
-func WithLevel(level int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
+func WithGun() Option {
+	return func(person *GamePerson) error {
+		person.flags |= gunFlag
+		return nil
 	}
 }
 
-func WithHouse() func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
+func WithFamily() Option {
+	return func(person *GamePerson) error {
+		person.flags |= familyFlag
+		return nil
 	}
 }
 
-func WithGun() func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
+func WithType(personType int) Option {
+	return withFlags("type", personType, BuilderGamePersonType, WarriorGamePersonType, typeField)
+}
+
+func withStat(name string, value, max int, field bitField) Option {
+	return func(person *GamePerson) error {
+		if err := checkRange(name, value, 0, max); err != nil {
+			return err
+		}
+
+		person.stats = field.set(person.stats, value)
+		return nil
 	}
 }
 
-func WithFamily() func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
+func withFlags(name string, value, min, max int, field bitField) Option {
+	return func(person *GamePerson) error {
+		if err := checkRange(name, value, min, max); err != nil {
+			return err
+		}
+
+		person.flags = uint16(field.set(uint32(person.flags), value))
+		return nil
 	}
 }
 
-func WithType(personType int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		// need to implement
+func checkRange(name string, value, min, max int) error {
+	if value < min || value > max {
+		return fmt.Errorf("%w: %s %d is out of range [%d, %d]", ErrInvalidOption, name, value, min, max)
 	}
+
+	return nil
 }
 
 const (
@@ -94,88 +145,156 @@ const (
 	WarriorGamePersonType
 )
 
+// int32 limits widened by one, int is int32 on 32-bit
+// platforms and can not go beyond them
+const (
+	belowInt32 = max(math.MinInt, math.MinInt32-1)
+	aboveInt32 = min(math.MaxInt, math.MaxInt32+1)
+)
+
+const (
+	maxNameLength = 42
+	maxMana       = 1000
+	maxHealth     = 1000
+	maxRespect    = 10
+	maxStrength   = 10
+	maxExperience = 10
+	maxLevel      = 10
+)
+
+// bitField describes width bits of a word starting from shift
+type bitField struct {
+	shift uint
+	width uint
+}
+
+func (f bitField) get(word uint32) int {
+	return int(word >> f.shift & (1<<f.width - 1))
+}
+
+func (f bitField) set(word uint32, value int) uint32 {
+	mask := uint32(1<<f.width-1) << f.shift
+	return word&^mask | uint32(value)<<f.shift&mask
+}
+
+// stats: mana(10) | health(10) | respect(4) | strength(4) | experience(4)
+var (
+	manaField       = bitField{shift: 0, width: 10}
+	healthField     = bitField{shift: 10, width: 10}
+	respectField    = bitField{shift: 20, width: 4}
+	strengthField   = bitField{shift: 24, width: 4}
+	experienceField = bitField{shift: 28, width: 4}
+)
+
+// flags: level(4) | house(1) | gun(1) | family(1) | type(2)
+var (
+	levelField = bitField{shift: 0, width: 4}
+	typeField  = bitField{shift: 7, width: 2}
+)
+
+const (
+	houseFlag  = 1 << 4
+	gunFlag    = 1 << 5
+	familyFlag = 1 << 6
+)
+
+// GamePerson is packed into 64 bytes, fields are
+// ordered by alignment so there are no padding holes
 type GamePerson struct {
-	// need to implement
+	x, y, z int32
+	gold    uint32
+	stats   uint32
+	flags   uint16
+	name    [maxNameLength]byte // zero padded
 }
 
-func NewGamePerson(options ...Option) GamePerson {
-	// need to implement
-	return GamePerson{}
+// NewGamePerson applies options in order, later options override
+// earlier ones. It fails on the first invalid option.
+func NewGamePerson(options ...Option) (GamePerson, error) {
+	var person GamePerson
+	for _, option := range options {
+		if err := option(&person); err != nil {
+			return GamePerson{}, err
+		}
+	}
+
+	return person, nil
+}
+
+// MustNewGamePerson is NewGamePerson for options known to be valid,
+// like constants, it panics on invalid options
+func MustNewGamePerson(options ...Option) GamePerson {
+	person, err := NewGamePerson(options...)
+	if err != nil {
+		panic(err)
+	}
+
+	return person
 }
 
 func (p *GamePerson) Name() string {
-	// need to implement
-	return ""
+	length := bytes.IndexByte(p.name[:], 0)
+	if length < 0 {
+		length = maxNameLength
+	}
+
+	return string(p.name[:length])
 }
 
 func (p *GamePerson) X() int {
-	// need to implement
-	return 0
+	return int(p.x)
 }
 
 func (p *GamePerson) Y() int {
-	// need to implement
-	return 0
+	return int(p.y)
 }
 
 func (p *GamePerson) Z() int {
-	// need to implement
-	return 0
+	return int(p.z)
 }
 
 func (p *GamePerson) Gold() int {
-	// need to implement
-	return 0
+	return int(p.gold)
 }
 
 func (p *GamePerson) Mana() int {
-	// need to implement
-	return 0
+	return manaField.get(p.stats)
 }
 
 func (p *GamePerson) Health() int {
-	// need to implement
-	return 0
+	return healthField.get(p.stats)
 }
 
 func (p *GamePerson) Respect() int {
-	// need to implement
-	return 0
+	return respectField.get(p.stats)
 }
 
 func (p *GamePerson) Strength() int {
-	// need to implement
-	return 0
+	return strengthField.get(p.stats)
 }
 
 func (p *GamePerson) Experience() int {
-	// need to implement
-	return 0
+	return experienceField.get(p.stats)
 }
 
 func (p *GamePerson) Level() int {
-	// need to implement
-	return 0
+	return levelField.get(uint32(p.flags))
 }
 
 func (p *GamePerson) HasHouse() bool {
-	// need to implement
-	return false
+	return p.flags&houseFlag != 0
 }
 
 func (p *GamePerson) HasGun() bool {
-	// need to implement
-	return false
+	return p.flags&gunFlag != 0
 }
 
 func (p *GamePerson) HasFamilty() bool {
-	// need to implement
-	return false
+	return p.flags&familyFlag != 0
 }
 
 func (p *GamePerson) Type() int {
-	// need to implement
-	return 0
+	return typeField.get(uint32(p.flags))
 }
 
 func TestGamePerson(t *testing.T) {
@@ -207,7 +326,8 @@ func TestGamePerson(t *testing.T) {
 		WithType(personType),
 	}
 
-	person := NewGamePerson(options...)
+	person, err := NewGamePerson(options...)
+	require.NoError(t, err)
 	assert.Equal(t, name, person.Name())
 	assert.Equal(t, x, person.X())
 	assert.Equal(t, y, person.Y())
@@ -224,3 +344,58 @@ func TestGamePerson(t *testing.T) {
 	assert.False(t, person.HasGun())
 	assert.Equal(t, personType, person.Type())
 }
+
+func TestGamePersonOutOfRange(t *testing.T) {
+	tests := map[string]Option{
+		"long name":  WithName(strings.Repeat("a", maxNameLength+1)),
+		"zero byte":  WithName("a\x00b"),
+		"mana":       WithMana(maxMana + 1),
+		"health":     WithHealth(-1),
+		"respect":    WithRespect(maxRespect + 1),
+		"strength":   WithStrength(maxStrength + 1),
+		"experience": WithExperience(maxExperience + 1),
+		"level":      WithLevel(maxLevel + 1),
+		"type":       WithType(WarriorGamePersonType + 1),
+	}
+
+	if aboveInt32 > math.MaxInt32 {
+		tests["x"] = WithCoordinates(aboveInt32, 0, 0)
+		tests["z"] = WithCoordinates(0, 0, belowInt32)
+	}
+
+	for name, option := range tests {
+		t.Run(name, func(t *testing.T) {
+			person, err := NewGamePerson(WithName("valid"), option)
+			assert.ErrorIs(t, err, ErrInvalidOption)
+			assert.Zero(t, person)
+		})
+	}
+
+	assert.Panics(t, func() { MustNewGamePerson(WithLevel(-1)) })
+}
+
+func TestGamePersonOptionsOverride(t *testing.T) {
+	person := MustNewGamePerson(
+		WithName("long name"),
+		WithName("name"),
+		WithMana(maxMana),
+		WithMana(1),
+		WithLevel(maxLevel),
+		WithGun(),
+		WithType(WarriorGamePersonType),
+		WithType(BlacksmithGamePersonType),
+	)
+
+	assert.Equal(t, "name", person.Name())
+	assert.Equal(t, 1, person.Mana())
+	assert.Equal(t, maxLevel, person.Level())
+	assert.True(t, person.HasGun())
+	assert.False(t, person.HasHouse())
+	assert.Equal(t, BlacksmithGamePersonType, person.Type())
+}
+
+func TestGamePersonLayout(t *testing.T) {
+	personLayout := layout.MustAnalyze(GamePerson{})
+	assert.True(t, personLayout.IsOptimal(), personLayout.String())
+	assert.Zero(t, personLayout.Padding(), personLayout.String())
+}
@@ -0,0 +1,217 @@
+package layout
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// CacheLineSize is the most common size of cache lines on amd64 and arm64
+const CacheLineSize = 64
+
+type Field struct {
+	Name    string
+	Type    reflect.Type
+	Offset  uintptr
+	Size    uintptr
+	Align   uintptr
+	Padding uintptr // hole after the field
+}
+
+type Layout struct {
+	Type   reflect.Type
+	Size   uintptr
+	Align  uintptr
+	Fields []Field
+}
+
+// Analyze describes fields of the struct in memory
+func Analyze(structType reflect.Type) (Layout, error) {
+	if structType.Kind() != reflect.Struct {
+		return Layout{}, fmt.Errorf("struct expected instead of %s", structType)
+	}
+
+	layout := Layout{
+		Type:   structType,
+		Size:   structType.Size(),
+		Align:  uintptr(structType.Align()),
+		Fields: make([]Field, structType.NumField()),
+	}
+
+	for i := range layout.Fields {
+		field := structType.Field(i)
+		layout.Fields[i] = Field{
+			Name:   field.Name,
+			Type:   field.Type,
+			Offset: field.Offset,
+			Size:   field.Type.Size(),
+			Align:  uintptr(field.Type.Align()),
+		}
+	}
+
+	for i := range layout.Fields {
+		end := layout.Size
+		if i+1 < len(layout.Fields) {
+			end = layout.Fields[i+1].Offset
+		}
+
+		layout.Fields[i].Padding = end - layout.Fields[i].Offset - layout.Fields[i].Size
+	}
+
+	return layout, nil
+}
+
+// MustAnalyze is like Analyze but takes a value and panics on error, it is handy in tests
+func MustAnalyze(value any) Layout {
+	layout, err := Analyze(reflect.TypeOf(value))
+	if err != nil {
+		panic(err)
+	}
+
+	return layout
+}
+
+// Padding returns number of bytes wasted by alignment
+func (l Layout) Padding() uintptr {
+	var padding uintptr
+	for _, field := range l.Fields {
+		padding += field.Padding
+	}
+
+	return padding
+}
+
+// OptimalOrder returns field names in the order with the smallest size:
+// zero-size fields first (a zero-size field at the end gets padding),
+// then by alignment descending, original order is kept for equal fields
+func (l Layout) OptimalOrder() []string {
+	fields := l.optimalFields()
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name
+	}
+
+	return names
+}
+
+// optimalFields sorts a copy of fields instead of names,
+// blank fields have the same name and can not be found by it
+func (l Layout) optimalFields() []Field {
+	fields := slices.Clone(l.Fields)
+	slices.SortStableFunc(fields, func(a, b Field) int {
+		if (a.Size == 0) != (b.Size == 0) {
+			if a.Size == 0 {
+				return -1
+			}
+			return 1
+		}
+
+		return int(b.Align) - int(a.Align)
+	})
+
+	return fields
+}
+
+// OptimalSize returns size of the struct with fields in OptimalOrder
+func (l Layout) OptimalSize() uintptr {
+	var offset uintptr
+	fields := l.optimalFields()
+	for _, field := range fields {
+		offset = alignUp(offset, field.Align) + field.Size
+	}
+
+	// address of a zero-size field at the end
+	// must not point outside of the struct
+	if len(fields) != 0 && fields[len(fields)-1].Size == 0 && offset != 0 {
+		offset++
+	}
+
+	return alignUp(offset, l.Align)
+}
+
+func (l Layout) IsOptimal() bool {
+	return l.Size == l.OptimalSize()
+}
+
+// SharingRisk describes two fields used by different
+// goroutines which can be placed in the same cache line
+type SharingRisk struct {
+	First, Second string
+	Distance      uintptr // bytes between fields
+}
+
+func (r SharingRisk) String() string {
+	return fmt.Sprintf("%s and %s are %d bytes apart", r.First, r.Second, r.Distance)
+}
+
+// FalseSharing takes names of fields used by each goroutine. The struct
+// is not guaranteed to start at a cache line boundary, so the last byte
+// of one field and the first byte of another share a line when they
+// are less than CacheLineSize bytes apart. Blank fields can not be used.
+func (l Layout) FalseSharing(groups ...[]string) ([]SharingRisk, error) {
+	fields := make(map[string]Field, len(l.Fields))
+	for _, field := range l.Fields {
+		if field.Name != "_" {
+			fields[field.Name] = field
+		}
+	}
+
+	for _, group := range groups {
+		for _, name := range group {
+			if _, found := fields[name]; !found {
+				return nil, fmt.Errorf("unknown field %s of %s", name, l.Type)
+			}
+		}
+	}
+
+	var risks []SharingRisk
+	for i, group := range groups {
+		for _, other := range groups[i+1:] {
+			for _, firstName := range group {
+				for _, secondName := range other {
+					first, second := fields[firstName], fields[secondName]
+					if first.Offset > second.Offset {
+						first, second = second, first
+					}
+
+					distance := uintptr(0)
+					if end := first.Offset + first.Size; second.Offset > end {
+						distance = second.Offset - end
+					}
+
+					if distance+1 < CacheLineSize {
+						risks = append(risks, SharingRisk{First: first.Name, Second: second.Name, Distance: distance})
+					}
+				}
+			}
+		}
+	}
+
+	return risks, nil
+}
+
+func (l Layout) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%s: size %d, align %d, padding %d, optimal size %d\n",
+		l.Type, l.Size, l.Align, l.Padding(), l.OptimalSize())
+
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "offset\tsize\talign\tpadding\tfield\ttype")
+	for _, field := range l.Fields {
+		fmt.Fprintf(writer, "%d\t%d\t%d\t%d\t%s\t%s\n",
+			field.Offset, field.Size, field.Align, field.Padding, field.Name, field.Type)
+	}
+	_ = writer.Flush()
+
+	if !l.IsOptimal() {
+		fmt.Fprintf(&builder, "suggested order: %s\n", strings.Join(l.OptimalOrder(), ", "))
+	}
+
+	return builder.String()
+}
+
+func alignUp(offset, align uintptr) uintptr {
+	return (offset + align - 1) &^ (align - 1)
+}
@@ -0,0 +1,145 @@
+package layout
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v layout_test.go layout.go
+
+type data1 struct {
+	aaa bool
+	bbb int32
+	ccc bool
+}
+
+type data2 struct {
+	aaa int32
+	bbb bool
+	ccc bool
+}
+
+type finalZeroField struct {
+	x int64
+	a struct{}
+}
+
+type counters struct {
+	reads  atomic.Int64
+	writes atomic.Int64
+}
+
+type paddedCounters struct {
+	reads  atomic.Int64
+	_      [CacheLineSize]byte
+	writes atomic.Int64
+}
+
+func TestAnalyze(t *testing.T) {
+	layout := MustAnalyze(data1{})
+	assert.Equal(t, uintptr(12), layout.Size)
+	assert.Equal(t, uintptr(4), layout.Align)
+	assert.Equal(t, []Field{
+		{Name: "aaa", Type: reflect.TypeOf(false), Offset: 0, Size: 1, Align: 1, Padding: 3},
+		{Name: "bbb", Type: reflect.TypeOf(int32(0)), Offset: 4, Size: 4, Align: 4, Padding: 0},
+		{Name: "ccc", Type: reflect.TypeOf(false), Offset: 8, Size: 1, Align: 1, Padding: 3},
+	}, layout.Fields)
+	assert.Equal(t, uintptr(6), layout.Padding())
+
+	_, err := Analyze(reflect.TypeOf(0))
+	assert.Error(t, err)
+	assert.Panics(t, func() { MustAnalyze("") })
+}
+
+func TestOptimalOrder(t *testing.T) {
+	layout := MustAnalyze(data1{})
+	assert.Equal(t, []string{"bbb", "aaa", "ccc"}, layout.OptimalOrder())
+	assert.Equal(t, uintptr(8), layout.OptimalSize())
+	assert.False(t, layout.IsOptimal())
+
+	layout = MustAnalyze(data2{})
+	assert.Equal(t, []string{"aaa", "bbb", "ccc"}, layout.OptimalOrder())
+	assert.True(t, layout.IsOptimal())
+	assert.Equal(t, uintptr(2), layout.Padding())
+}
+
+func TestOptimalOrderZeroSizeField(t *testing.T) {
+	layout := MustAnalyze(finalZeroField{})
+	assert.Equal(t, 8+unsafe.Alignof(int64(0)), layout.Size) // the zero-size field gets padding
+	assert.Equal(t, []string{"a", "x"}, layout.OptimalOrder())
+	assert.Equal(t, uintptr(8), layout.OptimalSize())
+	assert.False(t, layout.IsOptimal())
+
+	assert.True(t, MustAnalyze(struct{}{}).IsOptimal())
+	assert.True(t, MustAnalyze(struct{ a, b struct{} }{}).IsOptimal())
+}
+
+func TestOptimalOrderBlankFields(t *testing.T) {
+	layout := MustAnalyze(struct {
+		a int64
+		_ [7]byte
+		b int64
+		_ [56]byte
+		c bool
+	}{})
+	size := uintptr(88)
+	if unsafe.Alignof(int64(0)) == 4 { // 32-bit platforms
+		size = 84
+	}
+	assert.Equal(t, size, layout.Size)
+	assert.Equal(t, []string{"a", "b", "_", "_", "c"}, layout.OptimalOrder())
+	assert.Equal(t, uintptr(80), layout.OptimalSize())
+	assert.False(t, layout.IsOptimal())
+
+	assert.True(t, MustAnalyze(struct {
+		a int32
+		_ [2]byte
+		_ [2]byte
+	}{}).IsOptimal())
+}
+
+func TestFalseSharing(t *testing.T) {
+	risks, err := MustAnalyze(counters{}).FalseSharing([]string{"reads"}, []string{"writes"})
+	require.NoError(t, err)
+	assert.Equal(t, []SharingRisk{{First: "reads", Second: "writes", Distance: 0}}, risks)
+	assert.Equal(t, "reads and writes are 0 bytes apart", risks[0].String())
+
+	// padding up to the line size is enough only for aligned structs
+	risks, err = MustAnalyze(struct {
+		reads  atomic.Int64
+		_      [CacheLineSize - 8]byte
+		writes atomic.Int64
+	}{}).FalseSharing([]string{"reads"}, []string{"writes"})
+	require.NoError(t, err)
+	assert.Len(t, risks, 1)
+
+	risks, err = MustAnalyze(paddedCounters{}).FalseSharing([]string{"reads"}, []string{"writes"})
+	require.NoError(t, err)
+	assert.Empty(t, risks)
+
+	// fields of one goroutine do not conflict
+	risks, err = MustAnalyze(counters{}).FalseSharing([]string{"reads", "writes"})
+	require.NoError(t, err)
+	assert.Empty(t, risks)
+
+	_, err = MustAnalyze(counters{}).FalseSharing([]string{"unknown"})
+	assert.Error(t, err)
+	_, err = MustAnalyze(paddedCounters{}).FalseSharing([]string{"reads"}, []string{"_"})
+	assert.Error(t, err)
+}
+
+func TestString(t *testing.T) {
+	expected := `layout.data1: size 12, align 4, padding 6, optimal size 8
+offset  size  align  padding  field  type
+0       1     1      3        aaa    bool
+4       4     4      0        bbb    int32
+8       1     1      3        ccc    bool
+suggested order: bbb, aaa, ccc
+`
+	assert.Equal(t, expected, MustAnalyze(data1{}).String())
+}
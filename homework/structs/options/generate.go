@@ -0,0 +1,262 @@
+package options
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Generate returns source code of WithX options for fields of the struct,
+// the code must be placed into the package of the struct. Field tag
+// `option:"name"` changes name of the option, `option:"-"` skips the field.
+// Generic structs and fields of generic or non-empty interface literal
+// types are rejected, reflection does not keep their type parameters.
+func Generate(structType reflect.Type) ([]byte, error) {
+	if structType.Kind() != reflect.Struct || structType.Name() == "" {
+		return nil, fmt.Errorf("named struct expected instead of %s", structType)
+	}
+	if isGeneric(structType) {
+		return nil, fmt.Errorf("generic struct %s is not supported", structType)
+	}
+
+	imports := make(map[string]string)
+	qualifier := ""
+	if optionsPath := reflect.TypeOf(Option[struct{}]{}).PkgPath(); optionsPath != structType.PkgPath() {
+		imports[optionsPath] = "options"
+		qualifier = "options."
+	}
+
+	var body bytes.Buffer
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name := field.Tag.Get("option")
+		if name == "-" || field.Anonymous {
+			continue
+		}
+		if name == "" {
+			name = lowerFirst(field.Name)
+		}
+
+		fieldType, err := qualifiedType(field.Type, structType.PkgPath(), imports)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		fmt.Fprintf(&body, "\nfunc With%s(value %s) %sOption[%s] {\n", camelCase(name), fieldType, qualifier, structType.Name())
+		fmt.Fprintf(&body, "\treturn %sFrom(%q, value, func(target *%s) {\n", qualifier, name, structType.Name())
+		fmt.Fprintf(&body, "\t\ttarget.%s = value\n", field.Name)
+		fmt.Fprintf(&body, "\t})\n}\n")
+	}
+
+	var source bytes.Buffer
+	fmt.Fprintf(&source, "// Code generated by options.Generate; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&source, "package %s\n\n", packageName(structType))
+	if len(imports) != 0 {
+		fmt.Fprintf(&source, "import (\n")
+		for _, importPath := range sortedKeys(imports) {
+			if name := imports[importPath]; name != path.Base(importPath) {
+				fmt.Fprintf(&source, "\t%s %q\n", name, importPath)
+			} else {
+				fmt.Fprintf(&source, "\t%q\n", importPath)
+			}
+		}
+		fmt.Fprintf(&source, ")\n")
+	}
+	source.Write(body.Bytes())
+
+	return format.Source(source.Bytes())
+}
+
+// qualifiedType returns type as it is written in the package pkgPath
+// and collects imports of other packages
+func qualifiedType(t reflect.Type, pkgPath string, imports map[string]string) (string, error) {
+	if t.Name() != "" {
+		if isGeneric(t) {
+			return "", fmt.Errorf("generic type %s is not supported", t)
+		}
+		if t.PkgPath() == "" {
+			return t.Name(), nil // predeclared
+		}
+		if t.PkgPath() == pkgPath {
+			return t.Name(), nil
+		}
+
+		imports[t.PkgPath()] = packageName(t)
+		return t.String(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Chan:
+		element, err := qualifiedType(t.Elem(), pkgPath, imports)
+		if err != nil {
+			return "", err
+		}
+
+		return compositeType(t, element), nil
+	case reflect.Map:
+		key, err := qualifiedType(t.Key(), pkgPath, imports)
+		if err != nil {
+			return "", err
+		}
+		element, err := qualifiedType(t.Elem(), pkgPath, imports)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("map[%s]%s", key, element), nil
+	case reflect.Func:
+		return funcType(t, pkgPath, imports)
+	case reflect.Struct:
+		return structType(t, pkgPath, imports)
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return "", fmt.Errorf("interface literal %s is not supported", t)
+		}
+
+		return "any", nil
+	default:
+		return t.String(), nil
+	}
+}
+
+func compositeType(t reflect.Type, element string) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + element
+	case reflect.Slice:
+		return "[]" + element
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), element)
+	}
+
+	switch t.ChanDir() {
+	case reflect.RecvDir:
+		return "<-chan " + element
+	case reflect.SendDir:
+		return "chan<- " + element
+	default:
+		return "chan " + element
+	}
+}
+
+func funcType(t reflect.Type, pkgPath string, imports map[string]string) (string, error) {
+	in := make([]string, t.NumIn())
+	for i := range in {
+		parameter, err := qualifiedType(t.In(i), pkgPath, imports)
+		if err != nil {
+			return "", err
+		}
+		in[i] = parameter
+	}
+	if t.IsVariadic() {
+		in[len(in)-1] = "..." + strings.TrimPrefix(in[len(in)-1], "[]")
+	}
+
+	out := make([]string, t.NumOut())
+	for i := range out {
+		result, err := qualifiedType(t.Out(i), pkgPath, imports)
+		if err != nil {
+			return "", err
+		}
+		out[i] = result
+	}
+
+	signature := "func(" + strings.Join(in, ", ") + ")"
+	switch len(out) {
+	case 0:
+		return signature, nil
+	case 1:
+		return signature + " " + out[0], nil
+	default:
+		return signature + " (" + strings.Join(out, ", ") + ")", nil
+	}
+}
+
+func structType(t reflect.Type, pkgPath string, imports map[string]string) (string, error) {
+	fields := make([]string, t.NumField())
+	for i := range fields {
+		field := t.Field(i)
+		if field.PkgPath != "" && field.PkgPath != pkgPath {
+			// unexported names of other packages can not be written
+			return "", fmt.Errorf("struct literal %s of package %s is not supported", t, field.PkgPath)
+		}
+
+		fieldType, err := qualifiedType(field.Type, pkgPath, imports)
+		if err != nil {
+			return "", err
+		}
+
+		fields[i] = fieldType
+		if !field.Anonymous {
+			fields[i] = field.Name + " " + fieldType
+		}
+		if tag := string(field.Tag); strings.ContainsRune(tag, '`') {
+			fields[i] += " " + strconv.Quote(tag)
+		} else if tag != "" {
+			fields[i] += " `" + tag + "`"
+		}
+	}
+
+	if len(fields) == 0 {
+		return "struct{}", nil
+	}
+
+	return "struct{ " + strings.Join(fields, "; ") + " }", nil
+}
+
+// isGeneric reports whether the named type is an instantiation,
+// reflection writes its type arguments with full import paths
+func isGeneric(t reflect.Type) bool {
+	return strings.ContainsRune(t.Name(), '[')
+}
+
+// packageName takes name of the package from the type name,
+// it can differ from the last element of the import path
+func packageName(t reflect.Type) string {
+	name, _, found := strings.Cut(t.String(), ".")
+	if !found {
+		return path.Base(t.PkgPath())
+	}
+
+	return name
+}
+
+func camelCase(name string) string {
+	var result strings.Builder
+	upper := true
+	for _, r := range name {
+		if r == '_' || r == '-' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		result.WriteRune(r)
+	}
+
+	return result.String()
+}
+
+func lowerFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+func sortedKeys(imports map[string]string) []string {
+	keys := make([]string, 0, len(imports))
+	for key := range imports {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,174 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrMutuallyExclusive = errors.New("mutually exclusive options")
+
+// Option configures T and can reject invalid values. Name and value
+// are kept for introspection, so the effective configuration can be
+// logged without knowing how options are implemented. The zero Option
+// does nothing.
+type Option[T any] struct {
+	name  string
+	value any
+	group string // options of the same group can not be used together
+	apply func(*T) error
+}
+
+func New[T any](name string, value any, apply func(*T) error) Option[T] {
+	return Option[T]{name: name, value: value, apply: apply}
+}
+
+// From adapts an option which can not fail
+func From[T any](name string, value any, apply func(*T)) Option[T] {
+	return New(name, value, func(target *T) error {
+		apply(target)
+		return nil
+	})
+}
+
+// Exclusive returns copy of the option which conflicts
+// with other options of the same group
+func (o Option[T]) Exclusive(group string) Option[T] {
+	o.group = group
+	return o
+}
+
+func (o Option[T]) Name() string {
+	return o.name
+}
+
+func (o Option[T]) Value() any {
+	return o.value
+}
+
+func (o Option[T]) String() string {
+	return fmt.Sprintf("%s=%v", o.name, o.value)
+}
+
+// Setting is an applied option, see Settings
+type Setting struct {
+	Name  string
+	Value any
+}
+
+func (s Setting) String() string {
+	return fmt.Sprintf("%s=%v", s.Name, s.Value)
+}
+
+// Settings returns effective values of options in order
+// of the first use, later options override earlier ones
+func Settings[T any](options ...Option[T]) []Setting {
+	positions := make(map[string]int, len(options))
+	settings := make([]Setting, 0, len(options))
+	for _, option := range options {
+		if option.apply == nil {
+			continue
+		}
+		if position, found := positions[option.name]; found {
+			settings[position].Value = option.value
+			continue
+		}
+
+		positions[option.name] = len(settings)
+		settings = append(settings, Setting{Name: option.name, Value: option.value})
+	}
+
+	return settings
+}
+
+// Describe formats settings for logs
+func Describe[T any](options ...Option[T]) string {
+	settings := Settings(options...)
+	parts := make([]string, 0, len(settings))
+	for _, setting := range settings {
+		parts = append(parts, setting.String())
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Constructor applies defaults, options and validators in this order
+type Constructor[T any] struct {
+	defaults   []func(*T)
+	validators []func(*T) error
+}
+
+func NewConstructor[T any]() *Constructor[T] {
+	return &Constructor[T]{}
+}
+
+// WithDefaults adds a hook which sets default values before options
+func (c *Constructor[T]) WithDefaults(hook func(*T)) *Constructor[T] {
+	c.defaults = append(c.defaults, hook)
+	return c
+}
+
+// WithValidator adds a check of the whole object after options,
+// it is useful for constraints between several fields
+func (c *Constructor[T]) WithValidator(validator func(*T) error) *Constructor[T] {
+	c.validators = append(c.validators, validator)
+	return c
+}
+
+func (c *Constructor[T]) New(options ...Option[T]) (T, error) {
+	var target T
+	for _, hook := range c.defaults {
+		hook(&target)
+	}
+
+	if err := Apply(&target, options...); err != nil {
+		var empty T
+		return empty, err
+	}
+
+	for _, validator := range c.validators {
+		if err := validator(&target); err != nil {
+			var empty T
+			return empty, err
+		}
+	}
+
+	return target, nil
+}
+
+// Apply checks exclusive groups and applies options to the target,
+// the target can be partially changed when an option fails
+func Apply[T any](target *T, options ...Option[T]) error {
+	if err := checkExclusive(options); err != nil {
+		return err
+	}
+
+	for _, option := range options {
+		if option.apply == nil {
+			continue
+		}
+		if err := option.apply(target); err != nil {
+			return fmt.Errorf("option %s: %w", option.name, err)
+		}
+	}
+
+	return nil
+}
+
+func checkExclusive[T any](options []Option[T]) error {
+	groups := make(map[string]string)
+	for _, option := range options {
+		if option.group == "" {
+			continue
+		}
+
+		name, found := groups[option.group]
+		if found && name != option.name {
+			return fmt.Errorf("%w: %s and %s", ErrMutuallyExclusive, name, option.name)
+		}
+
+		groups[option.group] = option.name
+	}
+
+	return nil
+}
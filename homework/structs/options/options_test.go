@@ -0,0 +1,276 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type client struct {
+	address string
+	timeout time.Duration
+	retries int
+	useTLS  bool
+	token   string
+}
+
+func WithAddress(address string) Option[client] {
+	return New("address", address, func(c *client) error {
+		if address == "" {
+			return errors.New("empty address")
+		}
+		c.address = address
+		return nil
+	})
+}
+
+func WithTimeout(timeout time.Duration) Option[client] {
+	return New("timeout", timeout, func(c *client) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout %v is not positive", timeout)
+		}
+		c.timeout = timeout
+		return nil
+	})
+}
+
+func WithRetries(retries int) Option[client] {
+	return From("retries", retries, func(c *client) {
+		c.retries = retries
+	})
+}
+
+func WithTLS() Option[client] {
+	return From("tls", true, func(c *client) {
+		c.useTLS = true
+	}).Exclusive("transport")
+}
+
+func WithInsecure() Option[client] {
+	return From("insecure", true, func(c *client) {
+		c.useTLS = false
+	}).Exclusive("transport")
+}
+
+func WithToken(token string) Option[client] {
+	// secrets are not shown in logs
+	return From("token", "***", func(c *client) {
+		c.token = token
+	})
+}
+
+func newClientConstructor() *Constructor[client] {
+	return NewConstructor[client]().
+		WithDefaults(func(c *client) {
+			c.address = "localhost:80"
+			c.timeout = time.Second
+			c.retries = 3
+		}).
+		WithValidator(func(c *client) error {
+			if c.token != "" && !c.useTLS {
+				return errors.New("token requires TLS")
+			}
+			return nil
+		})
+}
+
+func TestConstructorDefaults(t *testing.T) {
+	c, err := newClientConstructor().New(WithRetries(5))
+	require.NoError(t, err)
+	assert.Equal(t, client{address: "localhost:80", timeout: time.Second, retries: 5}, c)
+}
+
+func TestConstructorOptionError(t *testing.T) {
+	c, err := newClientConstructor().New(WithAddress("example.com"), WithTimeout(-time.Second))
+	assert.EqualError(t, err, "option timeout: timeout -1s is not positive")
+	assert.Equal(t, client{}, c)
+
+	_, err = newClientConstructor().New(WithAddress(""))
+	assert.EqualError(t, err, "option address: empty address")
+}
+
+func TestConstructorValidator(t *testing.T) {
+	_, err := newClientConstructor().New(WithToken("secret"))
+	assert.EqualError(t, err, "token requires TLS")
+
+	c, err := newClientConstructor().New(WithToken("secret"), WithTLS())
+	require.NoError(t, err)
+	assert.True(t, c.useTLS)
+	assert.Equal(t, "secret", c.token)
+}
+
+func TestMutuallyExclusiveOptions(t *testing.T) {
+	_, err := newClientConstructor().New(WithTLS(), WithRetries(1), WithInsecure())
+	assert.ErrorIs(t, err, ErrMutuallyExclusive)
+	assert.EqualError(t, err, "mutually exclusive options: tls and insecure")
+
+	// the same option twice is not a conflict
+	_, err = newClientConstructor().New(WithTLS(), WithTLS())
+	assert.NoError(t, err)
+}
+
+func TestApply(t *testing.T) {
+	c := client{retries: 1}
+	require.NoError(t, Apply(&c, WithRetries(2), WithTLS()))
+	assert.Equal(t, client{retries: 2, useTLS: true}, c)
+}
+
+func TestZeroOption(t *testing.T) {
+	c := client{retries: 1}
+	require.NoError(t, Apply(&c, Option[client]{}, WithRetries(2)))
+	assert.Equal(t, client{retries: 2}, c)
+	assert.Equal(t, []Setting{{Name: "retries", Value: 2}}, Settings(Option[client]{}, WithRetries(2)))
+}
+
+func TestIntrospection(t *testing.T) {
+	options := []Option[client]{
+		WithAddress("example.com"),
+		WithTimeout(5 * time.Second),
+		WithToken("secret"),
+		WithAddress("example.org"),
+	}
+
+	assert.Equal(t, "address", options[0].Name())
+	assert.Equal(t, "example.com", options[0].Value())
+	assert.Equal(t, "timeout=5s", options[1].String())
+
+	expected := []Setting{
+		{Name: "address", Value: "example.org"},
+		{Name: "timeout", Value: 5 * time.Second},
+		{Name: "token", Value: "***"},
+	}
+	assert.Equal(t, expected, Settings(options...))
+	assert.Equal(t, "address=example.org timeout=5s token=***", Describe(options...))
+}
+
+type serverConfig struct {
+	host      string
+	port      int
+	timeouts  map[string]time.Duration
+	handlers  []*client
+	LogLevel  string `option:"log_level"`
+	Internal  bool   `option:"-"`
+	callbacks chan<- error
+}
+
+func TestGenerate(t *testing.T) {
+	source, err := Generate(reflect.TypeOf(serverConfig{}))
+	require.NoError(t, err)
+
+	expected := `// Code generated by options.Generate; DO NOT EDIT.
+
+package options
+
+import (
+	"time"
+)
+
+func WithHost(value string) Option[serverConfig] {
+	return From("host", value, func(target *serverConfig) {
+		target.host = value
+	})
+}
+
+func WithPort(value int) Option[serverConfig] {
+	return From("port", value, func(target *serverConfig) {
+		target.port = value
+	})
+}
+
+func WithTimeouts(value map[string]time.Duration) Option[serverConfig] {
+	return From("timeouts", value, func(target *serverConfig) {
+		target.timeouts = value
+	})
+}
+
+func WithHandlers(value []*client) Option[serverConfig] {
+	return From("handlers", value, func(target *serverConfig) {
+		target.handlers = value
+	})
+}
+
+func WithLogLevel(value string) Option[serverConfig] {
+	return From("log_level", value, func(target *serverConfig) {
+		target.LogLevel = value
+	})
+}
+
+func WithCallbacks(value chan<- error) Option[serverConfig] {
+	return From("callbacks", value, func(target *serverConfig) {
+		target.callbacks = value
+	})
+}
+`
+	assert.Equal(t, expected, string(source))
+}
+
+func TestGenerateImportsOptions(t *testing.T) {
+	// structs of other packages get qualified options
+	source, err := Generate(reflect.TypeOf(time.Timer{}))
+	require.NoError(t, err)
+	assert.Contains(t, string(source), "package time")
+	assert.Contains(t, string(source), `"golang_course/homework/structs/options"`)
+	assert.Contains(t, string(source), "func WithC(value <-chan Time) options.Option[Timer]")
+}
+
+func TestGenerateNotStruct(t *testing.T) {
+	_, err := Generate(reflect.TypeOf(0))
+	assert.Error(t, err)
+
+	_, err = Generate(reflect.TypeOf(struct{ a int }{}))
+	assert.Error(t, err)
+}
+
+type pair[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+type hooksConfig struct {
+	onError func(error, ...time.Duration) (bool, error)
+	parse   func(string) time.Time
+	limits  struct {
+		Max time.Duration `json:"max"`
+		*client
+	}
+	payload any
+}
+
+func TestGenerateLiterals(t *testing.T) {
+	source, err := Generate(reflect.TypeOf(hooksConfig{}))
+	require.NoError(t, err)
+
+	assert.Contains(t, string(source), "\t\"time\"\n")
+	assert.Contains(t, string(source), "func WithOnError(value func(error, ...time.Duration) (bool, error)) Option[hooksConfig]")
+	assert.Contains(t, string(source), "func WithParse(value func(string) time.Time) Option[hooksConfig]")
+	assert.Contains(t, string(source), "func WithLimits(value struct {\n\tMax time.Duration `json:\"max\"`\n\t*client\n}) Option[hooksConfig]")
+	assert.Contains(t, string(source), "func WithPayload(value any) Option[hooksConfig]")
+}
+
+func TestGenerateUnsupported(t *testing.T) {
+	_, err := Generate(reflect.TypeOf(pair[string, int]{}))
+	assert.ErrorContains(t, err, "generic struct")
+
+	type genericField struct {
+		pairs []pair[string, int]
+	}
+	_, err = Generate(reflect.TypeOf(genericField{}))
+	assert.ErrorContains(t, err, "field pairs: generic type")
+
+	type interfaceField struct {
+		stringer interface{ String() string }
+	}
+	_, err = Generate(reflect.TypeOf(interfaceField{}))
+	assert.ErrorContains(t, err, "field stringer: interface literal")
+
+	type foreignStruct struct {
+		timer struct{ time.Timer }
+	}
+	_, err = Generate(reflect.TypeOf(foreignStruct{}))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,951 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -bench=. homework_test.go store_test.go spatial_test.go
+
+type Point struct {
+	X, Y, Z int
+}
+
+func PointOf(person *GamePerson) Point {
+	return Point{X: person.X(), Y: person.Y(), Z: person.Z()}
+}
+
+// SpatialIndex finds entities by position, coordinates of entities
+// must fit into int32 like coordinates of GamePerson, Insert and Move
+// panic otherwise. Centers and borders of queries can be any.
+type SpatialIndex[ID comparable] interface {
+	// Insert adds the entity or moves it if it is already in the index
+	Insert(id ID, point Point)
+	Move(id ID, point Point) bool
+	Remove(id ID) bool
+	// Radius returns entities at distance <= radius from the center
+	Radius(center Point, radius int) []ID
+	// Box returns entities inside of the box, borders are included
+	Box(box Box) []ID
+	// Nearest returns up to k entities sorted by distance to the center
+	Nearest(center Point, k int) []ID
+}
+
+// distanceSquared saturates instead of overflow, so distances
+// between far points are still greater than any radius squared
+func distanceSquared(a, b Point) uint64 {
+	return sumOfSquares(int64(a.X)-int64(b.X), int64(a.Y)-int64(b.Y), int64(a.Z)-int64(b.Z))
+}
+
+func sumOfSquares(dx, dy, dz int64) uint64 {
+	var sum uint64
+	for _, d := range [...]int64{dx, dy, dz} {
+		magnitude := uint64(d)
+		if d < 0 {
+			magnitude = uint64(-d)
+		}
+
+		if magnitude > math.MaxUint32 {
+			return math.MaxUint64
+		}
+
+		var carry uint64
+		sum, carry = bits.Add64(sum, magnitude*magnitude, 0)
+		if carry != 0 {
+			return math.MaxUint64
+		}
+	}
+
+	return sum
+}
+
+// radiusSquared saturates like distanceSquared, radii which do
+// not fit into uint32 cover any distance between int32 points
+func radiusSquared(radius int) uint64 {
+	if uint64(radius) > math.MaxUint32 {
+		return math.MaxUint64
+	}
+
+	return uint64(radius) * uint64(radius)
+}
+
+func mustFitInt32(point Point) {
+	for _, coordinate := range [...]int{point.X, point.Y, point.Z} {
+		if coordinate < math.MinInt32 || coordinate > math.MaxInt32 {
+			panic(fmt.Sprintf("spatial index: point %+v does not fit into int32", point))
+		}
+	}
+}
+
+func insideBox(box Box, point Point) bool {
+	return point.X >= box.MinX && point.X <= box.MaxX &&
+		point.Y >= box.MinY && point.Y <= box.MaxY &&
+		point.Z >= box.MinZ && point.Z <= box.MaxZ
+}
+
+// boxAround expects not negative radius, borders are clamped
+// to one step beyond int32, so they do not overflow and
+// select the same points of the index
+func boxAround(center Point, radius int) Box {
+	var box Box
+	box.MinX, box.MaxX = aroundCoordinate(center.X, radius)
+	box.MinY, box.MaxY = aroundCoordinate(center.Y, radius)
+	box.MinZ, box.MaxZ = aroundCoordinate(center.Z, radius)
+	return box
+}
+
+// clampCoordinate keeps one step beyond int32 on both sides,
+// so borders outside of int32 still select no points
+func clampCoordinate(coordinate int) int {
+	return min(max(coordinate, belowInt32), aboveInt32)
+}
+
+func aroundCoordinate(center, radius int) (int, int) {
+	low, high := belowInt32, aboveInt32
+	if center >= low+radius {
+		low = min(center-radius, high)
+	}
+	if center <= high-radius {
+		high = max(center+radius, belowInt32)
+	}
+
+	return low, high
+}
+
+type neighbour[ID comparable] struct {
+	id       ID
+	distance uint64
+}
+
+// neighbourHeap keeps the farthest of k nearest candidates on top
+type neighbourHeap[ID comparable] []neighbour[ID]
+
+func (h neighbourHeap[ID]) Len() int           { return len(h) }
+func (h neighbourHeap[ID]) Less(i, j int) bool { return h[i].distance > h[j].distance }
+func (h neighbourHeap[ID]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *neighbourHeap[ID]) Push(x any) {
+	*h = append(*h, x.(neighbour[ID]))
+}
+
+func (h *neighbourHeap[ID]) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+func (h *neighbourHeap[ID]) offer(candidate neighbour[ID], k int) {
+	if h.Len() < k {
+		heap.Push(h, candidate)
+	} else if candidate.distance < (*h)[0].distance {
+		(*h)[0] = candidate
+		heap.Fix(h, 0)
+	}
+}
+
+// full returns true when k candidates are found and all of them are closer than distance
+func (h *neighbourHeap[ID]) full(k int, distance uint64) bool {
+	return h.Len() == k && (*h)[0].distance <= distance
+}
+
+func (h *neighbourHeap[ID]) sorted() []ID {
+	ids := make([]ID, h.Len())
+	for i := len(ids) - 1; i >= 0; i-- {
+		ids[i] = heap.Pop(h).(neighbour[ID]).id
+	}
+
+	return ids
+}
+
+// GridIndex splits space into cubic cells, it is the best
+// choice when entities are spread evenly and queries are small
+type GridIndex[ID comparable] struct {
+	cellSize int
+	cells    map[gridCell][]ID
+	entries  map[ID]gridEntry
+}
+
+type gridCell struct {
+	x, y, z int
+}
+
+type gridEntry struct {
+	point Point
+	cell  gridCell
+	index int // position in the cell
+}
+
+func NewGridIndex[ID comparable](cellSize int) *GridIndex[ID] {
+	if cellSize <= 0 {
+		panic("grid cell size must be positive")
+	}
+
+	return &GridIndex[ID]{
+		cellSize: cellSize,
+		cells:    make(map[gridCell][]ID),
+		entries:  make(map[ID]gridEntry),
+	}
+}
+
+func (g *GridIndex[ID]) Insert(id ID, point Point) {
+	mustFitInt32(point)
+	if g.Move(id, point) {
+		return
+	}
+
+	g.add(id, point)
+}
+
+func (g *GridIndex[ID]) Move(id ID, point Point) bool {
+	mustFitInt32(point)
+	entry, found := g.entries[id]
+	if !found {
+		return false
+	}
+
+	if cell := g.cellOf(point); cell == entry.cell {
+		entry.point = point
+		g.entries[id] = entry
+		return true
+	}
+
+	g.Remove(id)
+	g.add(id, point)
+	return true
+}
+
+func (g *GridIndex[ID]) Remove(id ID) bool {
+	entry, found := g.entries[id]
+	if !found {
+		return false
+	}
+
+	ids := g.cells[entry.cell]
+	last := len(ids) - 1
+	if entry.index != last {
+		moved := ids[last]
+		ids[entry.index] = moved
+		movedEntry := g.entries[moved]
+		movedEntry.index = entry.index
+		g.entries[moved] = movedEntry
+	}
+
+	if last == 0 {
+		delete(g.cells, entry.cell)
+	} else {
+		g.cells[entry.cell] = ids[:last]
+	}
+
+	delete(g.entries, id)
+	return true
+}
+
+func (g *GridIndex[ID]) Radius(center Point, radius int) []ID {
+	if radius < 0 {
+		return nil
+	}
+
+	var result []ID
+	limit := radiusSquared(radius)
+	g.visitCells(boxAround(center, radius), func(ids []ID) {
+		for _, id := range ids {
+			if distanceSquared(center, g.entries[id].point) <= limit {
+				result = append(result, id)
+			}
+		}
+	})
+
+	return result
+}
+
+func (g *GridIndex[ID]) Box(box Box) []ID {
+	var result []ID
+	g.visitCells(box, func(ids []ID) {
+		for _, id := range ids {
+			if insideBox(box, g.entries[id].point) {
+				result = append(result, id)
+			}
+		}
+	})
+
+	return result
+}
+
+func (g *GridIndex[ID]) Nearest(center Point, k int) []ID {
+	if k <= 0 {
+		return nil
+	}
+
+	candidates := make(neighbourHeap[ID], 0, k)
+	centerCell := g.cellOf(center)
+	visited := 0
+
+	// rings of cells around the center: after ring r all
+	// entities closer than r*cellSize are already visited
+	for ring := 0; visited < len(g.entries); ring++ {
+		if ringCellsNumber(ring) > len(g.cells) {
+			return g.nearestByScan(center, k)
+		}
+
+		g.visitRing(centerCell, ring, func(ids []ID) {
+			for _, id := range ids {
+				candidates.offer(neighbour[ID]{id: id, distance: distanceSquared(center, g.entries[id].point)}, k)
+				visited++
+			}
+		})
+
+		covered := uint64(ring) * uint64(g.cellSize)
+		if candidates.full(k, covered*covered) {
+			break
+		}
+	}
+
+	return candidates.sorted()
+}
+
+func (g *GridIndex[ID]) nearestByScan(center Point, k int) []ID {
+	candidates := make(neighbourHeap[ID], 0, k)
+	for id, entry := range g.entries {
+		candidates.offer(neighbour[ID]{id: id, distance: distanceSquared(center, entry.point)}, k)
+	}
+
+	return candidates.sorted()
+}
+
+func (g *GridIndex[ID]) add(id ID, point Point) {
+	cell := g.cellOf(point)
+	g.entries[id] = gridEntry{point: point, cell: cell, index: len(g.cells[cell])}
+	g.cells[cell] = append(g.cells[cell], id)
+}
+
+func (g *GridIndex[ID]) cellOf(point Point) gridCell {
+	return gridCell{
+		x: floorDiv(point.X, g.cellSize),
+		y: floorDiv(point.Y, g.cellSize),
+		z: floorDiv(point.Z, g.cellSize),
+	}
+}
+
+// visitCells calls visit for non-empty cells intersecting the box,
+// big boxes are checked against occupied cells instead of the range
+func (g *GridIndex[ID]) visitCells(box Box, visit func(ids []ID)) {
+	// borders beyond int32 are clamped like in boxAround,
+	// so loops over cells do not overflow
+	low := g.cellOf(Point{X: clampCoordinate(box.MinX), Y: clampCoordinate(box.MinY), Z: clampCoordinate(box.MinZ)})
+	high := g.cellOf(Point{X: clampCoordinate(box.MaxX), Y: clampCoordinate(box.MaxY), Z: clampCoordinate(box.MaxZ)})
+	if low.x > high.x || low.y > high.y || low.z > high.z {
+		return
+	}
+
+	cellsNumber := (float64(high.x) - float64(low.x) + 1) *
+		(float64(high.y) - float64(low.y) + 1) *
+		(float64(high.z) - float64(low.z) + 1)
+	if cellsNumber > float64(len(g.cells)) {
+		for cell, ids := range g.cells {
+			if cell.x >= low.x && cell.x <= high.x &&
+				cell.y >= low.y && cell.y <= high.y &&
+				cell.z >= low.z && cell.z <= high.z {
+				visit(ids)
+			}
+		}
+		return
+	}
+
+	for x := low.x; x <= high.x; x++ {
+		for y := low.y; y <= high.y; y++ {
+			for z := low.z; z <= high.z; z++ {
+				if ids, found := g.cells[gridCell{x: x, y: y, z: z}]; found {
+					visit(ids)
+				}
+			}
+		}
+	}
+}
+
+func (g *GridIndex[ID]) visitRing(center gridCell, ring int, visit func(ids []ID)) {
+	for dx := -ring; dx <= ring; dx++ {
+		for dy := -ring; dy <= ring; dy++ {
+			step := 2 * ring // only two faces of the cube
+			if ring == 0 || abs(dx) == ring || abs(dy) == ring {
+				step = 1
+			}
+
+			for dz := -ring; dz <= ring; dz += step {
+				cell := gridCell{x: center.x + dx, y: center.y + dy, z: center.z + dz}
+				if ids, found := g.cells[cell]; found {
+					visit(ids)
+				}
+			}
+		}
+	}
+}
+
+func ringCellsNumber(ring int) int {
+	if ring == 0 {
+		return 1
+	}
+
+	outer, inner := 2*ring+1, 2*ring-1
+	return outer*outer*outer - inner*inner*inner
+}
+
+func floorDiv(a, b int) int {
+	quotient := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		quotient--
+	}
+
+	return quotient
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
+
+// leaves are split when they have more entities
+const octreeLeafCapacity = 8
+
+// Octree adapts to clustered entities: dense areas are split
+// into small cubes while empty areas cost nothing
+type Octree[ID comparable] struct {
+	root   *octreeNode[ID]
+	points map[ID]Point
+}
+
+type octreeNode[ID comparable] struct {
+	minX, minY, minZ int64
+	size             int64               // length of the cube edge
+	children         *[8]*octreeNode[ID] // nil for leaves, nil children are empty
+	ids              []ID                // entities of leaves
+	count            int                 // entities in the subtree
+}
+
+func NewOctree[ID comparable]() *Octree[ID] {
+	return &Octree[ID]{
+		root: &octreeNode[ID]{
+			minX: math.MinInt32,
+			minY: math.MinInt32,
+			minZ: math.MinInt32,
+			size: 1 << 32,
+		},
+		points: make(map[ID]Point),
+	}
+}
+
+func (o *Octree[ID]) Insert(id ID, point Point) {
+	mustFitInt32(point)
+	if o.Move(id, point) {
+		return
+	}
+
+	o.points[id] = point
+	o.insert(o.root, id, point)
+}
+
+func (o *Octree[ID]) Move(id ID, point Point) bool {
+	mustFitInt32(point)
+	if !o.Remove(id) {
+		return false
+	}
+
+	o.points[id] = point
+	o.insert(o.root, id, point)
+	return true
+}
+
+func (o *Octree[ID]) Remove(id ID) bool {
+	point, found := o.points[id]
+	if !found {
+		return false
+	}
+
+	o.remove(o.root, id, point)
+	delete(o.points, id)
+	return true
+}
+
+func (o *Octree[ID]) Radius(center Point, radius int) []ID {
+	if radius < 0 {
+		return nil
+	}
+
+	var result []ID
+	limit := radiusSquared(radius)
+	o.visit(o.root, func(node *octreeNode[ID]) bool {
+		return node.distanceSquared(center) <= limit
+	}, func(id ID) {
+		if distanceSquared(center, o.points[id]) <= limit {
+			result = append(result, id)
+		}
+	})
+
+	return result
+}
+
+func (o *Octree[ID]) Box(box Box) []ID {
+	var result []ID
+	o.visit(o.root, func(node *octreeNode[ID]) bool {
+		return node.intersects(box)
+	}, func(id ID) {
+		if insideBox(box, o.points[id]) {
+			result = append(result, id)
+		}
+	})
+
+	return result
+}
+
+func (o *Octree[ID]) Nearest(center Point, k int) []ID {
+	if k <= 0 {
+		return nil
+	}
+
+	// best first search, nodes are visited by distance to the center
+	candidates := make(neighbourHeap[ID], 0, k)
+	nodes := octreeQueue[ID]{{node: o.root, distance: o.root.distanceSquared(center)}}
+	for nodes.Len() != 0 {
+		item := heap.Pop(&nodes).(octreeQueueItem[ID])
+		if candidates.full(k, item.distance) {
+			break
+		}
+
+		if item.node.children == nil {
+			for _, id := range item.node.ids {
+				candidates.offer(neighbour[ID]{id: id, distance: distanceSquared(center, o.points[id])}, k)
+			}
+			continue
+		}
+
+		for _, child := range item.node.children {
+			if child != nil && child.count != 0 {
+				heap.Push(&nodes, octreeQueueItem[ID]{node: child, distance: child.distanceSquared(center)})
+			}
+		}
+	}
+
+	return candidates.sorted()
+}
+
+func (o *Octree[ID]) insert(node *octreeNode[ID], id ID, point Point) {
+	for node.children != nil {
+		node.count++
+		node = node.child(point, true)
+	}
+
+	node.count++
+	node.ids = append(node.ids, id)
+	if len(node.ids) > octreeLeafCapacity && node.size > 1 {
+		o.split(node)
+	}
+}
+
+func (o *Octree[ID]) split(node *octreeNode[ID]) {
+	node.children = new([8]*octreeNode[ID])
+	for _, id := range node.ids {
+		o.insert(node.child(o.points[id], true), id, o.points[id])
+	}
+
+	node.ids = nil
+}
+
+func (o *Octree[ID]) remove(node *octreeNode[ID], id ID, point Point) {
+	path := make([]*octreeNode[ID], 0, 33)
+	for node.children != nil {
+		node.count--
+		path = append(path, node)
+		node = node.child(point, false)
+	}
+
+	node.count--
+	index := slices.Index(node.ids, id)
+	last := len(node.ids) - 1
+	node.ids[index] = node.ids[last]
+	node.ids = node.ids[:last]
+
+	// merge the highest subtree which fits into one leaf
+	for _, parent := range path {
+		if parent.count <= octreeLeafCapacity {
+			ids := make([]ID, 0, octreeLeafCapacity)
+			parent.collect(&ids)
+			parent.ids = ids
+			parent.children = nil
+			return
+		}
+	}
+}
+
+// visit calls action for entities of leaves accepted by filter
+func (o *Octree[ID]) visit(node *octreeNode[ID], filter func(*octreeNode[ID]) bool, action func(ID)) {
+	if node == nil || node.count == 0 || !filter(node) {
+		return
+	}
+
+	if node.children == nil {
+		for _, id := range node.ids {
+			action(id)
+		}
+		return
+	}
+
+	for _, child := range node.children {
+		o.visit(child, filter, action)
+	}
+}
+
+func (n *octreeNode[ID]) child(point Point, create bool) *octreeNode[ID] {
+	half := n.size / 2
+	index := 0
+	minX, minY, minZ := n.minX, n.minY, n.minZ
+	if int64(point.X) >= n.minX+half {
+		index |= 1
+		minX += half
+	}
+	if int64(point.Y) >= n.minY+half {
+		index |= 2
+		minY += half
+	}
+	if int64(point.Z) >= n.minZ+half {
+		index |= 4
+		minZ += half
+	}
+
+	if n.children[index] == nil && create {
+		n.children[index] = &octreeNode[ID]{minX: minX, minY: minY, minZ: minZ, size: half}
+	}
+
+	return n.children[index]
+}
+
+func (n *octreeNode[ID]) collect(ids *[]ID) {
+	if n == nil {
+		return
+	}
+
+	if n.children == nil {
+		*ids = append(*ids, n.ids...)
+		return
+	}
+
+	for _, child := range n.children {
+		child.collect(ids)
+	}
+}
+
+// distanceSquared returns squared distance from the point to the closest point of the cube
+func (n *octreeNode[ID]) distanceSquared(point Point) uint64 {
+	axisDistance := func(coordinate, low int64) int64 {
+		high := low + n.size - 1
+		if coordinate < low {
+			return low - coordinate
+		}
+		if coordinate > high {
+			return coordinate - high
+		}
+		return 0
+	}
+
+	return sumOfSquares(
+		axisDistance(int64(point.X), n.minX),
+		axisDistance(int64(point.Y), n.minY),
+		axisDistance(int64(point.Z), n.minZ),
+	)
+}
+
+func (n *octreeNode[ID]) intersects(box Box) bool {
+	overlaps := func(low, high int, nodeLow int64) bool {
+		return int64(high) >= nodeLow && int64(low) <= nodeLow+n.size-1
+	}
+
+	return overlaps(box.MinX, box.MaxX, n.minX) &&
+		overlaps(box.MinY, box.MaxY, n.minY) &&
+		overlaps(box.MinZ, box.MaxZ, n.minZ)
+}
+
+type octreeQueueItem[ID comparable] struct {
+	node     *octreeNode[ID]
+	distance uint64
+}
+
+// octreeQueue keeps the closest node on top
+type octreeQueue[ID comparable] []octreeQueueItem[ID]
+
+func (q octreeQueue[ID]) Len() int           { return len(q) }
+func (q octreeQueue[ID]) Less(i, j int) bool { return q[i].distance < q[j].distance }
+func (q octreeQueue[ID]) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *octreeQueue[ID]) Push(x any) {
+	*q = append(*q, x.(octreeQueueItem[ID]))
+}
+
+func (q *octreeQueue[ID]) Pop() any {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
+
+var spatialIndexes = map[string]func() SpatialIndex[int]{
+	"grid":   func() SpatialIndex[int] { return NewGridIndex[int](10) },
+	"octree": func() SpatialIndex[int] { return NewOctree[int]() },
+}
+
+func TestSpatialIndex(t *testing.T) {
+	for name, newIndex := range spatialIndexes {
+		t.Run(name, func(t *testing.T) {
+			index := newIndex()
+			index.Insert(1, Point{X: 0, Y: 0, Z: 0})
+			index.Insert(2, Point{X: 3, Y: 4, Z: 0})
+			index.Insert(3, Point{X: -20, Y: -20, Z: -20})
+			index.Insert(4, Point{X: math.MaxInt32, Y: math.MinInt32, Z: 0})
+
+			assert.ElementsMatch(t, []int{1, 2}, index.Radius(Point{}, 5))
+			assert.ElementsMatch(t, []int{1}, index.Radius(Point{}, 4))
+			assert.Empty(t, index.Radius(Point{}, -1))
+			assert.ElementsMatch(t, []int{1, 3}, index.Box(Box{MinX: -20, MinY: -20, MinZ: -20, MaxX: 0, MaxY: 0, MaxZ: 0}))
+			assert.Equal(t, []int{2, 1, 3}, index.Nearest(Point{X: 3, Y: 3, Z: 0}, 3))
+			farthest := index.Nearest(Point{X: math.MaxInt32, Y: math.MinInt32, Z: 0}, 10)
+			assert.Len(t, farthest, 4)
+			assert.Equal(t, 4, farthest[0])
+			assert.Empty(t, index.Nearest(Point{}, 0))
+
+			assert.True(t, index.Move(3, Point{X: 1, Y: 1, Z: 1}))
+			assert.False(t, index.Move(5, Point{}))
+			assert.ElementsMatch(t, []int{1, 2, 3}, index.Radius(Point{}, 5))
+
+			index.Insert(1, Point{X: 100, Y: 100, Z: 100})
+			assert.ElementsMatch(t, []int{2, 3}, index.Radius(Point{}, 5))
+
+			assert.True(t, index.Remove(2))
+			assert.False(t, index.Remove(2))
+			assert.ElementsMatch(t, []int{3}, index.Radius(Point{}, 5))
+			assert.Equal(t, []int{1}, index.Nearest(Point{X: 90, Y: 90, Z: 90}, 1))
+		})
+	}
+}
+
+func TestSpatialIndexAgainstScan(t *testing.T) {
+	for name, newIndex := range spatialIndexes {
+		t.Run(name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(42))
+			randomPoint := func() Point {
+				return Point{X: r.Intn(200) - 100, Y: r.Intn(200) - 100, Z: r.Intn(20)}
+			}
+
+			index := newIndex()
+			points := make(map[int]Point)
+			for i := 0; i < 5000; i++ {
+				id := r.Intn(1000)
+				switch r.Intn(4) {
+				case 0:
+					assert.Equal(t, hasKey(points, id), index.Remove(id))
+					delete(points, id)
+				default:
+					points[id] = randomPoint()
+					index.Insert(id, points[id])
+				}
+
+				if i%100 != 0 {
+					continue
+				}
+
+				center, radius := randomPoint(), r.Intn(50)
+				var expected []int
+				for id, point := range points {
+					if distanceSquared(center, point) <= uint64(radius*radius) {
+						expected = append(expected, id)
+					}
+				}
+				assert.ElementsMatch(t, expected, index.Radius(center, radius))
+
+				box := boxAround(center, radius)
+				expected = expected[:0]
+				for id, point := range points {
+					if insideBox(box, point) {
+						expected = append(expected, id)
+					}
+				}
+				assert.ElementsMatch(t, expected, index.Box(box))
+
+				k := r.Intn(20) + 1
+				distances := make([]uint64, 0, len(points))
+				for _, point := range points {
+					distances = append(distances, distanceSquared(center, point))
+				}
+				slices.Sort(distances)
+
+				nearest := index.Nearest(center, k)
+				assert.Len(t, nearest, min(k, len(points)))
+				for i, id := range nearest {
+					assert.Equal(t, distances[i], distanceSquared(center, points[id]))
+				}
+			}
+		})
+	}
+}
+
+func TestSpatialIndexEdges(t *testing.T) {
+	if aboveInt32 == math.MaxInt32 {
+		t.Skip("int can not go beyond int32 on 32-bit platforms")
+	}
+
+	assert.Panics(t, func() { NewGridIndex[int](0) })
+	assert.Panics(t, func() { NewGridIndex[int](-1) })
+
+	corners := []Point{
+		{X: math.MinInt32, Y: math.MinInt32, Z: math.MinInt32},
+		{X: math.MaxInt32, Y: math.MaxInt32, Z: math.MaxInt32},
+		{X: math.MaxInt32, Y: math.MinInt32, Z: 0},
+	}
+
+	for name, newIndex := range spatialIndexes {
+		t.Run(name, func(t *testing.T) {
+			index := newIndex()
+			for id, point := range corners {
+				index.Insert(id, point)
+			}
+
+			// both indexes reject coordinates which do not fit into int32
+			assert.Panics(t, func() { index.Insert(3, Point{X: aboveInt32}) })
+			assert.Panics(t, func() { index.Move(0, Point{Z: belowInt32}) })
+			assert.Len(t, index.Radius(Point{}, math.MaxInt), len(corners))
+			assert.Equal(t, []int{0}, index.Nearest(Point{X: math.MinInt32}, 1))
+
+			// huge radii and far centers do not overflow
+			assert.Len(t, index.Radius(corners[0], math.MaxInt), len(corners))
+			assert.Len(t, index.Radius(Point{X: aboveInt32}, math.MaxInt32), 0)
+			assert.Empty(t, index.Radius(Point{X: math.MaxInt, Y: math.MaxInt, Z: math.MaxInt}, 1))
+			assert.Empty(t, index.Radius(Point{X: math.MinInt}, math.MaxInt32))
+			assert.Equal(t, []int{2}, index.Radius(Point{X: aboveInt32, Y: math.MinInt32}, 1))
+			assert.Empty(t, index.Box(boxAround(Point{X: aboveInt32, Y: math.MaxInt32, Z: math.MaxInt32}, 0)))
+			assert.Equal(t, []int{1}, index.Box(boxAround(Point{X: math.MaxInt, Y: math.MaxInt, Z: math.MaxInt}, math.MaxInt)))
+			assert.Len(t, index.Box(Box{MinX: math.MinInt, MinY: math.MinInt, MinZ: math.MinInt, MaxX: math.MaxInt, MaxY: math.MaxInt, MaxZ: math.MaxInt}), len(corners))
+			assert.Len(t, index.Nearest(Point{X: math.MaxInt}, 10), len(corners))
+		})
+	}
+
+	// borders near the limits of int do not overflow loops over cells
+	grid := NewGridIndex[int](1)
+	grid.Insert(0, corners[0])
+	grid.Insert(1, Point{})
+	assert.Empty(t, grid.Box(Box{MinX: math.MaxInt - 1, MaxX: math.MaxInt}))
+	assert.Empty(t, grid.Box(Box{MinX: math.MinInt, MaxX: math.MinInt + 1}))
+	full := Box{MinX: math.MinInt, MinY: math.MinInt, MinZ: math.MinInt, MaxX: math.MaxInt, MaxY: math.MaxInt, MaxZ: math.MaxInt}
+	assert.ElementsMatch(t, []int{0, 1}, grid.Box(full))
+
+	assert.Equal(t, uint64(math.MaxUint64), distanceSquared(Point{X: math.MinInt}, Point{}))
+	assert.Equal(t, uint64(math.MaxUint64), radiusSquared(math.MaxInt))
+}
+
+func hasKey(points map[int]Point, id int) bool {
+	_, found := points[id]
+	return found
+}
+
+func TestDistanceSquaredSaturation(t *testing.T) {
+	a := Point{X: math.MinInt32, Y: math.MinInt32, Z: math.MinInt32}
+	b := Point{X: math.MaxInt32, Y: math.MaxInt32, Z: math.MaxInt32}
+	assert.Equal(t, uint64(math.MaxUint64), distanceSquared(a, b))
+	assert.Equal(t, uint64(50), distanceSquared(Point{X: 3, Y: 4, Z: 5}, Point{}))
+	assert.Equal(t, -1, floorDiv(-1, 10))
+	assert.Equal(t, -2, floorDiv(-11, 10))
+	assert.Equal(t, 1, floorDiv(10, 10))
+}
+
+func newBenchmarkPoints() []Point {
+	r := rand.New(rand.NewSource(42))
+	points := make([]Point, 100_000)
+	for i := range points {
+		points[i] = Point{X: r.Intn(10_000), Y: r.Intn(10_000), Z: r.Intn(100)}
+	}
+
+	return points
+}
+
+var SinkIDs []int
+
+func BenchmarkSpatialRadius(b *testing.B) {
+	points := newBenchmarkPoints()
+	for name, newIndex := range map[string]func() SpatialIndex[int]{
+		"grid":   func() SpatialIndex[int] { return NewGridIndex[int](50) },
+		"octree": func() SpatialIndex[int] { return NewOctree[int]() },
+	} {
+		b.Run(name, func(b *testing.B) {
+			index := newIndex()
+			for id, point := range points {
+				index.Insert(id, point)
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				SinkIDs = index.Radius(points[i%len(points)], 100)
+			}
+		})
+	}
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			center := points[i%len(points)]
+			SinkIDs = SinkIDs[:0]
+			for id, point := range points {
+				if distanceSquared(center, point) <= 100*100 {
+					SinkIDs = append(SinkIDs, id)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkSpatialNearest(b *testing.B) {
+	points := newBenchmarkPoints()
+	for name, index := range map[string]SpatialIndex[int]{
+		"grid":   NewGridIndex[int](50),
+		"octree": NewOctree[int](),
+	} {
+		b.Run(name, func(b *testing.B) {
+			for id, point := range points {
+				index.Insert(id, point)
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				SinkIDs = index.Nearest(points[i%len(points)], 10)
+			}
+		})
+	}
+}
+
+func BenchmarkSpatialMove(b *testing.B) {
+	points := newBenchmarkPoints()
+	for name, index := range map[string]SpatialIndex[int]{
+		"grid":   NewGridIndex[int](50),
+		"octree": NewOctree[int](),
+	} {
+		b.Run(name, func(b *testing.B) {
+			for id, point := range points {
+				index.Insert(id, point)
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				id := i % len(points)
+				point := points[id]
+				point.X += i % 3
+				index.Move(id, point)
+			}
+		})
+	}
+}
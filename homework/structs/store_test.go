@@ -0,0 +1,495 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -bench=. homework_test.go store_test.go
+
+// GamePersonHandle stays valid while the person is in the store,
+// after deletion the generation of the slot changes and old
+// handles do not match new persons in the same slot
+type GamePersonHandle struct {
+	slot       uint32
+	generation uint32
+}
+
+type storeSlot struct {
+	index      int32 // position in columns, -1 for free slots
+	generation uint32
+}
+
+// GamePersonStore keeps persons as struct of arrays, so loops
+// over one or two fields do not load other fields into cache.
+// Deletion moves the last person into the hole (swap-remove),
+// handles are resolved to columns positions through slots.
+type GamePersonStore struct {
+	x, y, z []int32
+	gold    []uint32
+	stats   []uint32
+	flags   []uint16
+	names   [][maxNameLength]byte
+	owners  []uint32 // position in columns -> slot
+
+	slots     []storeSlot
+	freeSlots []uint32
+	selection []int32 // reused by queries
+}
+
+func NewGamePersonStore(capacity int) *GamePersonStore {
+	return &GamePersonStore{
+		x:      make([]int32, 0, capacity),
+		y:      make([]int32, 0, capacity),
+		z:      make([]int32, 0, capacity),
+		gold:   make([]uint32, 0, capacity),
+		stats:  make([]uint32, 0, capacity),
+		flags:  make([]uint16, 0, capacity),
+		names:  make([][maxNameLength]byte, 0, capacity),
+		owners: make([]uint32, 0, capacity),
+		slots:  make([]storeSlot, 0, capacity),
+	}
+}
+
+func (s *GamePersonStore) Len() int {
+	return len(s.owners)
+}
+
+func (s *GamePersonStore) Insert(person GamePerson) GamePersonHandle {
+	var slot uint32
+	if length := len(s.freeSlots); length != 0 {
+		slot = s.freeSlots[length-1]
+		s.freeSlots = s.freeSlots[:length-1]
+	} else {
+		slot = uint32(len(s.slots))
+		s.slots = append(s.slots, storeSlot{})
+	}
+
+	s.slots[slot].index = int32(len(s.owners))
+	s.owners = append(s.owners, slot)
+	s.x = append(s.x, person.x)
+	s.y = append(s.y, person.y)
+	s.z = append(s.z, person.z)
+	s.gold = append(s.gold, person.gold)
+	s.stats = append(s.stats, person.stats)
+	s.flags = append(s.flags, person.flags)
+	s.names = append(s.names, person.name)
+
+	return GamePersonHandle{slot: slot, generation: s.slots[slot].generation}
+}
+
+func (s *GamePersonStore) Delete(handle GamePersonHandle) bool {
+	index, found := s.index(handle)
+	if !found {
+		return false
+	}
+
+	last := len(s.owners) - 1
+	if index != last {
+		s.x[index] = s.x[last]
+		s.y[index] = s.y[last]
+		s.z[index] = s.z[last]
+		s.gold[index] = s.gold[last]
+		s.stats[index] = s.stats[last]
+		s.flags[index] = s.flags[last]
+		s.names[index] = s.names[last]
+		s.owners[index] = s.owners[last]
+		s.slots[s.owners[index]].index = int32(index)
+	}
+
+	s.x = s.x[:last]
+	s.y = s.y[:last]
+	s.z = s.z[:last]
+	s.gold = s.gold[:last]
+	s.stats = s.stats[:last]
+	s.flags = s.flags[:last]
+	s.names = s.names[:last]
+	s.owners = s.owners[:last]
+
+	s.slots[handle.slot] = storeSlot{index: -1, generation: handle.generation + 1}
+	s.freeSlots = append(s.freeSlots, handle.slot)
+	return true
+}
+
+func (s *GamePersonStore) Contains(handle GamePersonHandle) bool {
+	_, found := s.index(handle)
+	return found
+}
+
+func (s *GamePersonStore) Get(handle GamePersonHandle) (GamePerson, bool) {
+	index, found := s.index(handle)
+	if !found {
+		return GamePerson{}, false
+	}
+
+	return GamePerson{
+		x:     s.x[index],
+		y:     s.y[index],
+		z:     s.z[index],
+		gold:  s.gold[index],
+		stats: s.stats[index],
+		flags: s.flags[index],
+		name:  s.names[index],
+	}, true
+}
+
+func (s *GamePersonStore) Set(handle GamePersonHandle, person GamePerson) bool {
+	index, found := s.index(handle)
+	if !found {
+		return false
+	}
+
+	s.x[index] = person.x
+	s.y[index] = person.y
+	s.z[index] = person.z
+	s.gold[index] = person.gold
+	s.stats[index] = person.stats
+	s.flags[index] = person.flags
+	s.names[index] = person.name
+	return true
+}
+
+// Columns are valid until the next Insert or Delete, they can be
+// changed in place by simulation loops. Position i in all columns
+// belongs to the same person, Handle returns its handle.
+
+func (s *GamePersonStore) XColumn() []int32 {
+	return s.x
+}
+
+func (s *GamePersonStore) YColumn() []int32 {
+	return s.y
+}
+
+func (s *GamePersonStore) ZColumn() []int32 {
+	return s.z
+}
+
+func (s *GamePersonStore) GoldColumn() []uint32 {
+	return s.gold
+}
+
+func (s *GamePersonStore) Handle(index int) GamePersonHandle {
+	slot := s.owners[index]
+	return GamePersonHandle{slot: slot, generation: s.slots[slot].generation}
+}
+
+func (s *GamePersonStore) index(handle GamePersonHandle) (int, bool) {
+	if int(handle.slot) >= len(s.slots) {
+		return 0, false
+	}
+
+	slot := s.slots[handle.slot]
+	if slot.index < 0 || slot.generation != handle.generation {
+		return 0, false
+	}
+
+	return int(slot.index), true
+}
+
+// StoreFilter narrows positions of selected persons in place,
+// every filter makes one pass over one or several columns
+type StoreFilter func(store *GamePersonStore, selection []int32) []int32
+
+// Query returns handles of persons matching all filters
+func (s *GamePersonStore) Query(filters ...StoreFilter) []GamePersonHandle {
+	selection := s.selection[:0]
+	for index := range s.owners {
+		selection = append(selection, int32(index))
+	}
+
+	for _, filter := range filters {
+		selection = filter(s, selection)
+	}
+
+	s.selection = selection
+	handles := make([]GamePersonHandle, 0, len(selection))
+	for _, index := range selection {
+		handles = append(handles, s.Handle(int(index)))
+	}
+
+	return handles
+}
+
+type Box struct {
+	MinX, MinY, MinZ int
+	MaxX, MaxY, MaxZ int
+}
+
+// WithinBox selects persons with coordinates inside of the box, borders are included
+func WithinBox(box Box) StoreFilter {
+	return func(store *GamePersonStore, selection []int32) []int32 {
+		selection = filterRange(store.x, selection, box.MinX, box.MaxX)
+		selection = filterRange(store.y, selection, box.MinY, box.MaxY)
+		return filterRange(store.z, selection, box.MinZ, box.MaxZ)
+	}
+}
+
+// OfType selects persons of the type, unknown types select nobody
+func OfType(personType int) StoreFilter {
+	return func(store *GamePersonStore, selection []int32) []int32 {
+		if personType < BuilderGamePersonType || personType > WarriorGamePersonType {
+			return selection[:0]
+		}
+
+		mask := uint16(typeField.set(0, 1<<typeField.width-1))
+		value := uint16(typeField.set(0, personType))
+
+		filtered := selection[:0]
+		for _, index := range selection {
+			if store.flags[index]&mask == value {
+				filtered = append(filtered, index)
+			}
+		}
+
+		return filtered
+	}
+}
+
+func GoldGreaterThan(gold int) StoreFilter {
+	return func(store *GamePersonStore, selection []int32) []int32 {
+		if gold < 0 {
+			return selection
+		}
+		if int64(gold) >= math.MaxUint32 {
+			return selection[:0]
+		}
+
+		filtered := selection[:0]
+		for _, index := range selection {
+			if store.gold[index] > uint32(gold) {
+				filtered = append(filtered, index)
+			}
+		}
+
+		return filtered
+	}
+}
+
+// filterRange keeps positions with low <= column[position] <= high,
+// loops are written by hand in filters to avoid calls per element
+func filterRange(column []int32, selection []int32, low, high int) []int32 {
+	low32, high32 := clampInt32(low), clampInt32(high)
+
+	filtered := selection[:0]
+	for _, index := range selection {
+		if value := column[index]; value >= low32 && value <= high32 {
+			filtered = append(filtered, index)
+		}
+	}
+
+	return filtered
+}
+
+func clampInt32(value int) int32 {
+	return int32(min(max(value, math.MinInt32), math.MaxInt32))
+}
+
+func TestGamePersonStore(t *testing.T) {
+	store := NewGamePersonStore(0)
+	first := store.Insert(MustNewGamePerson(WithName("first"), WithGold(1)))
+	second := store.Insert(MustNewGamePerson(WithName("second"), WithGold(2)))
+	third := store.Insert(MustNewGamePerson(WithName("third"), WithGold(3)))
+	assert.Equal(t, 3, store.Len())
+
+	assert.True(t, store.Delete(first))
+	assert.False(t, store.Delete(first))
+	assert.False(t, store.Contains(first))
+	assert.Equal(t, 2, store.Len())
+
+	// third was moved into the hole, handles still work
+	person, found := store.Get(third)
+	require.True(t, found)
+	assert.Equal(t, "third", person.Name())
+	assert.Equal(t, 3, person.Gold())
+
+	person, found = store.Get(second)
+	require.True(t, found)
+	assert.Equal(t, "second", person.Name())
+
+	// slot is reused with new generation
+	fourth := store.Insert(MustNewGamePerson(WithName("fourth")))
+	assert.Equal(t, first.slot, fourth.slot)
+	_, found = store.Get(first)
+	assert.False(t, found)
+	assert.False(t, store.Set(first, GamePerson{}))
+
+	assert.True(t, store.Set(fourth, MustNewGamePerson(WithName("updated"))))
+	person, _ = store.Get(fourth)
+	assert.Equal(t, "updated", person.Name())
+
+	_, found = store.Get(GamePersonHandle{slot: 100})
+	assert.False(t, found)
+}
+
+func TestGamePersonStoreRoundTrip(t *testing.T) {
+	store := NewGamePersonStore(1)
+	person := MustNewGamePerson(
+		WithName("warrior"),
+		WithCoordinates(math.MinInt32, 0, math.MaxInt32),
+		WithGold(math.MaxUint32),
+		WithMana(10),
+		WithLevel(3),
+		WithFamily(),
+		WithType(WarriorGamePersonType),
+	)
+
+	stored, found := store.Get(store.Insert(person))
+	require.True(t, found)
+	assert.Equal(t, person, stored)
+}
+
+func TestGamePersonStoreColumns(t *testing.T) {
+	store := NewGamePersonStore(2)
+	first := store.Insert(MustNewGamePerson(WithCoordinates(1, 1, 1)))
+	store.Insert(MustNewGamePerson(WithCoordinates(2, 2, 2)))
+
+	xs := store.XColumn()
+	for i := range xs {
+		xs[i] += 10
+	}
+
+	person, _ := store.Get(first)
+	assert.Equal(t, 11, person.X())
+	assert.Equal(t, []int32{1, 2}, store.YColumn())
+	assert.Equal(t, first, store.Handle(0))
+}
+
+func TestGamePersonStoreQuery(t *testing.T) {
+	store := NewGamePersonStore(0)
+	expected := store.Insert(MustNewGamePerson(WithType(WarriorGamePersonType), WithCoordinates(5, 5, 5), WithGold(200)))
+	store.Insert(MustNewGamePerson(WithType(WarriorGamePersonType), WithCoordinates(5, 5, 5), WithGold(50)))
+	store.Insert(MustNewGamePerson(WithType(WarriorGamePersonType), WithCoordinates(50, 5, 5), WithGold(200)))
+	store.Insert(MustNewGamePerson(WithType(BuilderGamePersonType), WithCoordinates(5, 5, 5), WithGold(200)))
+	deleted := store.Insert(MustNewGamePerson(WithType(WarriorGamePersonType), WithCoordinates(0, 0, 10), WithGold(300)))
+	border := store.Insert(MustNewGamePerson(WithType(WarriorGamePersonType), WithCoordinates(0, 10, 10), WithGold(101)))
+	store.Delete(deleted)
+
+	box := Box{MinX: 0, MinY: 0, MinZ: 0, MaxX: 10, MaxY: 10, MaxZ: 10}
+	handles := store.Query(OfType(WarriorGamePersonType), WithinBox(box), GoldGreaterThan(100))
+	assert.ElementsMatch(t, []GamePersonHandle{expected, border}, handles)
+
+	assert.Len(t, store.Query(), 5)
+	assert.Len(t, store.Query(OfType(BlacksmithGamePersonType)), 0)
+
+	// the type field is two bits wide, so 4 would be stored as a builder
+	assert.Len(t, store.Query(OfType(BuilderGamePersonType)), 1)
+	assert.Empty(t, store.Query(OfType(WarriorGamePersonType+2)))
+	assert.Empty(t, store.Query(OfType(-1)))
+}
+
+func TestGamePersonStoreRandomOperations(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	store := NewGamePersonStore(0)
+	persons := make(map[GamePersonHandle]GamePerson)
+
+	for i := 0; i < 10_000; i++ {
+		if r.Intn(3) == 0 && len(persons) != 0 {
+			for handle := range persons {
+				assert.True(t, store.Delete(handle))
+				delete(persons, handle)
+				break
+			}
+			continue
+		}
+
+		person := MustNewGamePerson(WithGold(uint32(r.Intn(1000))), WithCoordinates(r.Intn(100), r.Intn(100), r.Intn(100)))
+		persons[store.Insert(person)] = person
+	}
+
+	assert.Equal(t, len(persons), store.Len())
+	for handle, person := range persons {
+		stored, found := store.Get(handle)
+		assert.True(t, found)
+		assert.Equal(t, person, stored)
+	}
+}
+
+const benchmarkPersonsNumber = 1_000_000
+
+var queryBox = Box{MinX: 0, MinY: 0, MinZ: 0, MaxX: 500, MaxY: 500, MaxZ: 500}
+
+func newBenchmarkPerson(r *rand.Rand) GamePerson {
+	return MustNewGamePerson(
+		WithName("person"),
+		WithCoordinates(r.Intn(1000), r.Intn(1000), r.Intn(1000)),
+		WithGold(uint32(r.Intn(1000))),
+		WithType(r.Intn(WarriorGamePersonType+1)),
+	)
+}
+
+var SinkHandles []GamePersonHandle
+var SinkCount int
+
+func BenchmarkStoreQuery(b *testing.B) {
+	r := rand.New(rand.NewSource(42))
+	store := NewGamePersonStore(benchmarkPersonsNumber)
+	for i := 0; i < benchmarkPersonsNumber; i++ {
+		store.Insert(newBenchmarkPerson(r))
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		SinkHandles = store.Query(GoldGreaterThan(900), WithinBox(queryBox), OfType(WarriorGamePersonType))
+	}
+}
+
+func BenchmarkSliceOfStructsQuery(b *testing.B) {
+	r := rand.New(rand.NewSource(42))
+	persons := make([]GamePerson, 0, benchmarkPersonsNumber)
+	for i := 0; i < benchmarkPersonsNumber; i++ {
+		persons = append(persons, newBenchmarkPerson(r))
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		SinkCount = 0
+		for j := range persons {
+			person := &persons[j]
+			if person.Gold() > 900 && person.Type() == WarriorGamePersonType &&
+				person.X() >= queryBox.MinX && person.X() <= queryBox.MaxX &&
+				person.Y() >= queryBox.MinY && person.Y() <= queryBox.MaxY &&
+				person.Z() >= queryBox.MinZ && person.Z() <= queryBox.MaxZ {
+				SinkCount++
+			}
+		}
+	}
+}
+
+func BenchmarkStoreMoveAll(b *testing.B) {
+	r := rand.New(rand.NewSource(42))
+	store := NewGamePersonStore(benchmarkPersonsNumber)
+	for i := 0; i < benchmarkPersonsNumber; i++ {
+		store.Insert(newBenchmarkPerson(r))
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		xs, ys := store.XColumn(), store.YColumn()
+		ys = ys[:len(xs)]
+		for j := range xs {
+			xs[j]++
+			ys[j]--
+		}
+	}
+}
+
+func BenchmarkSliceOfStructsMoveAll(b *testing.B) {
+	r := rand.New(rand.NewSource(42))
+	persons := make([]GamePerson, 0, benchmarkPersonsNumber)
+	for i := 0; i < benchmarkPersonsNumber; i++ {
+		persons = append(persons, newBenchmarkPerson(r))
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := range persons {
+			persons[j].x++
+			persons[j].y--
+		}
+	}
+}
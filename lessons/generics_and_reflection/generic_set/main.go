@@ -1,15 +1,28 @@
 package main
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+)
 
 type Set[K comparable] struct {
 	data map[K]struct{}
 }
 
-func NewSet[K comparable]() Set[K] {
-	return Set[K]{
-		data: make(map[K]struct{}),
+func NewSet[K comparable](keys ...K) Set[K] {
+	s := Set[K]{
+		data: make(map[K]struct{}, len(keys)),
 	}
+
+	for _, key := range keys {
+		s.Insert(key)
+	}
+
+	return s
 }
 
 func (s *Set[K]) Insert(key K) {
@@ -25,6 +38,115 @@ func (s *Set[K]) Contains(key K) bool {
 	return found
 }
 
+func (s *Set[K]) Len() int {
+	return len(s.data)
+}
+
+// All iterates keys in random order like range over a map
+func (s *Set[K]) All() iter.Seq[K] {
+	return maps.Keys(s.data)
+}
+
+// Union, Intersection, Difference and SymmetricDifference
+// return new sets and do not change their arguments
+func (s *Set[K]) Union(other Set[K]) Set[K] {
+	result := Set[K]{data: maps.Clone(s.data)}
+	if result.data == nil {
+		result.data = make(map[K]struct{}, other.Len())
+	}
+
+	maps.Copy(result.data, other.data)
+	return result
+}
+
+func (s *Set[K]) Intersection(other Set[K]) Set[K] {
+	// iterate over the smaller set
+	smaller, bigger := s, &other
+	if smaller.Len() > bigger.Len() {
+		smaller, bigger = bigger, smaller
+	}
+
+	result := NewSet[K]()
+	for key := range smaller.data {
+		if bigger.Contains(key) {
+			result.Insert(key)
+		}
+	}
+
+	return result
+}
+
+func (s *Set[K]) Difference(other Set[K]) Set[K] {
+	result := NewSet[K]()
+	for key := range s.data {
+		if !other.Contains(key) {
+			result.Insert(key)
+		}
+	}
+
+	return result
+}
+
+// SymmetricDifference returns keys which are in exactly one of the sets
+func (s *Set[K]) SymmetricDifference(other Set[K]) Set[K] {
+	result := s.Difference(other)
+	for key := range other.data {
+		if !s.Contains(key) {
+			result.Insert(key)
+		}
+	}
+
+	return result
+}
+
+// IsSubset reports whether every key of s is in other
+func (s *Set[K]) IsSubset(other Set[K]) bool {
+	if s.Len() > other.Len() {
+		return false
+	}
+
+	for key := range s.data {
+		if !other.Contains(key) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Set[K]) Equal(other Set[K]) bool {
+	return s.Len() == other.Len() && s.IsSubset(other)
+}
+
+// MarshalJSON writes keys as an array sorted by their JSON, so equal
+// sets always give the same output. It has a value receiver to work
+// for sets which are not addressable, like values of maps.
+func (s Set[K]) MarshalJSON() ([]byte, error) {
+	encoded := make([][]byte, 0, len(s.data))
+	for key := range s.data {
+		data, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded = append(encoded, data)
+	}
+
+	slices.SortFunc(encoded, bytes.Compare)
+	return slices.Concat([]byte("["), bytes.Join(encoded, []byte(",")), []byte("]")), nil
+}
+
+// UnmarshalJSON replaces keys of the set, duplicates are allowed
+func (s *Set[K]) UnmarshalJSON(data []byte) error {
+	var keys []K
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+
+	*s = NewSet(keys...)
+	return nil
+}
+
 // skipping like with function
 func (s *Set[_]) Print() {
 	fmt.Println(s.data)
@@ -34,4 +156,17 @@ func main() {
 	set := NewSet[string]()
 	set.Insert("key")
 	set.Erase("key")
+
+	backend := NewSet("go", "sql", "docker")
+	frontend := NewSet("js", "css", "docker")
+
+	common := backend.Intersection(frontend)
+	all := backend.Union(frontend)
+	onlyBackend := backend.Difference(frontend)
+	common.Print()
+	all.Print()
+	onlyBackend.Print()
+
+	data, _ := json.Marshal(backend.SymmetricDifference(frontend))
+	fmt.Println(string(data)) // ["css","go","js","sql"]
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v .
+
+func TestSetAlgebra(t *testing.T) {
+	a := NewSet(1, 2, 3)
+	b := NewSet(3, 4)
+
+	union := a.Union(b)
+	intersection := a.Intersection(b)
+	difference := a.Difference(b)
+	symmetric := a.SymmetricDifference(b)
+	assert.ElementsMatch(t, []int{1, 2, 3, 4}, slices.Collect(union.All()))
+	assert.ElementsMatch(t, []int{3}, slices.Collect(intersection.All()))
+	assert.ElementsMatch(t, []int{1, 2}, slices.Collect(difference.All()))
+	assert.ElementsMatch(t, []int{1, 2, 4}, slices.Collect(symmetric.All()))
+
+	// arguments are not changed
+	assert.Equal(t, 3, a.Len())
+	assert.Equal(t, 2, b.Len())
+
+	assert.True(t, intersection.IsSubset(a))
+	assert.True(t, a.IsSubset(union))
+	assert.False(t, a.IsSubset(b))
+	assert.True(t, a.Equal(NewSet(3, 2, 1)))
+	assert.False(t, a.Equal(union))
+
+	var empty Set[int]
+	assert.Zero(t, empty.Len())
+	assert.True(t, empty.IsSubset(a))
+	emptyUnion := empty.Union(b)
+	assert.True(t, emptyUnion.Equal(b))
+}
+
+func TestSetJSON(t *testing.T) {
+	set := NewSet("b", "c", "a")
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	assert.JSONEq(t, `["a","b","c"]`, string(data))
+
+	// sets inside other values are sorted too, by JSON and not by value
+	data, err = json.Marshal(map[string]Set[int]{"ids": NewSet(10, 2)})
+	require.NoError(t, err)
+	assert.Equal(t, `{"ids":[10,2]}`, string(data))
+
+	var decoded Set[string]
+	require.NoError(t, json.Unmarshal([]byte(`["x","y","x"]`), &decoded))
+	assert.True(t, decoded.Equal(NewSet("x", "y")))
+
+	assert.Error(t, json.Unmarshal([]byte(`[1]`), &decoded))
+}